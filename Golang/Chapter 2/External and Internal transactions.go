@@ -2,30 +2,42 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"encoding/base64"
 	"log"
-	"strings"
-	"time"
 
-	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-go/tl"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/signer"
 )
 
 func main() {
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	internalMessageBody := cell.BeginCell().
 		MustStoreUInt(0, 32).                // write 32 zero bits to indicate that a text comment will follow
 		MustStoreStringSnake("Hello, TON!"). // write our text comment
 		EndCell()
 
-	walletAddress := address.MustParseAddr("put your address")
+	walletAddress, err := cfg.WalletAddress() // wallet = "your address" in the config or TON_WALLET
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
+
+	amount := tlb.MustFromTON("0.2")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount in the config protects from a mistyped amount
+		log.Fatalln(err.Error())
+		return
+	}
 
 	internalMessage := cell.BeginCell().
 		MustStoreUInt(0, 1).     // indicate that it is an internal message -> int_msg_info$0
@@ -34,22 +46,19 @@ func main() {
 		MustStoreBoolBit(false). // bounced
 		MustStoreUInt(0, 2).     // src -> addr_none
 		MustStoreAddr(walletAddress).
-		MustStoreCoins(tlb.MustFromTON("0.2").NanoTON().Uint64()). // amount
-		MustStoreBoolBit(false).                                   // Extra currency
-		MustStoreCoins(0).                                         // IHR Fee
-		MustStoreCoins(0).                                         // Forwarding Fee
-		MustStoreUInt(0, 64).                                      // Logical time of creation
-		MustStoreUInt(0, 32).                                      // UNIX time of creation
-		MustStoreBoolBit(false).                                   // No State Init
-		MustStoreBoolBit(true).                                    // We store Message Body as a reference
-		MustStoreRef(internalMessageBody).                         // Store Message Body as a reference
+		MustStoreCoins(amount.NanoTON().Uint64()). // amount
+		MustStoreBoolBit(false).                   // Extra currency
+		MustStoreCoins(0).                         // IHR Fee
+		MustStoreCoins(0).                         // Forwarding Fee
+		MustStoreUInt(0, 64).                      // Logical time of creation
+		MustStoreUInt(0, 32).                      // UNIX time of creation
+		MustStoreBoolBit(false).                   // No State Init
+		MustStoreBoolBit(true).                    // We store Message Body as a reference
+		MustStoreRef(internalMessageBody).         // Store Message Body as a reference
 		EndCell()
 
-	mnemonic := strings.Split("put your mnemonic", " ") // get our mnemonic as array
-
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
@@ -68,21 +77,28 @@ func main() {
 	}
 	seqno := getMethodResult.MustInt(0) // get seqno from response
 
-	// The next three lines will extract the private key using the mnemonic phrase. We will not go into cryptographic details. With the tonutils-go library, this is all implemented, but we’re doing it again to get a full understanding.
-	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonic, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-
-	privateKey := ed25519.NewKeyFromSeed(k)
+	// The key is loaded by its name from the encrypted keystore, so the mnemonic is not written in the code. Add it with `go run ./cmd/keystore add <name>` and put the passphrase to the TON_KEYSTORE_PASSPHRASE environment variable.
+	// Inside, keys.FromMnemonicWithPassword extracts the private key using the mnemonic phrase. It is hmac + pbkdf2 with "TON default seed" as salt, but first it checks that the mnemonic is valid, so a typo will not give us a different wallet.
+	keyPair, err := cfg.LoadKey() // key = "the key name of your wallet" in the config, it is the same as keystore.LoadKey(cfg.Keystore, cfg.Key, os.Getenv(keystore.PassphraseEnv))
+	// keyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	keySigner := signer.FromKeyPair(keyPair) // messages are signed through signer.Signer, the key may also be in another process: signer.NewUnixSigner, signer.NewHTTPSigner or signer.NewFileSigner
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32).                          // subwallet_id | We consider this further
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32). // Message expiration time, +60 = 1 minute
-		MustStoreUInt(seqno.Uint64(), 32).                     // store seqno
-		MustStoreUInt(uint64(3), 8).                           // store mode of our internal message
-		MustStoreRef(internalMessage)                          // store our internalMessage as a reference
+		MustStoreUInt(uint64(cfg.SubwalletID), 32).         // subwallet_id | We consider this further
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // Message expiration time, timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(seqno.Uint64(), 32).                  // store seqno
+		MustStoreUInt(uint64(3), 8).                        // store mode of our internal message
+		MustStoreRef(internalMessage)                       // store our internalMessage as a reference
 
-	signature := ed25519.Sign(privateKey, toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	signature, err := keySigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
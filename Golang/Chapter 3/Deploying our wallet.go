@@ -2,40 +2,62 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"encoding/base64"
 	"log"
-	"strings"
-	"time"
+	"os"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-go/tl"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
-	"github.com/xssnick/tonutils-go/ton/wallet"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/keys"
+	"main/keystore"
+	"main/signer"
 )
 
 func main() {
-	// mnemonic := strings.Split("put your mnemonic", " ") // get our mnemonic as array
-	mnemonic := wallet.NewSeed() // get new mnemonic
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
+	password := "" // put a password here if the new mnemonic should be protected by it
+	// mnemonic := keys.ParseMnemonic("put your mnemonic") // get our mnemonic as array
+	mnemonic, err := keys.NewMnemonicWithPassword(password) // get new mnemonic, without a password it is the same as wallet.NewSeed()
+	if err != nil {
+		panic(err)
+	}
 
-	// The following three lines will extract the private key using the mnemonic phrase. We will not go into cryptographic details. It has all been implemented in the tonutils-go library, but it immediately returns the finished object of the wallet with the address and ready methods. So we’ll have to write the lines to get the key separately. Goland IDE will automatically import all required libraries (crypto, pbkdf2 and others).
-	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonic, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
+	// keys.FromMnemonicWithPassword will extract the private key using the mnemonic phrase. It has also been implemented in the tonutils-go library, but there it immediately returns the finished object of the wallet with the address and ready methods. Inside it is hmac + pbkdf2 with "TON default seed" as salt, and before that the mnemonic is checked, so a typo returns an error instead of a key of some other wallet.
+	keyPair, err := keys.FromMnemonicWithPassword(mnemonic, password)
+	if err != nil {
+		panic(err)
+	}
 
-	privateKey := ed25519.NewKeyFromSeed(k)              // get private key
-	publicKey := privateKey.Public().(ed25519.PublicKey) // get public key from private key
-	log.Println(publicKey)                               // print publicKey so that at this stage the compiler does not complain that we do not use our variable
-	log.Println(mnemonic)                                // if we want, we can print our mnemonic
+	publicKey := keyPair.PublicKey // get public key
+	log.Println(publicKey)         // print publicKey so that at this stage the compiler does not complain that we do not use our variable
+	log.Println(mnemonic)          // if we want, we can print our mnemonic
+
+	keySigner := signer.FromKeyPair(keyPair) // see signer.Signer in Chapter 2
+
+	// Save the new mnemonic to the encrypted keystore only when a key name is set in the config (key or TON_KEY),
+	// so the next examples can load it. The passphrase is taken from the TON_KEYSTORE_PASSPHRASE environment variable.
+	if cfg.Key != "" {
+		ks, err := keystore.Open(cfg.Keystore)
+		if err != nil {
+			panic(err)
+		}
+		if err = ks.Add(cfg.Key, mnemonic, password, os.Getenv(keystore.PassphraseEnv)); err != nil {
+			log.Println("Keystore err:", err.Error()) // for example the name is already taken: the mnemonic above is not saved, the deploy goes on
+		}
+	}
 
-	var subWallet uint64 = 698983191
+	subWallet := uint64(cfg.SubwalletID) // subwallet_id in the config, 698983191 by default
 
 	base64BOC := "te6ccgEBCAEAhgABFP8A9KQT9LzyyAsBAgEgAgMCAUgEBQCW8oMI1xgg0x/TH9MfAvgju/Jj7UTQ0x/TH9P/0VEyuvKhUUS68qIE+QFUEFX5EPKj+ACTINdKltMH1AL7AOgwAaTIyx/LH8v/ye1UAATQMAIBSAYHABe7Oc7UTQ0z8x1wv/gAEbjJftRNDXCx+A==" // save our base64 encoded output from compiler to variable
 	codeCellBytes, _ := base64.StdEncoding.DecodeString(base64BOC)                                                                                                                                                      // decode base64 in order to get byte array
@@ -48,7 +70,7 @@ func main() {
 
 	dataCell := cell.BeginCell().
 		MustStoreUInt(0, 32).           // Seqno
-		MustStoreUInt(698983191, 32).   // Subwallet ID
+		MustStoreUInt(subWallet, 32).   // Subwallet ID
 		MustStoreSlice(publicKey, 256). // Public Key
 		EndCell()
 
@@ -62,18 +84,30 @@ func main() {
 		MustStoreBoolBit(false). // No library
 		EndCell()
 
-	contractAddress := address.NewAddress(0, 0, stateInit.Hash()) // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
-	log.Println("Contract address:", contractAddress.String())    // Output contract address to console
+	contractAddress := address.NewAddress(0, 0, stateInit.Hash())        // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
+	log.Println("Contract address:", cfg.FormatAddress(contractAddress)) // Output contract address to console, with the testnet flag on a test network
 
 	internalMessageBody := cell.BeginCell().
 		MustStoreUInt(0, 32).
 		MustStoreStringSnake("Hello, TON!").
 		EndCell()
 
+	firstWallet, err := cfg.WalletAddress() // wallet = "your first wallet address from were you sent 0.1 TON" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
+
+	amount := tlb.MustFromTON("0.03")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+		log.Fatalln(err.Error())
+		return
+	}
+
 	internalMessage := cell.BeginCell().
 		MustStoreUInt(0x10, 6). // no bounce
-		MustStoreAddr(address.MustParseAddr("put your first wallet address from were you sent 0.1 TON")).
-		MustStoreBigCoins(tlb.MustFromTON("0.03").NanoTON()).
+		MustStoreAddr(firstWallet).
+		MustStoreBigCoins(amount.NanoTON()).
 		MustStoreUInt(1, 1+4+4+64+32+1+1). // We store 1 that means we have body as a reference
 		MustStoreRef(internalMessageBody).
 		EndCell()
@@ -81,12 +115,16 @@ func main() {
 	// message for our wallet
 	toSign := cell.BeginCell().
 		MustStoreUInt(subWallet, 32).
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32).
-		MustStoreUInt(0, 32). // We put seqno = 0, because after deploying wallet will store 0 as seqno
-		MustStoreUInt(3, 8).
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(0, 32).                               // We put seqno = 0, because after deploying wallet will store 0 as seqno
+		MustStoreUInt(3, 8).                                // send mode: pay fees separately, ignore errors
 		MustStoreRef(internalMessage)
 
-	signature := ed25519.Sign(privateKey, toSign.EndCell().Hash())
+	signature, err := keySigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512).
 		MustStoreBuilder(toSign).
@@ -105,8 +143,7 @@ func main() {
 		EndCell()
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err = connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
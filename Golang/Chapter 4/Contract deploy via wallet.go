@@ -2,13 +2,9 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"encoding/base64"
 	"log"
-	"strings"
-	"time"
+	"os"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
@@ -16,22 +12,26 @@ import (
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/keystore"
+	"main/signer"
 )
 
 func main() {
-	mnemonicArray := strings.Split("put your mnemonic", " ")
-	// The following three lines will extract the private key using the mnemonic phrase.
-	// We will not go into cryptographic details. In the library tonutils-go, it is all implemented,
-	// but it immediately returns the finished object of the wallet with the address and ready-made methods.
-	// So we’ll have to write the lines to get the key separately. Goland IDE will automatically import
-	// all required libraries (crypto, pbkdf2 and others).
-	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonicArray, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	privateKey := ed25519.NewKeyFromSeed(k)              // get private key
-	publicKey := privateKey.Public().(ed25519.PublicKey) // get public key from private key
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	keyPair, err := keystore.LoadKey(cfg.Keystore, "put the key name of the wallet you will deploy", os.Getenv(keystore.PassphraseEnv))
+	// keyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		panic(err)
+	}
+	publicKey := keyPair.PublicKey // get public key
 
 	BOCBytes, _ := base64.StdEncoding.DecodeString("te6ccgEBCAEAhgABFP8A9KQT9LzyyAsBAgEgAgMCAUgEBQCW8oMI1xgg0x/TH9MfAvgju/Jj7UTQ0x/TH9P/0VEyuvKhUUS68qIE+QFUEFX5EPKj+ACTINdKltMH1AL7AOgwAaTIyx/LH8v/ye1UAATQMAIBSAYHABe7Oc7UTQ0z8x1wv/gAEbjJftRNDXCx+A==")
 	codeCell, _ := cell.FromBOC(BOCBytes)
@@ -51,18 +51,24 @@ func main() {
 		MustStoreBoolBit(false). // No library
 		EndCell()
 
-	contractAddress := address.NewAddress(0, 0, stateInit.Hash()) // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
-	log.Println("Contract address:", contractAddress.String())    // Output contract address to console
+	contractAddress := address.NewAddress(0, 0, stateInit.Hash())        // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
+	log.Println("Contract address:", cfg.FormatAddress(contractAddress)) // Output contract address to console, with the testnet flag on a test network
 
 	internalMessageBody := cell.BeginCell().
 		MustStoreUInt(0, 32).
 		MustStoreStringSnake("Deploying...").
 		EndCell()
 
+	amount := tlb.MustFromTON("0.01")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+		log.Fatalln(err.Error())
+		return
+	}
+
 	internalMessage := cell.BeginCell().
 		MustStoreUInt(0x10, 6). // no bounce
 		MustStoreAddr(contractAddress).
-		MustStoreBigCoins(tlb.MustFromTON("0.01").NanoTON()).
+		MustStoreBigCoins(amount.NanoTON()).
 		MustStoreUInt(0, 1+4+4+64+32).
 		MustStoreBoolBit(true).            // We have State Init
 		MustStoreBoolBit(true).            // We store State Init as a reference
@@ -72,8 +78,7 @@ func main() {
 		EndCell()
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
@@ -85,13 +90,20 @@ func main() {
 		return
 	}
 
-	walletMnemonicArray := strings.Split("put your mnemonic", " ")
-	mac = hmac.New(sha512.New, []byte(strings.Join(walletMnemonicArray, " ")))
-	hash = mac.Sum(nil)
-	k = pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	walletPrivateKey := ed25519.NewKeyFromSeed(k) // get private key
-	walletAddress := address.MustParseAddr("put your wallet address with which you will deploy")
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	walletKeyPair, err := cfg.LoadKey() // key = "the key name of your wallet" in the config
+	// walletKeyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	walletSigner := signer.FromKeyPair(walletKeyPair) // see signer.Signer in Chapter 2
+
+	walletAddress, err := cfg.WalletAddress() // wallet = "your wallet address with which you will deploy" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
 
 	getMethodResult, err := client.RunGetMethod(context.Background(), block, walletAddress, "seqno") // run "seqno" GET method from your wallet contract
 	if err != nil {
@@ -101,14 +113,18 @@ func main() {
 	seqno := getMethodResult.MustInt(0) // get seqno from response
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32).                          // subwallet_id | We consider this further
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32). // message expiration time, +60 = 1 minute
-		MustStoreUInt(seqno.Uint64(), 32).                     // store seqno
+		MustStoreUInt(uint64(cfg.SubwalletID), 32).         // subwallet_id | We consider this further
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // message expiration time, timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(seqno.Uint64(), 32).                  // store seqno
 		// Do not forget that if we use Wallet V4, we need to add .MustStoreUInt(0, 8)
 		MustStoreUInt(3, 8).          // store mode of our internal message
 		MustStoreRef(internalMessage) // store our internalMessage as a reference
 
-	signature := ed25519.Sign(walletPrivateKey, toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	signature, err := walletSigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
@@ -7,12 +7,19 @@ import (
 	"github.com/xssnick/tonutils-go/ton"
 	"log"
 	"math/big"
+
+	"main/config"
 )
 
 func main() {
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
@@ -2,12 +2,7 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"log"
-	"strings"
-	"time"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
@@ -15,12 +10,25 @@ import (
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/nft"
+	"main/signer"
 )
 
 func main() {
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	destinationAddress := address.MustParseAddr("put your wallet where you want to send NFT")
-	walletAddress := address.MustParseAddr("put your wallet which is the owner of NFT")
+	walletAddress, err := cfg.WalletAddress() // wallet = "your wallet which is the owner of NFT" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
 	nftAddress := address.MustParseAddr("put your nft address")
 
 	// We can add a comment, but it will not be displayed in the explorers,
@@ -36,38 +44,46 @@ func main() {
 		MustStoreAddr(destinationAddress).                    // new_owner
 		MustStoreAddr(walletAddress).                         // response_destination for excesses
 		MustStoreBoolBit(false).                              // we do not have custom_payload
-		MustStoreBigCoins(tlb.MustFromTON("0.01").NanoTON()). // forward_payload
+		MustStoreBigCoins(tlb.MustFromTON("0.01").NanoTON()). // forward_amount, sent to the new owner with forward_payload
 		MustStoreBoolBit(true).                               // we store forward_payload as a reference
 		MustStoreRef(forwardPayload).                         // store forward_payload as a reference
 		EndCell()
 
+	amount := tlb.MustFromTON("0.05")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+		log.Fatalln(err.Error())
+		return
+	}
+
 	internalMessage := cell.BeginCell().
 		MustStoreUInt(0x18, 6). // bounce
 		MustStoreAddr(nftAddress).
-		MustStoreBigCoins(tlb.MustFromTON("0.05").NanoTON()).
+		MustStoreBigCoins(amount.NanoTON()).
 		MustStoreUInt(1, 1+4+4+64+32+1+1). // We store 1 that means we have body as a reference
 		MustStoreRef(transferNftBody).
 		EndCell()
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
 	client := ton.NewAPIClient(connection)
 
-	mnemonic := strings.Split("put your mnemonic", " ") // word1 word2 word3
-	// The following three lines will extract the private key using the mnemonic phrase.
-	// We will not go into cryptographic details. In the library tonutils-go, it is all implemented,
-	// but it immediately returns the finished object of the wallet with the address and ready-made methods.
-	// So we’ll have to write the lines to get the key separately. Goland IDE will automatically import
-	// all required libraries (crypto, pbkdf2 and others).
-	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonic, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	privateKey := ed25519.NewKeyFromSeed(k) // get private key
+	// get_nft_data of the item tells its owner: if it is not our wallet, the transfer fails and the TON are spent on fees
+	if _, err = nft.CheckOwner(context.Background(), client, nftAddress, walletAddress, nil); err != nil { // pass the collection instead of nil to check it too
+		log.Fatalln("CheckOwner err:", err.Error())
+		return
+	}
+
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	keyPair, err := cfg.LoadKey() // key = "the key name of your wallet" in the config
+	// keyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	keySigner := signer.FromKeyPair(keyPair) // see signer.Signer in Chapter 2
 
 	block, err := client.CurrentMasterchainInfo(context.Background()) // get current block, we will need it in requests to LiteServer
 	if err != nil {
@@ -83,14 +99,18 @@ func main() {
 	seqno := getMethodResult.MustInt(0) // get seqno from response
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32).                          // subwallet_id | We consider this further
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32). // message expiration time, +60 = 1 minute
-		MustStoreUInt(seqno.Uint64(), 32).                     // store seqno
+		MustStoreUInt(uint64(cfg.SubwalletID), 32).         // subwallet_id | We consider this further
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // message expiration time, timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(seqno.Uint64(), 32).                  // store seqno
 		// Do not forget that if we use Wallet V4, we need to add .MustStoreUInt(0, 8)
 		MustStoreUInt(3, 8).          // store mode of our internal message
 		MustStoreRef(internalMessage) // store our internalMessage as a reference
 
-	signature := ed25519.Sign(privateKey, toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	signature, err := keySigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
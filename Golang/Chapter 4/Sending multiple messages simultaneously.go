@@ -2,12 +2,7 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"log"
-	"strings"
-	"time"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
@@ -15,10 +10,19 @@ import (
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/message"
+	"main/signer"
 )
 
 func main() {
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	internalMessagesAmount := [4]string{"0.01", "0.02", "0.03", "0.04"}
 	internalMessagesComment := [4]string{
 		"Hello, TON! #1",
@@ -32,16 +36,37 @@ func main() {
 		"Put any address that belongs to you",
 		"Put any address that belongs to you",
 	} // All 4 addresses can be the same
+	internalMessagesMode := [4]message.SendMode{
+		message.ModeDefault, // 3 = pay fees separately + ignore errors
+		message.ModeDefault,
+		message.ModeDefault,
+		message.ModeDefault, // message.ModeCarryAll sends the rest of the balance, only in the last message
+	}
+
+	// Each mode is valid on its own, but the batch can still go wrong, for example with
+	// a mode 128 message which is not the last one: the messages after it get nothing.
+	warnings, err := message.CheckBatch(internalMessagesMode[:]...)
+	if err != nil {
+		log.Fatalln("CheckBatch err:", err.Error())
+		return
+	}
+	for _, warning := range warnings {
+		log.Println("Warning:", warning)
+	}
 
 	var internalMessages [len(internalMessagesAmount)]*cell.Cell // array for our internal messages
 
 	for i := 0; i < len(internalMessagesAmount); i++ {
-		amount := internalMessagesAmount[i]
+		amount := tlb.MustFromTON(internalMessagesAmount[i])
+		if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+			log.Fatalln(err.Error())
+			return
+		}
 
 		internalMessage := cell.BeginCell().
 			MustStoreUInt(0x18, 6). // bounce
 			MustStoreAddr(address.MustParseAddr(destinationAddresses[i])).
-			MustStoreBigCoins(tlb.MustFromTON(amount).NanoTON()).
+			MustStoreBigCoins(amount.NanoTON()).
 			MustStoreUInt(0, 1+4+4+64+32+1)
 
 		/*
@@ -54,6 +79,7 @@ func main() {
 		if internalMessagesComment[i] != "" {
 			internalMessage.MustStoreBoolBit(true) // we store Message Body as a reference
 
+			// comment.Text(...) builds the same body, comment.Encrypt(...) an encrypted one only the receiver can read
 			internalMessageBody := cell.BeginCell().
 				MustStoreUInt(0, 32).
 				MustStoreStringSnake(internalMessagesComment[i]).
@@ -71,27 +97,27 @@ func main() {
 		internalMessages[i] = internalMessage.EndCell()
 	}
 
-	walletAddress := address.MustParseAddr("put your wallet address")
+	walletAddress, err := cfg.WalletAddress() // wallet = "your wallet address" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
 	client := ton.NewAPIClient(connection)
 
-	mnemonic := strings.Split("put your mnemonic", " ") // word1 word2 word3
-	// The following three lines will extract the private key using the mnemonic phrase.
-	// We will not go into cryptographic details. In the library tonutils-go, it is all implemented,
-	// but it immediately returns the finished object of the wallet with the address and ready-made methods.
-	// So we’ll have to write the lines to get the key separately. Goland IDE will automatically import
-	// all required libraries (crypto, pbkdf2 and others).
-	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonic, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	privateKey := ed25519.NewKeyFromSeed(k) // get private key
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	keyPair, err := cfg.LoadKey() // key = "the key name of your wallet" in the config
+	// keyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	keySigner := signer.FromKeyPair(keyPair) // see signer.Signer in Chapter 2
 
 	block, err := client.CurrentMasterchainInfo(context.Background()) // get current block, we will need it in requests to LiteServer
 	if err != nil {
@@ -107,18 +133,22 @@ func main() {
 	seqno := getMethodResult.MustInt(0) // get seqno from response
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32).                          // subwallet_id | We consider this further
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32). // message expiration time, +60 = 1 minute
-		MustStoreUInt(seqno.Uint64(), 32)                      // store seqno
+		MustStoreUInt(uint64(cfg.SubwalletID), 32).         // subwallet_id | We consider this further
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // message expiration time, timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(seqno.Uint64(), 32)                   // store seqno
 	// Do not forget that if we use Wallet V4, we need to add .MustStoreUInt(0, 8)
 
 	for i := 0; i < len(internalMessages); i++ {
 		internalMessage := internalMessages[i]
-		toSign.MustStoreUInt(3, 8)           // store mode of our internal message
-		toSign.MustStoreRef(internalMessage) // store our internalMessage as a reference
+		toSign.MustStoreUInt(uint64(internalMessagesMode[i]), 8) // store mode of our internal message
+		toSign.MustStoreRef(internalMessage)                     // store our internalMessage as a reference
 	}
 
-	signature := ed25519.Sign(privateKey, toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	signature, err := keySigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
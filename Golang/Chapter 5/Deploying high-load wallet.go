@@ -2,13 +2,9 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"encoding/base64"
 	"log"
-	"strings"
-	"time"
+	"os"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
@@ -16,10 +12,19 @@ import (
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/keystore"
+	"main/signer"
 )
 
 func main() {
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	base64BOC := "te6ccgEBCQEA5QABFP8A9KQT9LzyyAsBAgEgAgMCAUgEBQHq8oMI1xgg0x/TP/gjqh9TILnyY+1E0NMf0z/T//QE0VNggED0Dm+hMfJgUXO68qIH+QFUEIf5EPKjAvQE0fgAf44WIYAQ9HhvpSCYAtMH1DAB+wCRMuIBs+ZbgyWhyEA0gED0Q4rmMQHIyx8Tyz/L//QAye1UCAAE0DACASAGBwAXvZznaiaGmvmOuF/8AEG+X5dqJoaY+Y6Z/p/5j6AmipEEAgegc30JjJLb/JXdHxQANCCAQPSWb6VsEiCUMFMDud4gkzM2AZJsIeKz" // save our base64 encoded output from compiler to variable
 	codeCellBytes, _ := base64.StdEncoding.DecodeString(base64BOC)                                                                                                                                                                                                                                                                                  // decode base64 in order to get byte array
 	codeCell, err := cell.FromBOC(codeCellBytes)                                                                                                                                                                                                                                                                                                    // get cell with code from byte array
@@ -29,14 +34,15 @@ func main() {
 
 	log.Println("Hash:", base64.StdEncoding.EncodeToString(codeCell.Hash())) // get the hash of our cell, encode it to base64 because it has []byte type and output to the terminal
 
-	highloadMnemonicArray := strings.Split("put your mnemonic that you have generated and saved before", " ") // word1 word2 word3
-	mac := hmac.New(sha512.New, []byte(strings.Join(highloadMnemonicArray, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	highloadPrivateKey := ed25519.NewKeyFromSeed(k)                      // get private key
-	highloadPublicKey := highloadPrivateKey.Public().(ed25519.PublicKey) // get public key from private key
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	highloadKeyPair, err := keystore.LoadKey(cfg.Keystore, "put the key name of your high-load wallet", os.Getenv(keystore.PassphraseEnv))
+	// highloadKeyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic that you have generated and saved before"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		panic(err)
+	}
+	highloadPublicKey := highloadKeyPair.PublicKey // get public key
 
+	// highload.ParseData reads such a cell back, with every query_id the wallet remembers in the dictionary
 	dataCell := cell.BeginCell().
 		MustStoreUInt(698983191, 32).           // Subwallet ID
 		MustStoreUInt(0, 64).                   // Last cleaned
@@ -54,18 +60,24 @@ func main() {
 		MustStoreBoolBit(false). // No library
 		EndCell()
 
-	contractAddress := address.NewAddress(0, 0, stateInit.Hash()) // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
-	log.Println("Contract address:", contractAddress.String())    // Output contract address to console
+	contractAddress := address.NewAddress(0, 0, stateInit.Hash())        // get the hash of stateInit to get the address of our smart contract in workchain with ID 0
+	log.Println("Contract address:", cfg.FormatAddress(contractAddress)) // Output contract address to console, with the testnet flag on a test network
 
 	internalMessageBody := cell.BeginCell().
 		MustStoreUInt(0, 32).
 		MustStoreStringSnake("Deploying...").
 		EndCell()
 
+	amount := tlb.MustFromTON("0.01")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+		log.Fatalln(err.Error())
+		return
+	}
+
 	internalMessage := cell.BeginCell().
 		MustStoreUInt(0x10, 6). // no bounce
 		MustStoreAddr(contractAddress).
-		MustStoreBigCoins(tlb.MustFromTON("0.01").NanoTON()).
+		MustStoreBigCoins(amount.NanoTON()).
 		MustStoreUInt(0, 1+4+4+64+32).
 		MustStoreBoolBit(true).            // We have State Init
 		MustStoreBoolBit(true).            // We store State Init as a reference
@@ -75,8 +87,7 @@ func main() {
 		EndCell()
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err = connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
@@ -88,13 +99,20 @@ func main() {
 		return
 	}
 
-	walletMnemonicArray := strings.Split("put your mnemonic", " ")
-	mac = hmac.New(sha512.New, []byte(strings.Join(walletMnemonicArray, " ")))
-	hash = mac.Sum(nil)
-	k = pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	walletPrivateKey := ed25519.NewKeyFromSeed(k) // get private key
-	walletAddress := address.MustParseAddr("put your wallet address with which you will deploy")
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	walletKeyPair, err := cfg.LoadKey() // key = "the key name of your wallet" in the config
+	// walletKeyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	walletSigner := signer.FromKeyPair(walletKeyPair) // see signer.Signer in Chapter 2
+
+	walletAddress, err := cfg.WalletAddress() // wallet = "your wallet address with which you will deploy" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
 
 	getMethodResult, err := client.RunGetMethod(context.Background(), block, walletAddress, "seqno") // run "seqno" GET method from your wallet contract
 	if err != nil {
@@ -104,14 +122,18 @@ func main() {
 	seqno := getMethodResult.MustInt(0) // get seqno from response
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32).                          // subwallet_id | We consider this further
-		MustStoreUInt(uint64(time.Now().UTC().Unix()+60), 32). // message expiration time, +60 = 1 minute
-		MustStoreUInt(seqno.Uint64(), 32).                     // store seqno
+		MustStoreUInt(uint64(cfg.SubwalletID), 32).         // subwallet_id | We consider this further
+		MustStoreUInt(uint64(cfg.ValidUntil().Unix()), 32). // message expiration time, timeouts.valid_for in the config, 1 minute by default
+		MustStoreUInt(seqno.Uint64(), 32).                  // store seqno
 		// Do not forget that if we use Wallet V4, we need to add .MustStoreUInt(0, 8)
 		MustStoreUInt(3, 8).          // store mode of our internal message
 		MustStoreRef(internalMessage) // store our internalMessage as a reference
 
-	signature := ed25519.Sign(walletPrivateKey, toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	signature, err := walletSigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
@@ -2,14 +2,10 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/hmac"
-	"crypto/sha512"
 	"fmt"
 	"log"
 	"math/big"
 	"math/rand"
-	"strings"
 	"time"
 
 	"github.com/xssnick/tonutils-go/address"
@@ -18,13 +14,28 @@ import (
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-go/tvm/cell"
-	"golang.org/x/crypto/pbkdf2"
+
+	"main/config"
+	"main/signer"
 )
 
 func main() {
+	// Here the config describes the high-load wallet: its address, key and subwallet_id.
+	cfg, err := config.Load("") // settings from ton.toml (or the file in TON_CONFIG) and TON_* environment variables
+	if err != nil {
+		log.Fatalln("Config err:", err.Error())
+		return
+	}
+
 	var internalMessages []*cell.Cell
 	walletAddress := address.MustParseAddr("put your wallet address from which you deployed high-load wallet")
 
+	amount := tlb.MustFromTON("0.001")
+	if err = cfg.CheckAmount(amount); err != nil { // fees.max_amount, see Chapter 2
+		log.Fatalln(err.Error())
+		return
+	}
+
 	for i := 0; i < 12; i++ {
 		comment := fmt.Sprintf("Hello, TON! #%d", i)
 		internalMessageBody := cell.BeginCell().
@@ -35,7 +46,7 @@ func main() {
 		internalMessage := cell.BeginCell().
 			MustStoreUInt(0x18, 6). // bounce
 			MustStoreAddr(walletAddress).
-			MustStoreBigCoins(tlb.MustFromTON("0.001").NanoTON()).
+			MustStoreBigCoins(amount.NanoTON()).
 			MustStoreUInt(0, 1+4+4+64+32).
 			MustStoreBoolBit(false).           // We do not have State Init
 			MustStoreBoolBit(true).            // We store Message Body as a reference
@@ -60,25 +71,36 @@ func main() {
 	}
 
 	queryID := rand.Uint32()
-	timeout := 120                                                               // timeout for message expiration, 120 seconds = 2 minutes
-	now := time.Now().Add(time.Duration(timeout)*time.Second).UTC().Unix() << 32 // get current timestamp + timeout
-	finalQueryID := uint64(now) + uint64(queryID)                                // get our final query_id
-	log.Println(finalQueryID)                                                    // print query_id. With this query_id we can call GET method to check if our request has been processed
+	timeout := cfg.Timeouts.ValidFor                  // timeout for message expiration, timeouts.valid_for in the config
+	now := time.Now().Add(timeout).UTC().Unix() << 32 // get current timestamp + timeout
+	finalQueryID := uint64(now) + uint64(queryID)     // get our final query_id
+	log.Println(finalQueryID)                         // print query_id. With this query_id we can call GET method to check if our request has been processed
 
 	toSign := cell.BeginCell().
-		MustStoreUInt(698983191, 32). // subwallet_id
+		MustStoreUInt(uint64(cfg.SubwalletID), 32). // subwallet_id
 		MustStoreUInt(finalQueryID, 64).
 		MustStoreDict(dictionary)
 
-	highloadMnemonicArray := strings.Split("put your high-load wallet mnemonic", " ") // word1 word2 word3
-	mac := hmac.New(sha512.New, []byte(strings.Join(highloadMnemonicArray, " ")))
-	hash := mac.Sum(nil)
-	k := pbkdf2.Key(hash, []byte("TON default seed"), 100000, 32, sha512.New) // In TON libraries "TON default seed" is used as salt when getting keys
-	// 32 is a key len
-	highloadPrivateKey := ed25519.NewKeyFromSeed(k) // get private key
-	highloadWalletAddress := address.MustParseAddr("put your high-load wallet address")
+	// The key is loaded from the encrypted keystore, as in Chapter 2
+	highloadKeyPair, err := cfg.LoadKey() // key = "the key name of your high-load wallet" in the config
+	// highloadKeyPair, err := keys.FromMnemonicWithPassword(keys.ParseMnemonic("put your high-load wallet mnemonic"), "") // or get the key from the mnemonic directly
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+		return
+	}
+	highloadSigner := signer.FromKeyPair(highloadKeyPair) // see signer.Signer in Chapter 2
 
-	signature := ed25519.Sign(highloadPrivateKey, toSign.EndCell().Hash())
+	highloadWalletAddress, err := cfg.WalletAddress() // wallet = "your high-load wallet address" in the config
+	if err != nil {
+		log.Fatalln("WalletAddress err:", err.Error())
+		return
+	}
+
+	signature, err := highloadSigner.Sign(context.Background(), toSign.EndCell().Hash()) // get the hash of our message to wallet smart contract and sign it to get signature
+	if err != nil {
+		log.Fatalln("Sign err:", err.Error())
+		return
+	}
 
 	body := cell.BeginCell().
 		MustStoreSlice(signature, 512). // store signature
@@ -96,8 +118,7 @@ func main() {
 		EndCell()
 
 	connection := liteclient.NewConnectionPool()
-	configUrl := "https://ton-blockchain.github.io/global.config.json"
-	err := connection.AddConnectionsFromConfigUrl(context.Background(), configUrl)
+	err = cfg.AddConnections(context.Background(), connection) // liteserver_config from the config, a URL or a local file
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,396 @@
+// Command collection deploys an NFT collection (TEP-62) from the wallet in the
+// config, whatever version it is (see package detect), and mints its items.
+//
+//	go run ./cmd/collection deploy -code nft-collection.boc -item-code nft-item.boc \
+//	    -content https://example.com/collection.json -common https://example.com/items/ -royalty 5/100
+//	go run ./cmd/collection mint -collection <collection> -owner <owner> -content 0.json
+//	go run ./cmd/collection batch -collection <collection> -file items.csv
+//	go run ./cmd/collection address -collection <collection> -index 12
+//
+// -code and -item-code are the compiled nft-collection.fc and nft-item.fc of
+// the standard contracts, as a BOC file, hex or base64. The wallet in the
+// config becomes the owner of the collection, the only one who mints. The
+// items get the next indexes of the collection unless -index (-from for batch)
+// is given. items.csv has one item per line: "owner,content", for example
+// "EQ...,12.json"; batch mints up to 249 items in one message.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/config"
+	"main/detect"
+	"main/inspect"
+	"main/message"
+	"main/nft"
+	"main/signer"
+)
+
+// mintFee is what the collection spends to deploy one item, on top of the item amount.
+const mintFee = "0.02"
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "deploy":
+		err = deploy(cfg, os.Args[2:])
+	case "mint":
+		err = mint(cfg, os.Args[2:])
+	case "batch":
+		err = batch(cfg, os.Args[2:])
+	case "address":
+		err = itemAddress(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: collection deploy|mint|batch|address [flags]")
+	os.Exit(2)
+}
+
+func deploy(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	codeFlag := fs.String("code", "", "code of the collection: BOC file, hex or base64")
+	itemCodeFlag := fs.String("item-code", "", "code of the items: BOC file, hex or base64")
+	content := fs.String("content", "", "URI of the collection metadata JSON")
+	common := fs.String("common", "", "URI prefix of the item metadata, like https://example.com/items/")
+	royaltyFlag := fs.String("royalty", "0/100", "royalty share, like 5/100 for 5%")
+	royaltyTo := fs.String("royalty-address", "", "address which gets the royalty, the wallet if empty")
+	value := fs.String("ton", "0.05", "TON sent to the collection for its storage")
+	_ = fs.Parse(args)
+	if *codeFlag == "" || *itemCodeFlag == "" || *content == "" {
+		usage()
+	}
+
+	code, err := inspect.ParseBOC(*codeFlag)
+	if err != nil {
+		return fmt.Errorf("-code: %w", err)
+	}
+	itemCode, err := inspect.ParseBOC(*itemCodeFlag)
+	if err != nil {
+		return fmt.Errorf("-item-code: %w", err)
+	}
+	royalty, err := parseRoyalty(*royaltyFlag)
+	if err != nil {
+		return err
+	}
+	if *royaltyTo != "" {
+		if royalty.Address, err = address.ParseAddr(*royaltyTo); err != nil {
+			return fmt.Errorf("-royalty-address: %w", err)
+		}
+	}
+	amount, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckAmount(amount); err != nil {
+		return err
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	collection := nft.Collection{
+		Owner:         walletAddress,
+		Content:       nft.OffChain(*content),
+		CommonContent: *common,
+		ItemCode:      itemCode,
+		Royalty:       royalty,
+	}
+	stateInit, err := collection.StateInit(code)
+	if err != nil {
+		return err
+	}
+	collectionAddress := address.NewAddress(0, 0, stateInit.Hash())
+
+	// Like in Chapter 4 "Contract deploy via wallet": the collection is deployed by the message which carries its StateInit
+	internalMessage, err := (&message.Internal{
+		Dest:         collectionAddress,
+		Value:        amount,
+		StateInit:    stateInit,
+		StateInitRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	if err = send(cfg, client, walletAddress, internalMessage); err != nil {
+		return err
+	}
+	log.Println("Collection address:", cfg.FormatAddress(collectionAddress))
+	return nil
+}
+
+// parseRoyalty parses "5/100".
+func parseRoyalty(s string) (nft.Royalty, error) {
+	factor, base, ok := strings.Cut(s, "/")
+	if !ok {
+		return nft.Royalty{}, fmt.Errorf("-royalty %q: want factor/base, like 5/100", s)
+	}
+	f, err := strconv.ParseUint(factor, 10, 16)
+	if err != nil {
+		return nft.Royalty{}, fmt.Errorf("-royalty factor: %w", err)
+	}
+	b, err := strconv.ParseUint(base, 10, 16)
+	if err != nil {
+		return nft.Royalty{}, fmt.Errorf("-royalty base: %w", err)
+	}
+	return nft.Royalty{Factor: uint16(f), Base: uint16(b)}, nil
+}
+
+func mint(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("mint", flag.ExitOnError)
+	collectionFlag := fs.String("collection", "", "collection address")
+	owner := fs.String("owner", "", "owner of the new item, the wallet if empty")
+	content := fs.String("content", "", "item part of the content, like 12.json")
+	index := fs.Int64("index", -1, "item index, the next index of the collection if negative")
+	value := fs.String("ton", "0.05", "TON the item gets for its storage")
+	_ = fs.Parse(args)
+	if *collectionFlag == "" || *content == "" {
+		usage()
+	}
+
+	collectionAddress, err := address.ParseAddr(*collectionFlag)
+	if err != nil {
+		return fmt.Errorf("collection address: %w", err)
+	}
+	amount, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	itemOwner := walletAddress
+	if *owner != "" {
+		if itemOwner, err = address.ParseAddr(*owner); err != nil {
+			return fmt.Errorf("-owner: %w", err)
+		}
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	start, err := firstIndex(cfg, client, collectionAddress, walletAddress, *index)
+	if err != nil {
+		return err
+	}
+	m := nft.Mint{Index: start, Owner: itemOwner, Content: *content, Amount: amount}
+	body, err := nft.MintBody(uint64(time.Now().UnixNano()), m)
+	if err != nil {
+		return err
+	}
+
+	if err = sendToCollection(cfg, client, walletAddress, collectionAddress, body, 1, amount); err != nil {
+		return err
+	}
+	log.Printf("Sent the mint of item %d", start)
+	return printItemAddresses(cfg, client, collectionAddress, start, 1)
+}
+
+func batch(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	collectionFlag := fs.String("collection", "", "collection address")
+	file := fs.String("file", "", `CSV file with one "owner,content" per item`)
+	from := fs.Int64("from", -1, "index of the first item, the next index of the collection if negative")
+	value := fs.String("ton", "0.05", "TON every item gets for its storage")
+	_ = fs.Parse(args)
+	if *collectionFlag == "" || *file == "" {
+		usage()
+	}
+
+	collectionAddress, err := address.ParseAddr(*collectionFlag)
+	if err != nil {
+		return fmt.Errorf("collection address: %w", err)
+	}
+	amount, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	if len(records) > nft.MaxBatchMint {
+		return fmt.Errorf("%d items, one batch mints at most %d", len(records), nft.MaxBatchMint)
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	start, err := firstIndex(cfg, client, collectionAddress, walletAddress, *from)
+	if err != nil {
+		return err
+	}
+
+	var items []nft.Mint
+	for i, record := range records {
+		itemOwner, err := address.ParseAddr(record[0])
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", *file, i+1, err)
+		}
+		items = append(items, nft.Mint{Index: start + uint64(i), Owner: itemOwner, Content: record[1], Amount: amount})
+	}
+
+	body, err := nft.BatchMintBody(uint64(time.Now().UnixNano()), items)
+	if err != nil {
+		return err
+	}
+	if err = sendToCollection(cfg, client, walletAddress, collectionAddress, body, len(items), amount); err != nil {
+		return err
+	}
+	log.Printf("Sent the mint of items %d to %d", start, start+uint64(len(items))-1)
+	return printItemAddresses(cfg, client, collectionAddress, start, len(items))
+}
+
+func itemAddress(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("address", flag.ExitOnError)
+	collectionFlag := fs.String("collection", "", "collection address")
+	index := fs.Uint64("index", 0, "item index")
+	_ = fs.Parse(args)
+	if *collectionFlag == "" {
+		usage()
+	}
+
+	collectionAddress, err := address.ParseAddr(*collectionFlag)
+	if err != nil {
+		return fmt.Errorf("collection address: %w", err)
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	return printItemAddresses(cfg, client, collectionAddress, *index, 1)
+}
+
+// firstIndex returns index, or the next index of the collection if it is negative.
+// It also checks that the wallet owns the collection, else the collection
+// rejects the mint and the TON are spent on fees.
+func firstIndex(cfg *config.Config, client *ton.APIClient, collectionAddress, walletAddress *address.Address, index int64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	data, err := nft.GetCollectionData(ctx, client, collectionAddress)
+	if err != nil {
+		return 0, err
+	}
+	if data.Owner.Workchain() != walletAddress.Workchain() || string(data.Owner.Data()) != string(walletAddress.Data()) {
+		return 0, fmt.Errorf("the collection is owned by %s, not by the wallet", cfg.FormatAddress(data.Owner))
+	}
+
+	next := data.NextItemIndex.Uint64()
+	if index < 0 {
+		return next, nil
+	}
+	if uint64(index) > next {
+		return 0, fmt.Errorf("index %d is after the next index of the collection, %d", index, next)
+	}
+	return uint64(index), nil
+}
+
+func printItemAddresses(cfg *config.Config, client *ton.APIClient, collectionAddress *address.Address, start uint64, count int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	for i := uint64(0); i < uint64(count); i++ {
+		item, err := nft.ItemAddress(ctx, client, collectionAddress, start+i)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d %s\n", start+i, cfg.FormatAddress(item))
+	}
+	return nil
+}
+
+// sendToCollection sends body to the collection with the amount of every item plus mintFee for each.
+func sendToCollection(cfg *config.Config, client *ton.APIClient, walletAddress, collectionAddress *address.Address, body *cell.Cell, items int, amount tlb.Coins) error {
+	fee := tlb.MustFromTON(mintFee)
+	perItem := new(big.Int).Add(amount.NanoTON(), fee.NanoTON())
+	value := tlb.FromNanoTON(new(big.Int).Mul(perItem, big.NewInt(int64(items))))
+	if err := cfg.CheckAmount(value); err != nil {
+		return err
+	}
+
+	internalMessage, err := (&message.Internal{
+		Bounce:  true, // if the collection rejects the mint, the TON comes back
+		Dest:    collectionAddress,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+	return send(cfg, client, walletAddress, internalMessage)
+}
+
+// send sends one internal message with mode 3 from the wallet in the config,
+// whatever wallet it is (see package detect).
+func send(cfg *config.Config, client *ton.APIClient, walletAddress *address.Address, internalMessage *cell.Cell) error {
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Detect(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	detect.UseJournal(wallet, "")
+	messages := []message.Out{{Mode: message.ModeDefault, Message: internalMessage}}
+	externalMessage, err := wallet.Transfer(ctx, signer.FromKeyPair(keyPair), messages, cfg.ValidUntil())
+	if err != nil {
+		return err
+	}
+	return message.Send(ctx, client.Client(), externalMessage)
+}
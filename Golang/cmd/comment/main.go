@@ -0,0 +1,174 @@
+// Command comment builds and reads comments of internal messages.
+//
+//	go run ./cmd/comment text "Hello, TON!"
+//	go run ./cmd/comment encrypt -to <receiver wallet> "memo 12345"
+//	go run ./cmd/comment decrypt <base64, hex or file>
+//
+// text and encrypt print the message body as a base64 BOC. encrypt reads the
+// public key of the receiver with its get_public_key get method, or takes -pubkey.
+// decrypt takes a message body or a whole internal message, whose source is the
+// sender; for a body alone pass -from. The wallet and its key are the ones in
+// the config (see package config), the passphrase is in TON_KEYSTORE_PASSPHRASE.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/inspect"
+	"main/message"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "text":
+		err = text(os.Args[2:])
+	case "encrypt":
+		err = encrypt(cfg, os.Args[2:])
+	case "decrypt":
+		err = decrypt(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: comment text|encrypt|decrypt [flags] <text or BOC>")
+	os.Exit(2)
+}
+
+func text(args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+	body, err := comment.Text(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(body.ToBOC()))
+	return nil
+}
+
+func encrypt(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	to := fs.String("to", "", "receiver wallet address")
+	publicKeyHex := fs.String("pubkey", "", "receiver public key in hex, instead of reading it from the receiver wallet")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || (*to == "" && *publicKeyHex == "") {
+		usage()
+	}
+
+	sender, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+
+	var receiverKey ed25519.PublicKey
+	if *publicKeyHex != "" {
+		receiverKey, err = hex.DecodeString(*publicKeyHex)
+		if err != nil || len(receiverKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("-pubkey must be %d bytes in hex", ed25519.PublicKeySize)
+		}
+	} else {
+		receiver, err := address.ParseAddr(*to)
+		if err != nil {
+			return fmt.Errorf("receiver address: %w", err)
+		}
+
+		client, err := cli.Connect(cfg)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+		defer cancel()
+		if receiverKey, err = comment.ReceiverKey(ctx, client, receiver); err != nil {
+			return err
+		}
+	}
+
+	body, err := comment.Encrypt(fs.Arg(0), sender, keyPair.PrivateKey, receiverKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(body.ToBOC()))
+	return nil
+}
+
+func decrypt(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	from := fs.String("from", "", "sender wallet address, taken from the message if it is a whole internal message")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	c, err := inspect.ParseBOC(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	body, sender := c, (*address.Address)(nil)
+	if internal, err := message.ParseInternal(c); err == nil && internal.Source != nil {
+		body, sender = internal.Body, internal.Source
+	}
+	if *from != "" {
+		if sender, err = address.ParseAddr(*from); err != nil {
+			return fmt.Errorf("sender address: %w", err)
+		}
+	}
+
+	if !comment.IsEncrypted(body) {
+		return printPlain(body)
+	}
+	if sender == nil {
+		return fmt.Errorf("-from is required for a message body")
+	}
+
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	text, err := comment.Decrypt(body, sender, keyPair.PrivateKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+	return nil
+}
+
+func printPlain(body *cell.Cell) error {
+	text, err := comment.Decode(body)
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+	return nil
+}
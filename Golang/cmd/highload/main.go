@@ -0,0 +1,457 @@
+// Command highload sends TON from the highload wallet v2 in the config (see
+// Chapter 5) with query_ids from a journal, and checks what became of them.
+//
+//	go run ./cmd/highload send -to <address> -amount 0.1 -comment "hello" [-to ... -amount ...]
+//	go run ./cmd/highload batch -file payouts.csv [-dry-run] [-wait 1m]
+//	go run ./cmd/highload resume -file payouts.csv [-wait 1m]
+//	go run ./cmd/highload queries [-reconcile] [-prune 24h]
+//	go run ./cmd/highload state
+//
+// The journal (-journal, highload-queries.json by default) is a file with
+// every query_id issued for the wallet, see highload.Journal. queries prints
+// them; with -reconcile it first asks the wallet with processed? about the
+// pending ones, with -prune it removes the ones which expired longer ago.
+//
+// payouts.csv has one payout per line: "address,amount,comment", for example
+// "EQ...,1.5,salary". batch splits any number of them into queries which fit
+// into an external message (see highload.Split), sends every one with its own
+// query_id and prints what happened to each; -dry-run only prints the split.
+// The chunks, their lines of the file and query_ids, are kept in a plan next
+// to the journal, payouts.csv.plan.json (see highload.Plan), and batch
+// refuses a file which already has one. A chunk which failed may still reach
+// the wallet, so it is sent again only once its query_id has expired: resume
+// asks the wallet about every chunk of the plan and sends the expired ones,
+// and the ones batch never got to. The file must not change in between.
+//
+// state prints the data of the wallet: its subwallet ID, last_cleaned and
+// every query_id of old_queries with its expiration time and random part,
+// and the note of the journal for the ones it issued.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/highload"
+	"main/message"
+	"main/signer"
+)
+
+const defaultJournal = detect.HighloadJournal // the one cmd/send uses too
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "send":
+		err = send(cfg, os.Args[2:])
+	case "batch":
+		err = batch(cfg, os.Args[2:])
+	case "resume":
+		err = resume(cfg, os.Args[2:])
+	case "queries":
+		err = queries(cfg, os.Args[2:])
+	case "state":
+		err = state(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: highload send|batch|resume|queries|state [flags]")
+	os.Exit(2)
+}
+
+func send(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var to, amounts, texts cli.List
+	fs.Var(&to, "to", "destination address, repeat for more messages")
+	fs.Var(&amounts, "amount", "TON to send, one per -to")
+	fs.Var(&texts, "comment", "text comment, one per -to or none")
+	journalPath := fs.String("journal", defaultJournal, "journal of the query_ids")
+	_ = fs.Parse(args)
+	if len(to) == 0 || len(amounts) != len(to) || (len(texts) != 0 && len(texts) != len(to)) {
+		usage()
+	}
+	if len(to) > highload.MaxMessages {
+		return highload.ErrTooManyMessages
+	}
+
+	var messages []message.Out
+	for i := range to {
+		text := ""
+		if len(texts) != 0 {
+			text = texts[i]
+		}
+		m, err := payout(cfg, to[i], amounts[i], text)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, m)
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Expect(ctx, client, walletAddress, detect.HighloadV2, detect.HighloadV2R2)
+	if err != nil {
+		return err
+	}
+
+	// The query_id is recorded before the message is sent: if the send fails,
+	// it stays pending until it expires and is never issued again
+	journal := highload.OpenJournal(*journalPath, walletAddress)
+	queryID, err := journal.Issue(ctx, cfg.Timeouts.ValidFor, fmt.Sprintf("%d messages", len(messages)))
+	if err != nil {
+		return err
+	}
+
+	q := highload.Query{
+		SubwalletID: wallet.(*detect.HighloadWalletV2).SubwalletID,
+		QueryID:     queryID,
+		Messages:    messages,
+	}
+	externalMessage, err := q.External(ctx, signer.FromKeyPair(keyPair), walletAddress)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Printf("Sent %d messages with query_id %d", len(messages), queryID)
+	return nil
+}
+
+// payout returns the message which sends amount TON with a text comment to to.
+func payout(cfg *config.Config, to, amount, text string) (message.Out, error) {
+	destination, err := address.ParseAddr(to)
+	if err != nil {
+		return message.Out{}, fmt.Errorf("address %s: %w", to, err)
+	}
+	value, err := tlb.FromTON(amount)
+	if err != nil {
+		return message.Out{}, fmt.Errorf("amount %s: %w", amount, err)
+	}
+	if err = cfg.CheckAmount(value); err != nil {
+		return message.Out{}, err
+	}
+	var body *cell.Cell
+	if text != "" {
+		if body, err = comment.Text(text); err != nil {
+			return message.Out{}, err
+		}
+	}
+
+	internalMessage, err := (&message.Internal{
+		Bounce:  destination.IsBounceable(),
+		Dest:    destination,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return message.Out{}, err
+	}
+	return message.Out{Mode: message.ModeDefault, Message: internalMessage}, nil
+}
+
+func batch(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	file := fs.String("file", "", `CSV file with one "address,amount,comment" per payout, the comment may be empty`)
+	journalPath := fs.String("journal", defaultJournal, "journal of the query_ids")
+	dryRun := fs.Bool("dry-run", false, "print the chunks without sending them")
+	wait := fs.Duration("wait", 0, "wait this long after sending, then print what the wallet did with every chunk")
+	_ = fs.Parse(args)
+	if *file == "" {
+		usage()
+	}
+
+	messages, sum, err := readPayouts(cfg, *file)
+	if err != nil {
+		return err
+	}
+	chunks, err := highload.Split(messages)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		printChunks(chunks)
+		return nil
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	planPath := planFile(*journalPath, *file)
+	if _, err = os.Stat(planPath); err == nil {
+		return fmt.Errorf("%s was already sent, see %s: use resume to send its failed chunks", *file, planPath)
+	}
+	plan := highload.NewPlan(walletAddress, *file, sum, chunks)
+	return sendChunks(cfg, *journalPath, planPath, plan, chunks, false, *wait)
+}
+
+func resume(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	file := fs.String("file", "", "CSV file given to batch, unchanged")
+	journalPath := fs.String("journal", defaultJournal, "journal of the query_ids")
+	wait := fs.Duration("wait", 0, "wait this long after sending, then print what the wallet did with every chunk")
+	_ = fs.Parse(args)
+	if *file == "" {
+		usage()
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	planPath := planFile(*journalPath, *file)
+	plan, err := highload.LoadPlan(planPath, walletAddress)
+	if err != nil {
+		return err
+	}
+	messages, sum, err := readPayouts(cfg, *file)
+	if err != nil {
+		return err
+	}
+	if sum != plan.SHA256 {
+		return fmt.Errorf("%w: %s changed after batch", highload.ErrPlanChanged, *file)
+	}
+	chunks, err := highload.Split(messages)
+	if err != nil {
+		return err
+	}
+	if err = plan.Apply(chunks); err != nil {
+		return err
+	}
+	return sendChunks(cfg, *journalPath, planPath, plan, chunks, true, *wait)
+}
+
+// planFile returns where the plan of the batch in file is kept: next to the journal.
+func planFile(journalPath, file string) string {
+	return filepath.Join(filepath.Dir(journalPath), filepath.Base(file)+".plan.json")
+}
+
+// readPayouts returns the messages of the payouts in the CSV file and its SHA-256 sum.
+func readPayouts(cfg *config.Config, file string) ([]message.Out, string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", file, err)
+	}
+
+	var messages []message.Out
+	for i, record := range records {
+		m, err := payout(cfg, record[0], record[1], record[2])
+		if err != nil {
+			return nil, "", fmt.Errorf("%s line %d: %w", file, i+1, err)
+		}
+		messages = append(messages, m)
+	}
+	sum := sha256.Sum256(data)
+	return messages, hex.EncodeToString(sum[:]), nil
+}
+
+// sendChunks sends the chunks which need it, keeping plan in the file at
+// planPath. With refresh it first asks the wallet what became of the chunks
+// sent before, so only the ones whose query_ids expired are sent again.
+func sendChunks(cfg *config.Config, journalPath, planPath string, plan *highload.Plan, chunks []*highload.Chunk, refresh bool, wait time.Duration) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	// Every chunk is one request to the liteserver
+	requests := cfg.Timeouts.Request * time.Duration(len(chunks)+1)
+	ctx, cancel := context.WithTimeout(context.Background(), requests)
+	defer cancel()
+
+	wallet, err := detect.Expect(ctx, client, walletAddress, detect.HighloadV2, detect.HighloadV2R2)
+	if err != nil {
+		return err
+	}
+	save := func(chunks []*highload.Chunk) error {
+		plan.Record(chunks)
+		return highload.SavePlan(planPath, plan)
+	}
+	sender := highload.BatchSender{
+		Client:      client.Client(),
+		Journal:     highload.OpenJournal(journalPath, walletAddress),
+		Signer:      signer.FromKeyPair(keyPair),
+		Wallet:      walletAddress,
+		SubwalletID: wallet.(*detect.HighloadWalletV2).SubwalletID,
+		Timeout:     cfg.Timeouts.ValidFor,
+		Save:        save,
+	}
+
+	if refresh {
+		if err = sender.Refresh(ctx, client, chunks); err != nil {
+			return err
+		}
+	}
+	if err = save(chunks); err != nil {
+		return err
+	}
+	failed := sender.Send(ctx, chunks)
+
+	if wait > 0 {
+		time.Sleep(wait)
+		refreshCtx, refreshCancel := context.WithTimeout(context.Background(), requests)
+		defer refreshCancel()
+		if err = sender.Refresh(refreshCtx, client, chunks); err != nil {
+			return err
+		}
+	}
+	if err = save(chunks); err != nil {
+		return err
+	}
+	printChunks(chunks)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d chunks were not sent: run resume with the same -file after their query_ids expire, it sends only the expired ones", failed, len(chunks))
+	}
+	return nil
+}
+
+func printChunks(chunks []*highload.Chunk) {
+	for i, c := range chunks {
+		status := string(c.Status)
+		if c.Err != nil {
+			status = "failed: " + c.Err.Error()
+		}
+		fmt.Printf("chunk %d\t%d messages\t%d cells\t~%d gas\tquery_id %d\t%s\n", i+1, len(c.Messages), c.Cells, c.Gas, c.QueryID, status)
+	}
+}
+
+func queries(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("queries", flag.ExitOnError)
+	journalPath := fs.String("journal", defaultJournal, "journal of the query_ids")
+	reconcile := fs.Bool("reconcile", false, "ask the wallet about the pending query_ids first")
+	prune := fs.Duration("prune", 0, "remove the processed and expired query_ids which expired longer ago")
+	_ = fs.Parse(args)
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	journal := highload.OpenJournal(*journalPath, walletAddress)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	if *reconcile {
+		client, err := cli.Connect(cfg)
+		if err != nil {
+			return err
+		}
+		if _, err = journal.Reconcile(ctx, client, walletAddress); err != nil {
+			return err
+		}
+	}
+	if *prune > 0 {
+		removed, err := journal.Prune(ctx, time.Now().Add(-*prune))
+		if err != nil {
+			return err
+		}
+		log.Printf("Removed %d query_ids", removed)
+	}
+
+	records, err := journal.Records()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		fmt.Printf("%d\t%s\texpires %s\t%s\n", r.QueryID, r.Status, r.ExpireAt().UTC().Format(time.RFC3339), r.Note)
+	}
+	return nil
+}
+
+func state(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("state", flag.ExitOnError)
+	journalPath := fs.String("journal", defaultJournal, "journal of the query_ids, for their notes")
+	_ = fs.Parse(args)
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	walletState, err := highload.GetState(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+
+	// The journal is optional, the wallet may have been used without it
+	notes := map[uint64]string{}
+	if records, err := highload.OpenJournal(*journalPath, walletAddress).Records(); err == nil {
+		for _, r := range records {
+			notes[r.QueryID] = r.Note
+		}
+	}
+
+	lastCleaned := highload.SplitQueryID(walletState.LastCleaned)
+	fmt.Println("Subwallet ID:", walletState.SubwalletID)
+	fmt.Printf("Public key: %x\n", walletState.PublicKey)
+	fmt.Printf("Last cleaned: %d (expired %s)\n", lastCleaned.QueryID, lastCleaned.ExpireAt.UTC().Format(time.RFC3339))
+	fmt.Printf("Old queries: %d\n", len(walletState.OldQueries))
+	for _, q := range walletState.OldQueries {
+		fmt.Printf("%d\texpires %s\trandom %d\t%s\n", q.QueryID, q.ExpireAt.UTC().Format(time.RFC3339), q.Random, notes[q.QueryID])
+	}
+	return nil
+}
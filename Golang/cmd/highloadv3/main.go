@@ -0,0 +1,342 @@
+// Command highloadv3 deploys a highload wallet V3 (Chapter 6) for the key and
+// the subwallet ID in the config, sends TON from it and checks its queries.
+//
+//	go run ./cmd/highloadv3 address [-timeout 128]
+//	go run ./cmd/highloadv3 deploy [-timeout 128]
+//	go run ./cmd/highloadv3 info
+//	go run ./cmd/highloadv3 send -to <address> -amount 0.1 -comment "hello" [-to ... -amount ...] [-query 42] [-value 0.05]
+//	go run ./cmd/highloadv3 processed -query 42
+//
+// The timeout is a part of the address like the subwallet ID, so address and
+// deploy take the same -timeout, longer than a minute. deploy takes its
+// query_id from the journal like send. The other commands work with the wallet from
+// the config and read its subwallet ID and timeout with get methods. -query is
+// the sequence number of the query_id (see highloadv3.QueryID); without it
+// send takes the next one from the journal (-journal, highloadv3-queries.json
+// by default, see highloadv3.Journal) and prints it. -value is the TON sent
+// with every internal_transfer, highloadv3.TransferValue of the messages by default.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/highloadv3"
+	"main/message"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "address":
+		err = printAddress(cfg, os.Args[2:])
+	case "deploy":
+		err = deploy(cfg, os.Args[2:])
+	case "info":
+		err = info(cfg)
+	case "send":
+		err = send(cfg, os.Args[2:])
+	case "processed":
+		err = processed(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: highloadv3 address|deploy|info|send|processed [flags]")
+	os.Exit(2)
+}
+
+// queryID returns the query_id with sequence number seqno.
+func queryID(seqno int64) (highloadv3.QueryID, error) {
+	if seqno > highloadv3.MaxSeqno {
+		return highloadv3.QueryID{}, fmt.Errorf("-query is at most %d", highloadv3.MaxSeqno)
+	}
+	return highloadv3.QueryIDFromSeqno(uint32(seqno))
+}
+
+// timeoutFlag checks -timeout before it is cut to the 32 bits of the wallet.
+func timeoutFlag(timeout uint) (uint32, error) {
+	if timeout > highloadv3.MaxTimeout {
+		return 0, fmt.Errorf("-timeout: %w", highloadv3.ErrTimeout)
+	}
+	return uint32(timeout), nil
+}
+
+func printAddress(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("address", flag.ExitOnError)
+	timeoutSeconds := fs.Uint("timeout", highloadv3.DefaultTimeout, "seconds a message stays valid, above 60")
+	_ = fs.Parse(args)
+
+	timeout, err := timeoutFlag(*timeoutSeconds)
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	walletAddress, err := highloadv3.Address(keyPair.PublicKey, cfg.SubwalletID, timeout)
+	if err != nil {
+		return err
+	}
+	walletAddress.SetBounce(false) // send the first TON to it non-bounceable, it is not deployed yet
+	fmt.Println(cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func deploy(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	timeoutSeconds := fs.Uint("timeout", highloadv3.DefaultTimeout, "seconds a message stays valid, above 60")
+	journalPath := fs.String("journal", detect.HighloadV3Journal, "journal of the query_ids")
+	_ = fs.Parse(args)
+
+	timeout, err := timeoutFlag(*timeoutSeconds)
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	stateInit, err := highloadv3.StateInit(keyPair.PublicKey, cfg.SubwalletID, timeout)
+	if err != nil {
+		return err
+	}
+	walletAddress := address.NewAddress(0, 0, stateInit.Hash())
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// The deploy takes its query_id from the journal too, else the first send
+	// would take the same one and the wallet would refuse it
+	queryID, err := highloadv3.OpenJournal(*journalPath, walletAddress, timeout).Issue(ctx)
+	if err != nil {
+		return err
+	}
+	// Like the deploy of wallet V3 in Chapter 3, a request which sends nothing:
+	// an internal_transfer without actions, with no TON, to the wallet itself
+	body, err := highloadv3.InternalTransferBody(queryID, nil)
+	if err != nil {
+		return err
+	}
+	internalMessage, err := (&message.Internal{Dest: walletAddress, Body: body}).ToCell()
+	if err != nil {
+		return err
+	}
+	m := highloadv3.Message{
+		SubwalletID: cfg.SubwalletID,
+		Message:     internalMessage,
+		Mode:        message.ModeDefault,
+		QueryID:     queryID,
+		CreatedAt:   time.Now().Add(-highloadv3.CreatedAtLag),
+		Timeout:     timeout,
+	}
+	externalMessage, err := m.External(ctx, signer.FromKeyPair(keyPair), walletAddress, stateInit)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Deployed", cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func info(cfg *config.Config) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	subwalletID, err := highloadv3.GetSubwalletID(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	timeout, err := highloadv3.GetTimeout(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	lastClean, err := highloadv3.GetLastCleanTime(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Subwallet ID:", subwalletID)
+	fmt.Println("Timeout:", time.Duration(timeout)*time.Second)
+	fmt.Println("Last clean:", lastClean.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func send(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var to, amounts, texts cli.List
+	fs.Var(&to, "to", "destination address, repeat for more messages")
+	fs.Var(&amounts, "amount", "TON to send, one per -to")
+	fs.Var(&texts, "comment", "text comment, one per -to or none")
+	seqno := fs.Int64("query", -1, "sequence number of the query_id, the next one from the journal if not set")
+	journalPath := fs.String("journal", detect.HighloadV3Journal, "journal of the query_ids")
+	value := fs.String("value", "", "TON sent with internal_transfer, the wallet sends it to itself")
+	_ = fs.Parse(args)
+	if len(to) == 0 || len(amounts) != len(to) || (len(texts) != 0 && len(texts) != len(to)) {
+		usage()
+	}
+
+	transferValue := highloadv3.TransferValue(len(to))
+	if *value != "" {
+		v, err := tlb.FromTON(*value)
+		if err != nil {
+			return fmt.Errorf("-value: %w", err)
+		}
+		transferValue = v
+	}
+	if err := cfg.CheckFeeValue(transferValue); err != nil {
+		return err
+	}
+
+	var messages []message.Out
+	for i := range to {
+		destination, err := address.ParseAddr(to[i])
+		if err != nil {
+			return fmt.Errorf("-to %s: %w", to[i], err)
+		}
+		amount, err := tlb.FromTON(amounts[i])
+		if err != nil {
+			return fmt.Errorf("-amount %s: %w", amounts[i], err)
+		}
+		if err = cfg.CheckAmount(amount); err != nil {
+			return err
+		}
+		var body *cell.Cell
+		if len(texts) != 0 && texts[i] != "" {
+			if body, err = comment.Text(texts[i]); err != nil {
+				return err
+			}
+		}
+
+		internalMessage, err := (&message.Internal{
+			Bounce:  destination.IsBounceable(),
+			Dest:    destination,
+			Value:   amount,
+			Body:    body,
+			BodyRef: true,
+		}).ToCell()
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message.Out{Mode: message.ModeDefault, Message: internalMessage})
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Expect(ctx, client, walletAddress, detect.HighloadV3)
+	if err != nil {
+		return err
+	}
+	highloadWallet := wallet.(*detect.HighloadWalletV3) // the subwallet ID and the timeout must be the ones of the wallet
+
+	var q highloadv3.QueryID
+	if *seqno >= 0 {
+		q, err = queryID(*seqno)
+	} else {
+		q, err = highloadv3.OpenJournal(*journalPath, walletAddress, highloadWallet.Timeout).Issue(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	b := highloadv3.Batch{
+		SubwalletID: highloadWallet.SubwalletID,
+		Timeout:     highloadWallet.Timeout,
+		QueryID:     q,
+		Value:       transferValue,
+		Mode:        message.FlagPayFeesSeparately, // as in Chapter 6
+		Messages:    messages,
+	}
+	externalMessage, err := b.External(ctx, signer.FromKeyPair(keyPair), walletAddress, nil)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Printf("Sent %d messages with query_id %s, check it with: processed -query %d", len(messages), q, q.Seqno())
+	return nil
+}
+
+func processed(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("processed", flag.ExitOnError)
+	seqno := fs.Int64("query", -1, "sequence number of the query_id")
+	_ = fs.Parse(args)
+	if *seqno < 0 {
+		usage()
+	}
+
+	q, err := queryID(*seqno)
+	if err != nil {
+		return err
+	}
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	ok, err := highloadv3.IsProcessed(ctx, client, walletAddress, q, true)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ok)
+	return nil
+}
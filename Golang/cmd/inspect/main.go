@@ -0,0 +1,93 @@
+// Command inspect prints what a signed external message asks a wallet to do,
+// like the base64 BOC Chapter 2 logs before it sends it:
+//
+//	go run ./cmd/inspect te6cc...
+//	go run ./cmd/inspect -pubkey <hex> message.boc
+//
+// The message can be base64, hex, or a file with either or with the raw BOC.
+// With -pubkey or -key the signature is checked too, and the command fails if it
+// is not made by that key. The keystore and the key default to the ones in the
+// config (see package config), so a reviewer can run it without flags.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"main/config"
+	"main/inspect"
+	"main/keystore"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	publicKeyHex := flag.String("pubkey", "", "public key in hex to verify the signature with")
+	keystorePath := flag.String("file", cfg.Keystore, "keystore file")
+	keyName := flag.String("key", cfg.Key, "name of the key in the keystore, instead of -pubkey")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatalln("usage: inspect [-pubkey hex | -key name] <base64, hex or file>")
+	}
+
+	externalMessage, err := inspect.ParseBOC(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	decoded, err := inspect.Decode(externalMessage)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Print(decoded.Describe(cfg.FormatAddress))
+	if decoded.ExpiresAt().Before(time.Now()) {
+		fmt.Println("Expired:      the wallet will not accept it any more")
+	}
+
+	if *publicKeyHex != "" {
+		*keyName = "" // -pubkey wins over the key from the config
+	}
+	publicKey, err := loadPublicKey(*publicKeyHex, *keystorePath, *keyName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if publicKey == nil {
+		fmt.Println("Signed by:    not checked, pass -pubkey or -key")
+		return
+	}
+
+	if !decoded.Verify(publicKey) {
+		fmt.Println("Signed by:    NOT", hex.EncodeToString(publicKey))
+		os.Exit(1)
+	}
+	fmt.Println("Signed by:   ", hex.EncodeToString(publicKey))
+}
+
+// loadPublicKey returns nil if neither -pubkey nor a key is given.
+func loadPublicKey(publicKeyHex, keystorePath, keyName string) (ed25519.PublicKey, error) {
+	if keyName != "" {
+		ks, err := keystore.Open(keystorePath)
+		if err != nil {
+			return nil, err
+		}
+		return ks.PublicKey(keyName) // the public key is stored unencrypted, no passphrase needed
+	}
+	if publicKeyHex == "" {
+		return nil, nil
+	}
+
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("-pubkey must be %d bytes in hex", ed25519.PublicKeySize)
+	}
+	return publicKey, nil
+}
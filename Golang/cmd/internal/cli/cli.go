@@ -0,0 +1,28 @@
+// Package cli has what the commands share: the connection to the
+// liteservers of the config and a flag which may be repeated.
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"main/config"
+)
+
+// Connect returns a client of the liteservers in the config.
+func Connect(cfg *config.Config) (*ton.APIClient, error) {
+	connection := liteclient.NewConnectionPool()
+	if err := cfg.AddConnections(context.Background(), connection); err != nil {
+		return nil, err
+	}
+	return ton.NewAPIClient(connection), nil
+}
+
+// List is a flag which may be repeated, for example -to a -to b.
+type List []string
+
+func (l *List) String() string     { return strings.Join(*l, ",") }
+func (l *List) Set(v string) error { *l = append(*l, v); return nil }
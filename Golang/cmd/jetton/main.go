@@ -0,0 +1,262 @@
+// Command jetton sends and reads jettons of the wallet in the config.
+//
+//	go run ./cmd/jetton balance -master <jetton master>
+//	go run ./cmd/jetton transfer -master <jetton master> -to <owner> -amount 12.5 -decimals 6 -comment "invoice 42"
+//	go run ./cmd/jetton burn -master <jetton master> -amount 1
+//
+// -master is the address of the jetton, for example the USDT master; its
+// jetton wallet for our wallet is found with get_wallet_address. -decimals is
+// the one of the jetton metadata, 6 for USDT and 9 for most others.
+// The wallet, the key and the liteservers are the ones in the config (see
+// package config). The wallet may be any version package detect knows; a
+// highload wallet takes its query_ids from the journal of cmd/highload or
+// cmd/highloadv3 (see detect.UseJournal).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/jetton"
+	"main/message"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "balance":
+		err = balance(cfg, os.Args[2:])
+	case "transfer":
+		err = transfer(cfg, os.Args[2:])
+	case "burn":
+		err = burn(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: jetton balance|transfer|burn -master <address> [flags]")
+	os.Exit(2)
+}
+
+// flags is what every subcommand takes.
+type flags struct {
+	fs       *flag.FlagSet
+	master   *string
+	decimals *int
+}
+
+func newFlags(name string) *flags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return &flags{
+		fs:       fs,
+		master:   fs.String("master", "", "jetton master address"),
+		decimals: fs.Int("decimals", 9, "decimals of the jetton, 6 for USDT"),
+	}
+}
+
+// setup parses the flags and connects to the liteservers.
+func (f *flags) setup(cfg *config.Config, args []string) (*address.Address, *ton.APIClient, error) {
+	_ = f.fs.Parse(args)
+	if *f.master == "" {
+		return nil, nil, fmt.Errorf("-master is required")
+	}
+	master, err := address.ParseAddr(*f.master)
+	if err != nil {
+		return nil, nil, fmt.Errorf("master address: %w", err)
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return master, client, nil
+}
+
+func balance(cfg *config.Config, args []string) error {
+	f := newFlags("balance")
+	owner := f.fs.String("owner", cfg.Wallet, "owner wallet address")
+	master, client, err := f.setup(cfg, args)
+	if err != nil {
+		return err
+	}
+	ownerAddress, err := address.ParseAddr(*owner)
+	if err != nil {
+		return fmt.Errorf("owner address: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	jettonWallet, err := jetton.WalletAddress(ctx, client, master, ownerAddress)
+	if err != nil {
+		return err
+	}
+	units, err := jetton.Balance(ctx, client, master, ownerAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Jetton wallet:", cfg.FormatAddress(jettonWallet))
+	fmt.Println("Balance:      ", jetton.FormatAmount(units, *f.decimals))
+	return nil
+}
+
+func transfer(cfg *config.Config, args []string) error {
+	f := newFlags("transfer")
+	to := f.fs.String("to", "", "receiver wallet address (the owner, not its jetton wallet)")
+	amount := f.fs.String("amount", "", "amount of jettons, for example 12.5")
+	text := f.fs.String("comment", "", "comment for the receiver")
+	forward := f.fs.String("forward", "0.000000001", "TON forwarded to the receiver with the notification, which carries the comment")
+	attach := f.fs.String("ton", "0.05", "TON sent to our jetton wallet for the fees, the rest comes back")
+	master, client, err := f.setup(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	receiver, err := address.ParseAddr(*to)
+	if err != nil {
+		return fmt.Errorf("receiver address: %w", err)
+	}
+	units, err := jetton.ParseAmount(*amount, *f.decimals)
+	if err != nil {
+		return err
+	}
+	forwardAmount, err := tlb.FromTON(*forward)
+	if err != nil {
+		return fmt.Errorf("-forward: %w", err)
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+
+	var forwardPayload *cell.Cell
+	if *text != "" {
+		if forwardPayload, err = comment.Text(*text); err != nil {
+			return err
+		}
+	}
+
+	body, err := (&jetton.Transfer{
+		QueryID:             uint64(time.Now().UnixNano()), // comes back in the excesses, to match them with this message
+		Amount:              units,
+		Destination:         receiver,
+		ResponseDestination: walletAddress, // the TON not spent on fees comes back
+		ForwardTONAmount:    forwardAmount,
+		ForwardPayload:      forwardPayload,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sending %s jettons to %s", jetton.FormatAmount(units, *f.decimals), cfg.FormatAddress(receiver))
+	return send(cfg, client, master, walletAddress, *attach, body)
+}
+
+func burn(cfg *config.Config, args []string) error {
+	f := newFlags("burn")
+	amount := f.fs.String("amount", "", "amount of jettons to burn")
+	attach := f.fs.String("ton", "0.05", "TON sent to our jetton wallet for the fees, the rest comes back")
+	master, client, err := f.setup(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	units, err := jetton.ParseAmount(*amount, *f.decimals)
+	if err != nil {
+		return err
+	}
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+
+	body, err := (&jetton.Burn{
+		QueryID:             uint64(time.Now().UnixNano()), // comes back in the excesses, to match them with this message
+		Amount:              units,
+		ResponseDestination: walletAddress,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Burning %s jettons", jetton.FormatAmount(units, *f.decimals))
+	return send(cfg, client, master, walletAddress, *attach, body)
+}
+
+// send sends body from our wallet to our jetton wallet of master with attach TON.
+func send(cfg *config.Config, client *ton.APIClient, master, walletAddress *address.Address, attach string, body *cell.Cell) error {
+	value, err := tlb.FromTON(attach)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(value); err != nil { // the TON only pays the fees, fees.max_fee_value limits it
+		return err
+	}
+
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	jettonWallet, err := jetton.WalletAddress(ctx, client, master, walletAddress)
+	if err != nil {
+		return err
+	}
+	wallet, err := detect.Detect(ctx, client, walletAddress) // the body of the transfer depends on the wallet
+	if err != nil {
+		return err
+	}
+	detect.UseJournal(wallet, "")
+
+	internalMessage, err := (&message.Internal{
+		Bounce:  true, // our jetton wallet is deployed, if not the TON comes back
+		Dest:    jettonWallet,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+
+	messages := []message.Out{{Mode: message.ModeDefault, Message: internalMessage}}
+	externalMessage, err := wallet.Transfer(ctx, signer.FromKeyPair(keyPair), messages, cfg.ValidUntil())
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Sent through jetton wallet", cfg.FormatAddress(jettonWallet))
+	return nil
+}
@@ -0,0 +1,182 @@
+// Command keystore manages the encrypted keystore used by the examples.
+//
+//	go run ./cmd/keystore [-file keystore.json] <command> [arguments]
+//
+// Commands:
+//
+//	list                   show names and public keys
+//	new <name>             generate a new mnemonic, print it once and store it
+//	add <name>             read an existing mnemonic from stdin and store it
+//	passwd <name>          change the passphrase of a key
+//	mnemonic <name>        print the mnemonic of a key
+//	export <name> <file>   write one encrypted key to a file
+//	import <file> [name]   add a key written by export
+//	remove <name>          delete a key
+//
+// The passphrase is read from TON_KEYSTORE_PASSPHRASE, or asked for when it is not set;
+// a new passphrase is asked for twice. Secrets are typed without echo on a terminal.
+// The file defaults to keystore in the config (see package config).
+// Use -with-password to protect a new mnemonic with a password, which is asked
+// for too; add asks for it when the mnemonic needs one. import asks for the
+// passphrase the key was exported with, the key keeps it: change it with passwd.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"main/config"
+	"main/keys"
+	"main/keystore"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	path := flag.String("file", cfg.Keystore, "keystore file")
+	withPassword := flag.Bool("with-password", false, "protect the mnemonic made by new with a password")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ks, err := keystore.Open(*path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch cmd := args[0]; {
+	case cmd == "list":
+		for _, name := range ks.Names() {
+			publicKey, err := ks.PublicKey(name)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			fmt.Printf("%s\t%s\n", name, hex.EncodeToString(publicKey))
+		}
+	case cmd == "new" && len(args) == 2:
+		mnemonicPassword := ""
+		if *withPassword {
+			mnemonicPassword = newSecret("Mnemonic password: ")
+		}
+		mnemonic, err := keys.NewMnemonicWithPassword(mnemonicPassword)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err = ks.Add(args[1], mnemonic, mnemonicPassword, newPassphrase()); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("Write the mnemonic down, it is not shown again:")
+		fmt.Println(strings.Join(mnemonic, " "))
+	case cmd == "add" && len(args) == 2:
+		mnemonic := keys.ParseMnemonic(readSecret("Mnemonic: "))
+		mnemonicPassword := ""
+		if keys.IsPasswordNeeded(mnemonic) { // the words tell it, see package keys
+			mnemonicPassword = readSecret("Mnemonic password: ")
+		}
+		if err = ks.Add(args[1], mnemonic, mnemonicPassword, newPassphrase()); err != nil {
+			log.Fatalln(err)
+		}
+	case cmd == "passwd" && len(args) == 2:
+		oldPassphrase := passphrase("Current passphrase: ")
+		if err = ks.ChangePassphrase(args[1], oldPassphrase, newSecret("New passphrase: ")); err != nil {
+			log.Fatalln(err)
+		}
+	case cmd == "mnemonic" && len(args) == 2:
+		mnemonic, password, err := ks.Mnemonic(args[1], passphrase("Passphrase: "))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(strings.Join(mnemonic, " "))
+		if password != "" {
+			fmt.Println("Mnemonic password:", password)
+		}
+	case cmd == "export" && len(args) == 3:
+		data, err := ks.Export(args[1])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err = os.WriteFile(args[2], data, 0o600); err != nil {
+			log.Fatalln(err)
+		}
+	case cmd == "import" && (len(args) == 2 || len(args) == 3):
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		name := ""
+		if len(args) == 3 {
+			name = args[2]
+		}
+		if err = ks.Import(data, name, readSecret("Passphrase of the exported key: ")); err != nil {
+			log.Fatalln(err)
+		}
+	case cmd == "remove" && len(args) == 2:
+		if err = ks.Remove(args[1]); err != nil {
+			log.Fatalln(err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func passphrase(prompt string) string {
+	if p := os.Getenv(keystore.PassphraseEnv); p != "" {
+		return p
+	}
+	return readSecret(prompt)
+}
+
+// newPassphrase is passphrase for a key which is stored now: typed twice.
+func newPassphrase() string {
+	if p := os.Getenv(keystore.PassphraseEnv); p != "" {
+		return p
+	}
+	return newSecret("Passphrase: ")
+}
+
+// newSecret asks for a secret twice, a typo would lock the key for good.
+func newSecret(prompt string) string {
+	secret := readSecret(prompt)
+	if readSecret("Repeat "+strings.ToLower(prompt[:1])+prompt[1:]) != secret {
+		log.Fatalln("the two entries differ")
+	}
+	return secret
+}
+
+// readSecret reads a line without echo from a terminal, or a plain line
+// when stdin is a pipe.
+func readSecret(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return string(secret)
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalln(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,584 @@
+// Command multisig deploys a multisig wallet v2 and creates, approves and
+// reads its orders.
+//
+//	go run ./cmd/multisig address -code multisig.boc -signer <a> -signer <b> -signer <c> -threshold 2
+//	go run ./cmd/multisig deploy -code multisig.boc -signer <a> -signer <b> -signer <c> -threshold 2
+//	go run ./cmd/multisig info -multisig <multisig>
+//	go run ./cmd/multisig order -multisig <multisig> -to <address> -amount 100 [-comment "salary"] [-wait 30s]
+//	go run ./cmd/multisig approve -order <order> [-wait 30s]
+//	go run ./cmd/multisig status -order <order>
+//
+// -code is the compiled multisig.func of multisig-contract-v2, as a BOC file,
+// hex or base64; only address and deploy need it. The signers and proposers
+// are wallets: order and approve send from the wallet in the config, or from
+// -wallet, whatever wallet it is (see package detect), signed by the key
+// -key from the keystore or by a signer served by cmd/signer (-unix, -http with its token in TON_SIGNER_TOKEN).
+// deploy sends from the same wallet.
+//
+// An order whose transfers are above fees.max_amount is only created when
+// the multisig needs at least 2 approvals of at least 3 signers: the limit of
+// a single key does not hold for the treasury, its approvals do; an order
+// with a mode 64 or 128 message counts as above it. So deploy and order need
+// fees.max_amount in the config, and deploy refuses a weaker multisig unless
+// -weak is given. -wait reads the order until it is executed
+// or expired, for at most that long, and prints it.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/inspect"
+	"main/keystore"
+	"main/message"
+	"main/multisig"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "address", "deploy":
+		err = deploy(cfg, os.Args[1], os.Args[2:])
+	case "info":
+		err = info(cfg, os.Args[2:])
+	case "order":
+		err = order(cfg, os.Args[2:])
+	case "approve":
+		err = approve(cfg, os.Args[2:])
+	case "status":
+		err = status(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: multisig address|deploy|info|order|approve|status [flags]")
+	os.Exit(2)
+}
+
+func deploy(cfg *config.Config, action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	codeFlag := fs.String("code", "", "code of the multisig: BOC file, hex or base64")
+	var signers, proposers cli.List
+	fs.Var(&signers, "signer", "wallet of a signer, repeat for every signer in the order of their indexes")
+	fs.Var(&proposers, "proposer", "wallet which only creates orders, may be repeated")
+	threshold := fs.Uint("threshold", multisig.TreasuryThreshold, "approvals an order needs")
+	arbitrarySeqno := fs.Bool("arbitrary-seqno", false, "let the proposers choose the seqnos of the orders")
+	value := fs.String("ton", "0.5", "TON the multisig gets at deploy")
+	weak := fs.Bool("weak", false, "deploy a multisig below 2-of-3, which cannot send above fees.max_amount")
+	s := senderFlags(cfg, fs)
+	_ = fs.Parse(args)
+	if *codeFlag == "" || len(signers) == 0 {
+		usage()
+	}
+
+	code, err := inspect.ParseBOC(*codeFlag)
+	if err != nil {
+		return fmt.Errorf("-code: %w", err)
+	}
+	if *threshold > multisig.MaxSigners {
+		return multisig.ErrThreshold
+	}
+	p := &multisig.Params{Threshold: uint8(*threshold)}
+	if p.Signers, err = parseAddresses("-signer", signers); err != nil {
+		return err
+	}
+	if p.Proposers, err = parseAddresses("-proposer", proposers); err != nil {
+		return err
+	}
+	stateInit, err := multisig.StateInit(code, p, *arbitrarySeqno)
+	if err != nil {
+		return err
+	}
+	multisigAddress := address.NewAddress(0, 0, stateInit.Hash())
+	if action == "address" {
+		fmt.Println(cfg.FormatAddress(multisigAddress))
+		return nil
+	}
+
+	if err = cfg.RequireMaxAmount(); err != nil { // the treasury checks start from it
+		return err
+	}
+	if p.Threshold < multisig.TreasuryThreshold || len(p.Signers) < multisig.TreasurySigners {
+		weakness := fmt.Sprintf("the multisig is %d-of-%d, below %d-of-%d it cannot send above fees.max_amount",
+			p.Threshold, len(p.Signers), multisig.TreasuryThreshold, multisig.TreasurySigners)
+		if !*weak {
+			return errors.New(weakness + ", deploy it anyway with -weak")
+		}
+		log.Println("Deploying with -weak:", weakness)
+	}
+
+	amount, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckAmount(amount); err != nil {
+		return err
+	}
+	internalMessage, err := (&message.Internal{
+		Bounce:       false, // nothing to bounce from before the deploy
+		Dest:         multisigAddress,
+		Value:        amount,
+		StateInit:    stateInit,
+		Body:         multisig.DeployBody(),
+		StateInitRef: true,
+		BodyRef:      true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+
+	if err = s.send(cfg, internalMessage); err != nil {
+		return err
+	}
+	log.Println("Multisig:", cfg.FormatAddress(multisigAddress))
+	return nil
+}
+
+func info(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	multisigFlag := fs.String("multisig", "", "address of the multisig")
+	_ = fs.Parse(args)
+	if *multisigFlag == "" {
+		usage()
+	}
+
+	multisigAddress, err := address.ParseAddr(*multisigFlag)
+	if err != nil {
+		return fmt.Errorf("-multisig: %w", err)
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	state, err := multisig.GetState(ctx, client, multisigAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d-of-%d, next order seqno %s\n", state.Threshold, len(state.Signers), state.NextOrderSeqno)
+	for i, s := range state.Signers {
+		fmt.Printf("signer %d:   %s\n", i, cfg.FormatAddress(s))
+	}
+	for i, p := range state.Proposers {
+		fmt.Printf("proposer %d: %s\n", i, cfg.FormatAddress(p))
+	}
+	if err = state.CheckTreasury(); err != nil {
+		fmt.Println("It cannot send above fees.max_amount:", err)
+	}
+	return nil
+}
+
+func order(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("order", flag.ExitOnError)
+	multisigFlag := fs.String("multisig", "", "address of the multisig")
+	var to, amounts, texts cli.List
+	fs.Var(&to, "to", "destination address, repeat for more messages")
+	fs.Var(&amounts, "amount", "TON to send, one per -to")
+	fs.Var(&texts, "comment", "text comment, one per -to or none")
+	expire := fs.Duration("expire", 7*24*time.Hour, "time the signers have to approve the order")
+	value := fs.String("ton", "0.2", "TON sent with the order for its deploy and fees")
+	wait := fs.Duration("wait", 0, "wait at most this long for the order to be executed, then print it")
+	s := senderFlags(cfg, fs)
+	_ = fs.Parse(args)
+	if *multisigFlag == "" || len(to) == 0 || len(amounts) != len(to) || (len(texts) != 0 && len(texts) != len(to)) {
+		usage()
+	}
+
+	if err := cfg.RequireMaxAmount(); err != nil { // the treasury checks start from it
+		return err
+	}
+	multisigAddress, err := address.ParseAddr(*multisigFlag)
+	if err != nil {
+		return fmt.Errorf("-multisig: %w", err)
+	}
+	fee, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(fee); err != nil {
+		return err
+	}
+	var actions []*cell.Cell
+	for i := range to {
+		text := ""
+		if len(texts) != 0 {
+			text = texts[i]
+		}
+		action, err := transferAction(to[i], amounts[i], text)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+	orderCell, err := multisig.PackOrder(actions)
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	state, err := multisig.GetState(ctx, client, multisigAddress)
+	if err != nil {
+		return err
+	}
+	// Above the limit of one key, only a 2-of-3 treasury may send. A mode 128
+	// or 64 message may send more than its value, so it counts as above
+	parsed, err := multisig.ParseActions(orderCell)
+	if err != nil {
+		return err
+	}
+	total, unbounded := multisig.Total(parsed)
+	if unbounded || cfg.CheckAmount(total) != nil {
+		if err = state.CheckTreasury(); err != nil {
+			return err
+		}
+	}
+
+	walletAddress, err := s.walletAddress(cfg)
+	if err != nil {
+		return err
+	}
+	index, isSigner, err := state.Index(walletAddress)
+	if err != nil {
+		return err
+	}
+	// The seqno is the next one now: another order created meanwhile takes it
+	// and this one bounces, send it again then
+	orderSeqno := state.NextOrderSeqno
+	orderAddress, err := multisig.GetOrderAddress(ctx, client, multisigAddress, orderSeqno)
+	if err != nil {
+		return err
+	}
+
+	newOrder := multisig.NewOrder{
+		QueryID:    uint64(time.Now().UnixNano()),
+		OrderSeqno: orderSeqno,
+		IsSigner:   isSigner,
+		Index:      index,
+		ExpireAt:   time.Now().Add(*expire),
+		Order:      orderCell,
+	}
+	internalMessage, err := (&message.Internal{
+		Bounce:  true,
+		Dest:    multisigAddress,
+		Value:   fee,
+		Body:    newOrder.Body(),
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+	if err = s.send(cfg, internalMessage); err != nil {
+		return err
+	}
+	log.Printf("Order %s: %s TON in %d messages, %d approvals needed", orderSeqno, total.String(), len(actions), state.Threshold)
+	log.Println("Order address:", cfg.FormatAddress(orderAddress))
+	return waitAndPrint(cfg, client, orderAddress, *wait)
+}
+
+func approve(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	orderFlag := fs.String("order", "", "address of the order")
+	value := fs.String("ton", "0.1", "TON sent with the approval for the fees, the rest comes back")
+	wait := fs.Duration("wait", 0, "wait at most this long for the order to be executed, then print it")
+	s := senderFlags(cfg, fs)
+	_ = fs.Parse(args)
+	if *orderFlag == "" {
+		usage()
+	}
+
+	orderAddress, err := address.ParseAddr(*orderFlag)
+	if err != nil {
+		return fmt.Errorf("-order: %w", err)
+	}
+	fee, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(fee); err != nil {
+		return err
+	}
+	walletAddress, err := s.walletAddress(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// The order would reject these approvals, and keep the fee
+	o, err := multisig.GetOrder(ctx, client, orderAddress)
+	if err != nil {
+		return err
+	}
+	orderSigners := multisig.Params{Threshold: o.Threshold, Signers: o.Signers}
+	index, isSigner, err := orderSigners.Index(walletAddress)
+	if err != nil || !isSigner {
+		return fmt.Errorf("%s is not a signer of the order", cfg.FormatAddress(walletAddress))
+	}
+	switch {
+	case o.Executed:
+		return errors.New("the order is already executed")
+	case o.Expired():
+		return errors.New("the order expired at " + o.ExpireAt.UTC().Format(time.RFC3339))
+	case o.Approved(index):
+		return fmt.Errorf("signer %d already approved the order", index)
+	}
+
+	internalMessage, err := (&message.Internal{
+		Bounce:  true,
+		Dest:    orderAddress,
+		Value:   fee,
+		Body:    multisig.ApproveBody(index, uint64(time.Now().UnixNano())),
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+	if err = s.send(cfg, internalMessage); err != nil {
+		return err
+	}
+	log.Printf("Approved as signer %d, %d of %d approvals with this one", index, o.Approvals+1, o.Threshold)
+	return waitAndPrint(cfg, client, orderAddress, *wait)
+}
+
+func status(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	orderFlag := fs.String("order", "", "address of the order")
+	_ = fs.Parse(args)
+	if *orderFlag == "" {
+		usage()
+	}
+
+	orderAddress, err := address.ParseAddr(*orderFlag)
+	if err != nil {
+		return fmt.Errorf("-order: %w", err)
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	return waitAndPrint(cfg, client, orderAddress, 0)
+}
+
+// pollInterval is how often waitAndPrint reads the order, about a block.
+const pollInterval = 5 * time.Second
+
+// waitAndPrint reads the order at orderAddress every pollInterval until it is
+// executed or expired, for at most wait, then prints it. With wait 0 it reads
+// the order once.
+func waitAndPrint(cfg *config.Config, client *ton.APIClient, orderAddress *address.Address, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+		o, err := multisig.GetOrder(ctx, client, orderAddress)
+		cancel()
+		if err == nil && (o.Executed || o.Expired()) || time.Now().Add(pollInterval).After(deadline) {
+			if errors.Is(err, multisig.ErrNotInited) && wait > 0 {
+				fmt.Println("The order is not deployed yet, check again with status")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return printOrder(cfg, o)
+		}
+		// Until the order is deployed the get method fails, read it again
+		time.Sleep(pollInterval)
+	}
+}
+
+func printOrder(cfg *config.Config, o *multisig.Order) error {
+
+	state := "waiting for approvals"
+	switch {
+	case o.Executed:
+		state = "executed"
+	case o.Expired():
+		state = "expired"
+	}
+	fmt.Printf("Order %s of %s: %s, %d of %d approvals, expires %s\n", o.Seqno, cfg.FormatAddress(o.Multisig),
+		state, o.Approvals, o.Threshold, o.ExpireAt.UTC().Format(time.RFC3339))
+	for i, s := range o.Signers {
+		mark := " "
+		if o.Approved(uint8(i)) {
+			mark = "+"
+		}
+		fmt.Printf("  %s signer %d: %s\n", mark, i, cfg.FormatAddress(s))
+	}
+
+	actions, err := multisig.ParseActions(o.Actions)
+	if err != nil {
+		return err
+	}
+	for i, a := range actions {
+		switch {
+		case a.Message != nil:
+			fmt.Printf("  action %d: send %s TON to %s, mode %s\n", i, a.Message.Value.String(), cfg.FormatAddress(a.Message.Dest), a.Mode)
+		case a.Params != nil:
+			fmt.Printf("  action %d: change to %d-of-%d\n", i, a.Params.Threshold, len(a.Params.Signers))
+		default:
+			fmt.Printf("  action %d: unknown op %#x\n", i, a.Op)
+		}
+	}
+	return nil
+}
+
+func transferAction(to, amount, text string) (*cell.Cell, error) {
+	destination, err := address.ParseAddr(to)
+	if err != nil {
+		return nil, fmt.Errorf("-to %s: %w", to, err)
+	}
+	value, err := tlb.FromTON(amount)
+	if err != nil {
+		return nil, fmt.Errorf("-amount %s: %w", amount, err)
+	}
+	var body *cell.Cell
+	if text != "" {
+		if body, err = comment.Text(text); err != nil {
+			return nil, err
+		}
+	}
+	internalMessage, err := (&message.Internal{
+		Bounce:  destination.IsBounceable(),
+		Dest:    destination,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return nil, err
+	}
+	return multisig.SendMessage(message.ModeDefault, internalMessage)
+}
+
+func parseAddresses(name string, values []string) ([]*address.Address, error) {
+	var addresses []*address.Address
+	for _, v := range values {
+		addr, err := address.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", name, v, err)
+		}
+		addresses = append(addresses, addr)
+	}
+	return addresses, nil
+}
+
+// sender is the wallet of a signer or a proposer and where its key is.
+type sender struct {
+	wallet, key, unix, http *string
+}
+
+func senderFlags(cfg *config.Config, fs *flag.FlagSet) *sender {
+	return &sender{
+		wallet: fs.String("wallet", cfg.Wallet, "wallet of the signer or proposer"),
+		key:    fs.String("key", cfg.Key, "name of the key of -wallet in the keystore"),
+		unix:   fs.String("unix", "", "sign with the signer served on this Unix socket instead of -key"),
+		http:   fs.String("http", "", "sign with the signer served on this URL instead of -key, its token in "+signer.TokenEnv),
+	}
+}
+
+func (s *sender) walletAddress(cfg *config.Config) (*address.Address, error) {
+	if *s.wallet == "" {
+		return nil, config.ErrNoWallet
+	}
+	walletAddress, err := address.ParseAddr(*s.wallet)
+	if err != nil {
+		return nil, fmt.Errorf("-wallet: %w", err)
+	}
+	walletAddress.SetTestnetOnly(cfg.Testnet())
+	return walletAddress, nil
+}
+
+func (s *sender) signer(ctx context.Context, cfg *config.Config) (signer.Signer, error) {
+	switch {
+	case *s.unix != "":
+		return signer.NewUnixSigner(ctx, *s.unix)
+	case *s.http != "":
+		return signer.NewHTTPSigner(ctx, *s.http, os.Getenv(signer.TokenEnv), nil)
+	case *s.key == "":
+		return nil, config.ErrNoKey
+	}
+	keyPair, err := keystore.LoadKey(cfg.Keystore, *s.key, os.Getenv(keystore.PassphraseEnv))
+	if err != nil {
+		return nil, err
+	}
+	return signer.FromKeyPair(keyPair), nil
+}
+
+// send sends internalMessage from the wallet of s like cmd/send: the wallet
+// is detected and the key is checked before signing.
+func (s *sender) send(cfg *config.Config, internalMessage *cell.Cell) error {
+	walletAddress, err := s.walletAddress(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Detect(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	detect.UseJournal(wallet, "")
+	walletSigner, err := s.signer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(walletSigner.PublicKey(), wallet.PublicKey()) { // the wallet would reject the signature
+		return fmt.Errorf("the key is not the key of %s", cfg.FormatAddress(walletAddress))
+	}
+
+	messages := []message.Out{{Mode: message.ModeDefault, Message: internalMessage}}
+	externalMessage, err := wallet.Transfer(ctx, walletSigner, messages, cfg.ValidUntil())
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Printf("Sent from %s (%s)", cfg.FormatAddress(walletAddress), wallet.Version())
+	return nil
+}
@@ -0,0 +1,223 @@
+// Command nft reads NFT items and transfers them from the wallet in the config.
+//
+//	go run ./cmd/nft info <item>
+//	go run ./cmd/nft transfer -to <new owner> -comment "gift" <item> [<item>...]
+//	go run ./cmd/nft transfer -collection <collection> -to <new owner> <item> ... <item>
+//
+// transfer checks with get_nft_data that the wallet owns every item (and that
+// they are from -collection, if given) before anything is sent. The wallet in
+// the config is detected by its code (see package detect): a wallet V3 sends up
+// to 4 items at once, a highload wallet v2 (see Chapter 5) up to 254.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/message"
+	"main/nft"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "info":
+		err = info(cfg, os.Args[2:])
+	case "transfer":
+		err = transfer(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: nft info <item> | nft transfer -to <new owner> [flags] <item>...")
+	os.Exit(2)
+}
+
+func info(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+	item, err := address.ParseAddr(args[0])
+	if err != nil {
+		return fmt.Errorf("item address: %w", err)
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	data, err := nft.GetItemData(ctx, client, item)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Initialized:", data.Initialized)
+	fmt.Println("Index:      ", data.Index.String())
+	fmt.Println("Owner:      ", formatAddress(cfg, data.Owner))
+	fmt.Println("Collection: ", formatAddress(cfg, data.Collection))
+
+	fullContent, err := nft.FullContent(ctx, client, data)
+	if err != nil {
+		return err
+	}
+	content, err := nft.ParseContent(fullContent)
+	if err != nil {
+		return err
+	}
+	if content.URI != "" {
+		fmt.Println("URI:        ", content.URI)
+	}
+	for key, value := range content.OnChain {
+		fmt.Printf("On-chain %s: %q\n", key, value)
+	}
+
+	metadata, err := content.Metadata(ctx, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Name:       ", metadata.Name)
+	fmt.Println("Image:      ", metadata.Image)
+	for _, a := range metadata.Attributes {
+		fmt.Printf("  %s: %v\n", a.TraitType, a.Value)
+	}
+	return nil
+}
+
+func transfer(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("transfer", flag.ExitOnError)
+	to := fs.String("to", "", "new owner address")
+	collection := fs.String("collection", "", "refuse items which are not from this collection")
+	text := fs.String("comment", "", "comment for the new owner, sent with ownership_assigned")
+	forward := fs.String("forward", "0", "TON sent to the new owner with ownership_assigned, set it to deliver -comment")
+	value := fs.String("ton", "0.05", "TON sent to every item for the fees, the rest comes back")
+	_ = fs.Parse(args)
+	if *to == "" || fs.NArg() == 0 {
+		usage()
+	}
+
+	newOwner, err := address.ParseAddr(*to)
+	if err != nil {
+		return fmt.Errorf("new owner address: %w", err)
+	}
+	var collectionAddress *address.Address
+	if *collection != "" {
+		if collectionAddress, err = address.ParseAddr(*collection); err != nil {
+			return fmt.Errorf("collection address: %w", err)
+		}
+	}
+	forwardAmount, err := tlb.FromTON(*forward)
+	if err != nil {
+		return fmt.Errorf("-forward: %w", err)
+	}
+	itemValue, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(itemValue); err != nil { // the TON only pays the fees, fees.max_fee_value limits it
+		return err
+	}
+	var forwardPayload *cell.Cell
+	if *text != "" {
+		if forwardPayload, err = comment.Text(*text); err != nil {
+			return err
+		}
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Detect(ctx, client, walletAddress) // a wallet V3 sends 4 items at once, a highload wallet 254
+	if err != nil {
+		return err
+	}
+	detect.UseJournal(wallet, "")
+	if fs.NArg() > wallet.MaxMessages() {
+		return fmt.Errorf("%d items, %s sends at most %d at once", fs.NArg(), wallet.Version(), wallet.MaxMessages())
+	}
+
+	var transfers []nft.ItemTransfer
+	for _, arg := range fs.Args() {
+		item, err := address.ParseAddr(arg)
+		if err != nil {
+			return fmt.Errorf("item address %s: %w", arg, err)
+		}
+		// the transfer of an item we do not own fails, and its TON are spent on fees
+		if _, err = nft.CheckOwner(ctx, client, item, walletAddress, collectionAddress); err != nil {
+			return err
+		}
+
+		transfers = append(transfers, nft.ItemTransfer{
+			Item:  item,
+			Value: itemValue,
+			Transfer: nft.Transfer{
+				QueryID:             uint64(time.Now().UnixNano()), // comes back in the excesses, to match them with this message
+				NewOwner:            newOwner,
+				ResponseDestination: walletAddress, // the TON not spent on fees comes back
+				ForwardAmount:       forwardAmount,
+				ForwardPayload:      forwardPayload,
+			},
+		})
+	}
+
+	messages, err := nft.Messages(transfers)
+	if err != nil {
+		return err
+	}
+
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	externalMessage, err := wallet.Transfer(ctx, signer.FromKeyPair(keyPair), messages, cfg.ValidUntil())
+	if err != nil {
+		return err
+	}
+
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Printf("Sent %d items to %s", len(transfers), cfg.FormatAddress(newOwner))
+	return nil
+}
+
+func formatAddress(cfg *config.Config, addr *address.Address) string {
+	if addr == nil || addr.IsAddrNone() {
+		return "none"
+	}
+	return cfg.FormatAddress(addr)
+}
@@ -0,0 +1,212 @@
+// Command offline sends a wallet V3 transfer signed on an air-gapped machine.
+//
+// Online, make the unsigned request:
+//
+//	go run ./cmd/offline prepare -wallet <address> -out transfer.json \
+//	    -send "<address>,0.01,Hello, TON! #1" -send "<address>,0.02"
+//
+// Offline, check and sign it with a key from the keystore:
+//
+//	go run ./cmd/offline sign -key <name> -in transfer.json -out signed.json
+//
+// Online again, broadcast it:
+//
+//	go run ./cmd/offline broadcast -in signed.json
+//
+// The wallet, subwallet ID, key, fee limits and liteservers default to the ones in the config (see package config).
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+
+	"main/cmd/internal/cli"
+	"main/config"
+	"main/keystore"
+	"main/message"
+	"main/offline"
+	"main/signer"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch os.Args[1] {
+	case "prepare":
+		err = prepare(cfg, os.Args[2:])
+	case "sign":
+		err = sign(cfg, os.Args[2:])
+	case "broadcast":
+		err = broadcast(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: offline prepare|sign|broadcast [flags]")
+	os.Exit(2)
+}
+
+// messagesFlag collects -send "address,amount[,comment]" flags.
+type messagesFlag struct {
+	messages []offline.TransferMessage
+	bounce   *bool
+	mode     *uint
+}
+
+func (f *messagesFlag) String() string { return "" }
+
+func (f *messagesFlag) Set(value string) error {
+	parts := strings.SplitN(value, ",", 3)
+	if len(parts) < 2 {
+		return fmt.Errorf("expected address,amount[,comment], got %q", value)
+	}
+
+	m := offline.TransferMessage{
+		Destination: strings.TrimSpace(parts[0]),
+		Amount:      strings.TrimSpace(parts[1]),
+		Bounce:      *f.bounce,
+		Mode:        message.SendMode(*f.mode),
+	}
+	if *f.mode > 255 {
+		return fmt.Errorf("mode %d is not a byte", *f.mode)
+	}
+	if err := m.Mode.Validate(); err != nil {
+		return err
+	}
+	if len(parts) == 3 {
+		m.Comment = parts[2]
+	}
+	f.messages = append(f.messages, m)
+	return nil
+}
+
+func prepare(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("prepare", flag.ExitOnError)
+	wallet := fs.String("wallet", cfg.Wallet, "wallet address")
+	subwalletID := fs.Uint("subwallet", uint(cfg.SubwalletID), "subwallet ID")
+	validFor := fs.Duration("valid-for", time.Hour, "how long the signed transfer stays valid, longer than timeouts.valid_for to carry it offline and back")
+	out := fs.String("out", "transfer.json", "file for the unsigned request")
+	messages := &messagesFlag{
+		bounce: fs.Bool("bounce", true, "bounce flag of the next -send messages"),
+		mode:   fs.Uint("mode", 3, "send mode of the next -send messages"),
+	}
+	fs.Var(messages, "send", "message as address,amount[,comment], up to 4 times")
+	_ = fs.Parse(args)
+
+	walletAddress, err := address.ParseAddr(*wallet)
+	if err != nil {
+		return fmt.Errorf("wallet address: %w", err)
+	}
+	if err = checkAmounts(cfg, messages.messages); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	req, err := offline.Prepare(context.Background(), client, walletAddress, uint32(*subwalletID), *validFor, messages.messages)
+	if err != nil {
+		return err
+	}
+	fmt.Print(req.Describe())
+	return offline.WriteFile(*out, req)
+}
+
+func sign(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keystorePath := fs.String("file", cfg.Keystore, "keystore file")
+	keyName := fs.String("key", cfg.Key, "name of the key in the keystore")
+	in := fs.String("in", "transfer.json", "unsigned request")
+	out := fs.String("out", "signed.json", "file for the signed transfer")
+	_ = fs.Parse(args)
+
+	var req offline.TransferRequest
+	if err := offline.ReadFile(*in, &req); err != nil {
+		return err
+	}
+	if err := checkAmounts(cfg, req.Messages); err != nil { // the limits of the offline machine count, not the ones the request was made with
+		return err
+	}
+
+	fmt.Print(req.Describe())
+	fmt.Fprint(os.Stderr, "Sign this transfer? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(answer)) != "y" {
+		return fmt.Errorf("transfer was not signed")
+	}
+
+	keyPair, err := keystore.LoadKey(*keystorePath, *keyName, os.Getenv(keystore.PassphraseEnv))
+	if err != nil {
+		return err
+	}
+
+	signed, err := req.Sign(context.Background(), signer.FromKeyPair(keyPair))
+	if err != nil {
+		return err
+	}
+	return offline.WriteFile(*out, signed)
+}
+
+func broadcast(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	in := fs.String("in", "signed.json", "signed transfer")
+	_ = fs.Parse(args)
+
+	var signed offline.SignedTransfer
+	if err := offline.ReadFile(*in, &signed); err != nil {
+		return err
+	}
+
+	externalMessage, err := signed.External()
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err = message.Send(context.Background(), client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Transfer was sent, seqno:", signed.Request.Seqno)
+	return nil
+}
+
+// checkAmounts applies fees.max_amount of the config to every message.
+func checkAmounts(cfg *config.Config, messages []offline.TransferMessage) error {
+	for i, m := range messages {
+		amount, err := tlb.FromTON(m.Amount)
+		if err != nil {
+			return fmt.Errorf("message #%d: amount: %w", i+1, err)
+		}
+		if err = cfg.CheckAmount(amount); err != nil {
+			return fmt.Errorf("message #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Command send sends TON from the wallet in the config, whatever wallet it is:
+// the contract is detected by its code hash (see package detect) and the
+// message is built for it.
+//
+//	go run ./cmd/send -to <address> -amount 0.1 -comment "hello"
+//	go run ./cmd/send -to <a> -amount 1 -to <b> -amount 2
+//	go run ./cmd/send -detect
+//	go run ./cmd/send -detect -multisig-code multisig.boc
+//
+// -detect only prints what the wallet is. Before signing, send checks that the
+// key from the config is the key of the wallet. The multisig code is not in
+// this repository: with -multisig-code the multisig wallet V2 is detected too,
+// and send prints its signers and refuses, a multisig sends through orders.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/inspect"
+	"main/message"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var to, amounts, texts cli.List
+	flag.Var(&to, "to", "destination address, repeat for more messages")
+	flag.Var(&amounts, "amount", "TON to send, one per -to")
+	flag.Var(&texts, "comment", "text comment, one per -to or none")
+	onlyDetect := flag.Bool("detect", false, "print the wallet and exit")
+	multisigCode := flag.String("multisig-code", "", "code of the multisig wallet V2 to detect: BOC file, hex or base64")
+	flag.Parse()
+	if !*onlyDetect && (len(to) == 0 || len(amounts) != len(to) || (len(texts) != 0 && len(texts) != len(to))) {
+		flag.Usage()
+		log.Fatalln("every -to needs an -amount")
+	}
+
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *multisigCode != "" {
+		code, err := inspect.ParseBOC(*multisigCode)
+		if err != nil {
+			log.Fatalln("-multisig-code:", err)
+		}
+		detect.Register(code, detect.MultisigV2)
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	wallet, err := detect.Detect(ctx, client, walletAddress)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	detect.UseJournal(wallet, "") // a highload wallet takes its query_ids from the journal in the working directory
+	log.Printf("%s is %s", cfg.FormatAddress(walletAddress), wallet.Version())
+	if m, ok := wallet.(*detect.MultisigWallet); ok {
+		log.Printf("%d-of-%d signers, %d proposers, next order %s", m.Threshold, len(m.Signers), len(m.Proposers), m.NextOrderSeqno)
+		if !*onlyDetect {
+			log.Fatalln(detect.ErrUseOrders)
+		}
+	}
+	if *onlyDetect {
+		return
+	}
+
+	if len(to) > wallet.MaxMessages() {
+		log.Fatalf("%d messages, %s sends at most %d at once", len(to), wallet.Version(), wallet.MaxMessages())
+	}
+	messages, err := buildMessages(cfg, to, amounts, texts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !bytes.Equal(keyPair.PublicKey, wallet.PublicKey()) { // the wallet would reject the signature
+		log.Fatalln("the key from the config is not the key of the wallet")
+	}
+
+	externalMessage, err := wallet.Transfer(ctx, signer.FromKeyPair(keyPair), messages, cfg.ValidUntil())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Sent %d messages", len(messages))
+}
+
+func buildMessages(cfg *config.Config, to, amounts, texts []string) ([]message.Out, error) {
+	var messages []message.Out
+	for i := range to {
+		destination, err := address.ParseAddr(to[i])
+		if err != nil {
+			return nil, fmt.Errorf("-to %s: %w", to[i], err)
+		}
+		value, err := tlb.FromTON(amounts[i])
+		if err != nil {
+			return nil, fmt.Errorf("-amount %s: %w", amounts[i], err)
+		}
+		if err = cfg.CheckAmount(value); err != nil {
+			return nil, err
+		}
+		var body *cell.Cell
+		if len(texts) != 0 && texts[i] != "" {
+			if body, err = comment.Text(texts[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		internalMessage, err := (&message.Internal{
+			Bounce:  destination.IsBounceable(),
+			Dest:    destination,
+			Value:   value,
+			Body:    body,
+			BodyRef: true,
+		}).ToCell()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message.Out{Mode: message.ModeDefault, Message: internalMessage})
+	}
+	return messages, nil
+}
@@ -0,0 +1,133 @@
+// Command signer keeps a key from the keystore in its own process and signs
+// for the examples, so the process which talks to the liteserver never sees it.
+//
+//	go run ./cmd/signer -key <name> -confirm -unix /tmp/ton-signer.sock       serve signer.NewUnixSigner
+//	go run ./cmd/signer -key <name> -confirm -http 127.0.0.1:8081             serve signer.NewHTTPSigner
+//	go run ./cmd/signer -key <name> -approve-all -airgap /media/usb           answer signer.NewFileSigner requests once
+//
+// The keystore and the key default to the ones in the config (see package config).
+// The keystore passphrase is read from TON_KEYSTORE_PASSPHRASE.
+// Nothing is signed unless approval is chosen: with -confirm every hash has to
+// be approved on the terminal, with -approve-all every hash is logged and signed.
+// The Unix socket is made readable by its owner only. HTTP has no such check,
+// so -http needs a token in TON_SIGNER_TOKEN, which the clients send as well.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"main/config"
+	"main/keystore"
+	"main/signer"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	keystorePath := flag.String("file", cfg.Keystore, "keystore file")
+	keyName := flag.String("key", cfg.Key, "name of the key in the keystore")
+	unixPath := flag.String("unix", "", "Unix socket to listen on")
+	httpAddr := flag.String("http", "", "address to serve HTTP on")
+	airgapDir := flag.String("airgap", "", "directory with request files to sign")
+	confirm := flag.Bool("confirm", false, "ask before signing every hash")
+	approveAll := flag.Bool("approve-all", false, "sign every hash without asking, only logging it")
+	flag.Parse()
+
+	var approve signer.Approver
+	switch {
+	case *confirm && *approveAll:
+		log.Fatalln("-confirm and -approve-all exclude each other")
+	case *confirm:
+		approve = terminalApprover()
+	case *approveAll:
+		approve = logApprover
+	default:
+		log.Fatalln("choose how hashes are approved: -confirm or -approve-all")
+	}
+	token := os.Getenv(signer.TokenEnv)
+	if *httpAddr != "" && token == "" {
+		log.Fatalln("-http needs a token in", signer.TokenEnv)
+	}
+
+	keyPair, err := keystore.LoadKey(*keystorePath, *keyName, os.Getenv(keystore.PassphraseEnv))
+	if err != nil {
+		log.Fatalln("LoadKey err:", err.Error())
+	}
+	s := signer.FromKeyPair(keyPair)
+	log.Println("Public key:", hex.EncodeToString(s.PublicKey()))
+
+	switch {
+	case *airgapDir != "":
+		n, err := signer.SignRequests(context.Background(), *airgapDir, s, approve)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Signed requests:", n)
+	case *unixPath != "":
+		listener, err := net.Listen("unix", *unixPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		closeOnInterrupt(listener)
+		if err = os.Chmod(*unixPath, 0o600); err != nil { // only the owner may connect and sign
+			listener.Close()
+			log.Fatalln(err)
+		}
+		if err = signer.Serve(listener, s, approve); err != nil {
+			log.Fatalln(err)
+		}
+	case *httpAddr != "":
+		log.Println("Listening on", *httpAddr)
+		log.Fatalln(http.ListenAndServe(*httpAddr, signer.Handler(s, approve, token)))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func logApprover(hash []byte) error {
+	log.Println("Signing", hex.EncodeToString(hash))
+	return nil
+}
+
+func terminalApprover() signer.Approver {
+	var mu sync.Mutex
+	stdin := bufio.NewReader(os.Stdin)
+
+	return func(hash []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprintf(os.Stderr, "Sign %s? [y/N] ", hex.EncodeToString(hash))
+		answer, _ := stdin.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(answer)) != "y" {
+			return errors.New("rejected by operator")
+		}
+		return nil
+	}
+}
+
+// closeOnInterrupt closes the listener on Ctrl+C, so the socket file is removed.
+func closeOnInterrupt(listener net.Listener) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		listener.Close()
+	}()
+}
@@ -0,0 +1,254 @@
+// Command subscription creates, lists and cancels the subscriptions of the
+// wallet V4 in the config.
+//
+//	go run ./cmd/subscription create -code subscription-plugin.boc -beneficiary <address> \
+//	    -amount 1 -period 720h [-start 2026-11-01T00:00:00Z] [-timeout 1h] [-id 1]
+//	go run ./cmd/subscription list
+//	go run ./cmd/subscription cancel -plugin <plugin>
+//	go run ./cmd/subscription charge -plugin <plugin>
+//
+// -code is the compiled simple-subscription-plugin.fc of the wallet V4
+// sources, as a BOC file, hex or base64. create deploys the plugin from the
+// wallet and pays the first period; list prints the subscriptions installed on
+// the wallet with their next payment; cancel removes the plugin, which gives
+// its TON back to the wallet and destroys itself. charge sends the external
+// message which makes a plugin ask for its payment once a period is over: the
+// beneficiary or anyone else runs it, nothing is signed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+
+	"main/cmd/internal/cli"
+	"main/config"
+	"main/detect"
+	"main/inspect"
+	"main/message"
+	"main/signer"
+	"main/subscription"
+	"main/walletv4"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "create":
+		err = create(cfg, os.Args[2:])
+	case "list":
+		err = list(cfg)
+	case "cancel":
+		err = cancelSubscription(cfg, os.Args[2:])
+	case "charge":
+		err = charge(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: subscription create|list|cancel|charge [flags]")
+	os.Exit(2)
+}
+
+func create(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	codeFlag := fs.String("code", "", "code of the plugin: BOC file, hex or base64")
+	beneficiaryFlag := fs.String("beneficiary", "", "address which gets the payments")
+	amountFlag := fs.String("amount", "", "TON paid every period")
+	period := fs.Duration("period", 0, "time between the payments, whole seconds")
+	start := fs.String("start", "", "RFC 3339 start time of the subscription, now if empty")
+	timeout := fs.Duration("timeout", time.Hour, "time before the plugin asks again when the wallet did not pay")
+	id := fs.Uint("id", 0, "subscription ID, to have two subscriptions with the same terms")
+	fee := fs.String("ton", "0.1", "TON the plugin gets for storage and fees, besides -amount")
+	_ = fs.Parse(args)
+	if *codeFlag == "" || *beneficiaryFlag == "" || *amountFlag == "" || *period == 0 {
+		usage()
+	}
+
+	code, err := inspect.ParseBOC(*codeFlag)
+	if err != nil {
+		return fmt.Errorf("-code: %w", err)
+	}
+	beneficiary, err := address.ParseAddr(*beneficiaryFlag)
+	if err != nil {
+		return fmt.Errorf("-beneficiary: %w", err)
+	}
+	amount, err := tlb.FromTON(*amountFlag)
+	if err != nil {
+		return fmt.Errorf("-amount: %w", err)
+	}
+	if err = cfg.CheckAmount(amount); err != nil {
+		return err
+	}
+	feeAmount, err := tlb.FromTON(*fee)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(feeAmount); err != nil {
+		return err
+	}
+	var startTime time.Time
+	if *start != "" {
+		if startTime, err = time.Parse(time.RFC3339, *start); err != nil {
+			return fmt.Errorf("-start: %w", err)
+		}
+	}
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+
+	s := &subscription.Subscription{
+		Wallet:      walletAddress,
+		Beneficiary: beneficiary,
+		Amount:      amount,
+		Period:      *period,
+		StartTime:   startTime,
+		Timeout:     *timeout,
+		ID:          uint32(*id),
+	}
+	deployPlugin, err := s.Deploy(code, feeAmount)
+	if err != nil {
+		return err
+	}
+	if err = sign(cfg, &walletv4.Transfer{Plugin: deployPlugin}); err != nil {
+		return err
+	}
+	log.Println("Subscription:", cfg.FormatAddress(deployPlugin.Address()))
+	return nil
+}
+
+func list(cfg *config.Config) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	subscriptions, err := subscription.List(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		fmt.Println("No subscriptions")
+	}
+	for _, s := range subscriptions {
+		fmt.Println(cfg.FormatAddress(s.Plugin))
+		fmt.Println("  beneficiary: ", cfg.FormatAddress(s.Beneficiary))
+		fmt.Println("  amount:      ", s.Amount.String(), "TON every", s.Period)
+		fmt.Println("  next payment:", s.NextPayment().UTC().Format(time.RFC3339))
+		if s.FailedAttempts > 0 {
+			fmt.Println("  failed:      ", s.FailedAttempts, "requests the wallet did not pay")
+		}
+	}
+	return nil
+}
+
+func pluginFlag(name string, args []string) (*address.Address, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	plugin := fs.String("plugin", "", "address of the subscription plugin")
+	_ = fs.Parse(args)
+	if *plugin == "" {
+		usage()
+	}
+	pluginAddress, err := address.ParseAddr(*plugin)
+	if err != nil {
+		return nil, fmt.Errorf("-plugin: %w", err)
+	}
+	return pluginAddress, nil
+}
+
+func cancelSubscription(cfg *config.Config, args []string) error {
+	plugin, err := pluginFlag("cancel", args)
+	if err != nil {
+		return err
+	}
+	// The plugin gets "dstr" with the TON for its fees and sends all its balance back to the wallet
+	return sign(cfg, &walletv4.Transfer{Plugin: &walletv4.RemovePlugin{
+		Plugin:  plugin,
+		Amount:  tlb.MustFromTON("0.05"),
+		QueryID: uint64(time.Now().UnixNano()),
+	}})
+}
+
+func charge(cfg *config.Config, args []string) error {
+	plugin, err := pluginFlag("charge", args)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// The liteserver rejects the message when the plugin does not accept it, for example before the next payment
+	if err = message.Send(ctx, client.Client(), subscription.ChargeMessage(plugin)); err != nil {
+		return err
+	}
+	log.Println("Payment requested by", cfg.FormatAddress(plugin))
+	return nil
+}
+
+// sign fills in the wallet fields of t, signs it with the key from the config and sends it.
+func sign(cfg *config.Config, t *walletv4.Transfer) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// Only wallet V4 has plugins. The subwallet ID and the seqno are the
+	// ones in its data: the config may be of another subwallet
+	wallet, err := detect.Expect(ctx, client, walletAddress, detect.V4R1, detect.V4R2)
+	if err != nil {
+		return err
+	}
+	v4 := wallet.(*detect.WalletV4)
+	t.SubwalletID = v4.SubwalletID
+	t.ValidUntil = cfg.ValidUntil()
+	t.Seqno = v4.Seqno
+
+	externalMessage, err := t.External(ctx, signer.FromKeyPair(keyPair), walletAddress, nil)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Sent from", cfg.FormatAddress(walletAddress))
+	return nil
+}
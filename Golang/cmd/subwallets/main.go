@@ -0,0 +1,142 @@
+// Command subwallets prints the addresses of one key under a range of subwallet IDs.
+//
+//	go run ./cmd/subwallets -pubkey <hex> -from 0 -to 10
+//	go run ./cmd/subwallets -key <name> -type highload -from 0 -to 10 -check
+//	go run ./cmd/subwallets -key <name> -type v4 -from 0 -to 10
+//
+// With -check it also asks a liteserver which of them are deployed and what they hold.
+// The addresses are printed as they are computed; a range longer than -max is refused.
+// The key, the keystore and the liteservers default to the ones in the config (see package config).
+// -code replaces the built-in code with a base64 BOC of another wallet with the same data layout.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/config"
+	"main/contract"
+	"main/highload"
+	"main/keystore"
+	"main/subwallet"
+	"main/walletv3"
+	"main/walletv4"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	publicKeyHex := flag.String("pubkey", "", "public key in hex")
+	keystorePath := flag.String("file", cfg.Keystore, "keystore file")
+	keyName := flag.String("key", cfg.Key, "name of the key in the keystore, instead of -pubkey")
+	walletType := flag.String("type", "v3", "wallet type: v3, v4 or highload")
+	codeBOC := flag.String("code", "", "base64 BOC of the wallet code, instead of the built-in one")
+	first := flag.Uint("from", 0, "first subwallet ID")
+	last := flag.Uint("to", 10, "last subwallet ID")
+	limit := flag.Uint64("max", subwallet.MaxCount, "most subwallet IDs to list")
+	check := flag.Bool("check", false, "read the state and the balance of every address")
+	flag.Parse()
+
+	if *first > math.MaxUint32 || *last > math.MaxUint32 {
+		log.Fatalln("-from and -to must be at most", uint64(math.MaxUint32))
+	}
+	if *first > *last {
+		log.Fatalln(subwallet.ErrBadRange)
+	}
+	if count := uint64(*last-*first) + 1; count > *limit {
+		log.Fatalf("%d subwallet IDs, at most %d; raise -max to list more", count, *limit)
+	}
+
+	if *publicKeyHex != "" {
+		*keyName = "" // -pubkey wins over the key from the config
+	}
+	publicKey, err := loadPublicKey(*publicKeyHex, *keystorePath, *keyName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var code *cell.Cell
+	var data subwallet.DataFunc
+	switch *walletType {
+	case "v3":
+		code, data = walletv3.Code(), walletv3.Data
+	case "v4":
+		code, data = walletv4.Code(), walletv4.Data
+	case "highload":
+		code, data = highload.Code(), highload.Data
+	default:
+		log.Fatalln("unknown wallet type:", *walletType)
+	}
+	if *codeBOC != "" {
+		if code, err = contract.LoadCode(*codeBOC); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	each := func(fn func(subwallet.Wallet) error) error {
+		return subwallet.Each(publicKey, code, data, uint32(*first), uint32(*last), fn)
+	}
+
+	if !*check {
+		fmt.Printf("%-10s  %s\n", "SUBWALLET", "ADDRESS")
+		err = each(func(w subwallet.Wallet) error {
+			fmt.Printf("%-10d  %s\n", w.SubwalletID, cfg.FormatAddress(w.Address))
+			return nil
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx := context.Background()
+	block, err := client.CurrentMasterchainInfo(ctx) // all the addresses are read in one block
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%-10s  %-48s  %-9s  %s\n", "SUBWALLET", "ADDRESS", "STATUS", "BALANCE")
+	err = each(func(w subwallet.Wallet) error {
+		s, err := subwallet.CheckAt(ctx, client, block, w)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-10d  %-48s  %-9s  %s TON\n", s.SubwalletID, cfg.FormatAddress(s.Address), s.Status, s.Balance.String())
+		return nil
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func loadPublicKey(publicKeyHex, keystorePath, keyName string) (ed25519.PublicKey, error) {
+	if keyName != "" {
+		ks, err := keystore.Open(keystorePath)
+		if err != nil {
+			return nil, err
+		}
+		return ks.PublicKey(keyName) // the public key is stored unencrypted, no passphrase needed
+	}
+
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("-pubkey must be %d bytes in hex", ed25519.PublicKeySize)
+	}
+	return publicKey, nil
+}
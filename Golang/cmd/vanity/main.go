@@ -0,0 +1,108 @@
+// Command vanity searches for a wallet V3 address with a given prefix or suffix.
+//
+// Try subwallet IDs of a key from the keystore (fast):
+//
+//	go run ./cmd/vanity -key <name> -prefix EQDton
+//
+// Or generate a new mnemonic for every try and save the found one to the keystore (slow):
+//
+//	go run ./cmd/vanity -fresh -suffix TON -ignore-case -save <name>
+//
+// The keystore, the key and the subwallet ID default to the ones in the config (see package config).
+// Ctrl+C stops the search. The found subwallet ID and state init are printed
+// for the deploy from Chapter 3.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"main/config"
+	"main/keystore"
+	"main/vanity"
+	"main/walletv3"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	prefix := flag.String("prefix", "", "address prefix, including EQ or UQ (kQ or 0Q on a test network)")
+	suffix := flag.String("suffix", "", "address suffix")
+	ignoreCase := flag.Bool("ignore-case", false, "match letters in any case")
+	bounceable := flag.Bool("bounceable", true, "match the bounceable EQ... form instead of UQ...")
+	fresh := flag.Bool("fresh", false, "generate a new mnemonic for every try")
+	keystorePath := flag.String("file", cfg.Keystore, "keystore file")
+	keyName := flag.String("key", cfg.Key, "key in the keystore whose subwallet IDs are tried")
+	subwalletID := flag.Uint("subwallet", uint(cfg.SubwalletID), "subwallet ID with -fresh, the first one to try otherwise")
+	save := flag.String("save", "", "with -fresh, the name to save the found mnemonic under")
+	workers := flag.Int("workers", 0, "number of goroutines, all CPUs if 0")
+	flag.Parse()
+
+	opts := vanity.Options{
+		Prefix:      *prefix,
+		Suffix:      *suffix,
+		IgnoreCase:  *ignoreCase,
+		Bounceable:  *bounceable,
+		Testnet:     cfg.Testnet(),
+		FreshKeys:   *fresh,
+		SubwalletID: uint32(*subwalletID),
+		Code:        walletv3.Code(),
+		Data:        walletv3.Data,
+		Workers:     *workers,
+		Progress: func(tried uint64, elapsed time.Duration) {
+			log.Printf("Tried %d addresses, %.0f/s", tried, float64(tried)/elapsed.Seconds())
+		},
+		ProgressInterval: 5 * time.Second,
+	}
+
+	var ks *keystore.Keystore
+	if *keyName != "" || *save != "" {
+		if ks, err = keystore.Open(*keystorePath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if !*fresh {
+		if ks == nil {
+			log.Fatalln("-key or -fresh is required")
+		}
+		if opts.PublicKey, err = ks.PublicKey(*keyName); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := vanity.Search(ctx, opts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Println("Address:     ", result.Address.String())
+	fmt.Println("Subwallet ID:", result.SubwalletID)
+	fmt.Println("State init:  ", base64.StdEncoding.EncodeToString(result.StateInit.ToBOC()))
+	fmt.Println("Tried:       ", result.Tried)
+
+	if result.Mnemonic == nil {
+		fmt.Println("Key:         ", *keyName)
+		return
+	}
+	fmt.Println("Mnemonic:    ", strings.Join(result.Mnemonic, " "))
+
+	if *save != "" {
+		if err = ks.Add(*save, result.Mnemonic, "", os.Getenv(keystore.PassphraseEnv)); err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Saved to the keystore as", *save)
+	}
+}
@@ -0,0 +1,323 @@
+// Command walletv4 deploys a wallet V4R2 for the key in the config, sends TON
+// from it and manages its plugins.
+//
+//	go run ./cmd/walletv4 address
+//	go run ./cmd/walletv4 deploy
+//	go run ./cmd/walletv4 send -to <address> -amount 0.1 -comment "hello"
+//	go run ./cmd/walletv4 plugins
+//	go run ./cmd/walletv4 installed -plugin <plugin>
+//	go run ./cmd/walletv4 install -plugin <plugin>
+//	go run ./cmd/walletv4 remove -plugin <plugin>
+//	go run ./cmd/walletv4 deploy-plugin -state-init <BOC> [-body <BOC>]
+//
+// address prints the wallet of the key and the subwallet ID from the config;
+// deploy deploys it once it has TON for the fees. The other commands work
+// with the wallet from the config. -state-init and -body are a BOC file, hex
+// or base64.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/inspect"
+	"main/message"
+	"main/signer"
+	"main/walletv4"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "address":
+		err = printAddress(cfg)
+	case "deploy":
+		err = deploy(cfg)
+	case "send":
+		err = send(cfg, os.Args[2:])
+	case "plugins":
+		err = plugins(cfg)
+	case "installed":
+		err = installed(cfg, os.Args[2:])
+	case "install", "remove":
+		err = installOrRemove(cfg, os.Args[1], os.Args[2:])
+	case "deploy-plugin":
+		err = deployPlugin(cfg, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: walletv4 address|deploy|send|plugins|installed|install|remove|deploy-plugin [flags]")
+	os.Exit(2)
+}
+
+func printAddress(cfg *config.Config) error {
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	walletAddress := walletv4.Address(keyPair.PublicKey, cfg.SubwalletID)
+	walletAddress.SetBounce(false) // send the first TON to it non-bounceable, it is not deployed yet
+	fmt.Println(cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func deploy(cfg *config.Config) error {
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	walletAddress := walletv4.Address(keyPair.PublicKey, cfg.SubwalletID)
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// Like the deploy of wallet V3 in Chapter 3: seqno 0, no messages and the StateInit in the external message
+	t := walletv4.Transfer{
+		SubwalletID: cfg.SubwalletID,
+		ValidUntil:  cfg.ValidUntil(),
+	}
+	stateInit := walletv4.StateInit(keyPair.PublicKey, cfg.SubwalletID)
+	externalMessage, err := t.External(ctx, signer.FromKeyPair(keyPair), walletAddress, stateInit)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Deployed", cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func send(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	to := fs.String("to", "", "destination address")
+	amount := fs.String("amount", "", "TON to send")
+	text := fs.String("comment", "", "text comment")
+	_ = fs.Parse(args)
+	if *to == "" || *amount == "" {
+		usage()
+	}
+
+	destination, err := address.ParseAddr(*to)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	value, err := tlb.FromTON(*amount)
+	if err != nil {
+		return fmt.Errorf("-amount: %w", err)
+	}
+	if err = cfg.CheckAmount(value); err != nil {
+		return err
+	}
+	var body *cell.Cell
+	if *text != "" {
+		if body, err = comment.Text(*text); err != nil {
+			return err
+		}
+	}
+
+	internalMessage, err := (&message.Internal{
+		Bounce:  destination.IsBounceable(),
+		Dest:    destination,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+	if err != nil {
+		return err
+	}
+	return sign(cfg, &walletv4.Transfer{
+		Messages: []message.Out{{Mode: message.ModeDefault, Message: internalMessage}},
+	})
+}
+
+func plugins(cfg *config.Config) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	list, err := walletv4.GetPluginList(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No plugins")
+	}
+	for _, plugin := range list {
+		fmt.Println(cfg.FormatAddress(plugin))
+	}
+	return nil
+}
+
+func installed(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("installed", flag.ExitOnError)
+	pluginFlag := fs.String("plugin", "", "plugin address")
+	_ = fs.Parse(args)
+	if *pluginFlag == "" {
+		usage()
+	}
+
+	plugin, err := address.ParseAddr(*pluginFlag)
+	if err != nil {
+		return fmt.Errorf("-plugin: %w", err)
+	}
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	ok, err := walletv4.IsPluginInstalled(ctx, client, walletAddress, plugin)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ok)
+	return nil
+}
+
+func installOrRemove(cfg *config.Config, action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	pluginFlag := fs.String("plugin", "", "plugin address")
+	value := fs.String("ton", "0.05", "TON sent to the plugin with the op")
+	_ = fs.Parse(args)
+	if *pluginFlag == "" {
+		usage()
+	}
+
+	plugin, err := address.ParseAddr(*pluginFlag)
+	if err != nil {
+		return fmt.Errorf("-plugin: %w", err)
+	}
+	amount, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckFeeValue(amount); err != nil { // the TON only pays the fees of the plugin
+		return err
+	}
+
+	queryID := uint64(time.Now().UnixNano())
+	var pluginAction walletv4.PluginAction = &walletv4.InstallPlugin{Plugin: plugin, Amount: amount, QueryID: queryID}
+	if action == "remove" {
+		pluginAction = &walletv4.RemovePlugin{Plugin: plugin, Amount: amount, QueryID: queryID}
+	}
+	return sign(cfg, &walletv4.Transfer{Plugin: pluginAction})
+}
+
+func deployPlugin(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("deploy-plugin", flag.ExitOnError)
+	stateInitFlag := fs.String("state-init", "", "state init of the plugin: BOC file, hex or base64")
+	bodyFlag := fs.String("body", "", "body of the deploy message: BOC file, hex or base64")
+	value := fs.String("ton", "0.05", "TON the plugin gets")
+	workchain := fs.Int("workchain", 0, "workchain of the plugin")
+	_ = fs.Parse(args)
+	if *stateInitFlag == "" {
+		usage()
+	}
+
+	stateInit, err := inspect.ParseBOC(*stateInitFlag)
+	if err != nil {
+		return fmt.Errorf("-state-init: %w", err)
+	}
+	var body *cell.Cell
+	if *bodyFlag != "" {
+		if body, err = inspect.ParseBOC(*bodyFlag); err != nil {
+			return fmt.Errorf("-body: %w", err)
+		}
+	}
+	balance, err := tlb.FromTON(*value)
+	if err != nil {
+		return fmt.Errorf("-ton: %w", err)
+	}
+	if err = cfg.CheckAmount(balance); err != nil {
+		return err
+	}
+
+	d := &walletv4.DeployPlugin{Workchain: int8(*workchain), Balance: balance, StateInit: stateInit, Body: body}
+	if err = sign(cfg, &walletv4.Transfer{Plugin: d}); err != nil {
+		return err
+	}
+	log.Println("Plugin address:", cfg.FormatAddress(d.Address()))
+	return nil
+}
+
+// sign fills in the wallet fields of t, signs it with the key from the config and sends it.
+func sign(cfg *config.Config, t *walletv4.Transfer) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// the body of another wallet would be rejected, or worse, read differently
+	if _, err = detect.Expect(ctx, client, walletAddress, detect.V4R1, detect.V4R2); err != nil {
+		return err
+	}
+	seqno, err := walletv4.GetSeqno(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	t.SubwalletID = cfg.SubwalletID
+	t.ValidUntil = cfg.ValidUntil()
+	t.Seqno = seqno
+
+	externalMessage, err := t.External(ctx, signer.FromKeyPair(keyPair), walletAddress, nil)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Sent from", cfg.FormatAddress(walletAddress))
+	return nil
+}
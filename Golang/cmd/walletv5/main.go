@@ -0,0 +1,311 @@
+// Command walletv5 deploys a wallet V5R1 (W5) for the key in the config, sends
+// TON from it and manages its extensions.
+//
+//	go run ./cmd/walletv5 address [-subwallet 0]
+//	go run ./cmd/walletv5 deploy [-subwallet 0]
+//	go run ./cmd/walletv5 send -to <address> -amount 0.1 -comment "hello" [-to ... -amount ...]
+//	go run ./cmd/walletv5 send -internal -to <address> -amount 0.1
+//	go run ./cmd/walletv5 extensions
+//	go run ./cmd/walletv5 add-extension -extension <address>
+//	go run ./cmd/walletv5 remove-extension -extension <address>
+//
+// The wallet ID of W5 includes the network, so address and deploy use the
+// network of the config (mainnet or testnet) and -subwallet, 0 like the wallet
+// apps. The other commands work with the wallet from the config and read its
+// wallet ID with get_subwallet_id. send -internal does not send anything: it
+// prints a signed internal request, which anyone may send to the wallet with
+// TON for the fees.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/cmd/internal/cli"
+	"main/comment"
+	"main/config"
+	"main/detect"
+	"main/message"
+	"main/signer"
+	"main/walletv5"
+)
+
+func main() {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "address":
+		err = printAddress(cfg, os.Args[2:])
+	case "deploy":
+		err = deploy(cfg, os.Args[2:])
+	case "send":
+		err = send(cfg, os.Args[2:])
+	case "extensions":
+		err = extensions(cfg)
+	case "add-extension", "remove-extension":
+		err = changeExtension(cfg, os.Args[1], os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: walletv5 address|deploy|send|extensions|add-extension|remove-extension [flags]")
+	os.Exit(2)
+}
+
+// walletID returns the wallet ID of subwallet in the network of the config.
+func walletID(cfg *config.Config, subwallet uint) (walletv5.WalletID, error) {
+	if subwallet >= 1<<15 {
+		return walletv5.WalletID{}, walletv5.ErrSubwalletNumber
+	}
+	id := walletv5.DefaultWalletID(cfg.Testnet())
+	id.SubwalletNumber = uint16(subwallet)
+	return id, nil
+}
+
+func printAddress(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("address", flag.ExitOnError)
+	subwallet := fs.Uint("subwallet", 0, "subwallet number, 0 to 32767")
+	_ = fs.Parse(args)
+
+	id, err := walletID(cfg, *subwallet)
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	walletAddress, err := walletv5.Address(keyPair.PublicKey, id)
+	if err != nil {
+		return err
+	}
+	walletAddress.SetBounce(false) // send the first TON to it non-bounceable, it is not deployed yet
+	fmt.Println(cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func deploy(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	subwallet := fs.Uint("subwallet", 0, "subwallet number, 0 to 32767")
+	_ = fs.Parse(args)
+
+	id, err := walletID(cfg, *subwallet)
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	encodedID, err := id.Encode()
+	if err != nil {
+		return err
+	}
+	stateInit, err := walletv5.StateInit(keyPair.PublicKey, id)
+	if err != nil {
+		return err
+	}
+	walletAddress := address.NewAddress(0, byte(id.Workchain), stateInit.Hash())
+
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// Like the deploy in Chapter 3: seqno 0, no actions and the StateInit in the external message
+	r := walletv5.Request{
+		WalletID:   encodedID,
+		ValidUntil: cfg.ValidUntil(),
+	}
+	externalMessage, err := r.External(ctx, signer.FromKeyPair(keyPair), walletAddress, stateInit)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Deployed", cfg.FormatAddress(walletAddress))
+	return nil
+}
+
+func send(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var to, amounts, texts cli.List
+	fs.Var(&to, "to", "destination address, repeat for more messages")
+	fs.Var(&amounts, "amount", "TON to send, one per -to")
+	fs.Var(&texts, "comment", "text comment, one per -to or none")
+	internal := fs.Bool("internal", false, "print a signed internal request instead of sending an external message")
+	_ = fs.Parse(args)
+	if len(to) == 0 || len(amounts) != len(to) || (len(texts) != 0 && len(texts) != len(to)) {
+		usage()
+	}
+	if len(to) > walletv5.MaxMessages {
+		return fmt.Errorf("%d messages, the wallet sends at most %d at once", len(to), walletv5.MaxMessages)
+	}
+
+	var messages []message.Out
+	for i := range to {
+		destination, err := address.ParseAddr(to[i])
+		if err != nil {
+			return fmt.Errorf("-to %s: %w", to[i], err)
+		}
+		value, err := tlb.FromTON(amounts[i])
+		if err != nil {
+			return fmt.Errorf("-amount %s: %w", amounts[i], err)
+		}
+		if err = cfg.CheckAmount(value); err != nil {
+			return err
+		}
+		var body *cell.Cell
+		if len(texts) != 0 && texts[i] != "" {
+			if body, err = comment.Text(texts[i]); err != nil {
+				return err
+			}
+		}
+
+		internalMessage, err := (&message.Internal{
+			Bounce:  destination.IsBounceable(),
+			Dest:    destination,
+			Value:   value,
+			Body:    body,
+			BodyRef: true,
+		}).ToCell()
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message.Out{Mode: message.ModeDefault, Message: internalMessage})
+	}
+
+	return sign(cfg, walletv5.Actions{Messages: messages}, *internal)
+}
+
+func extensions(cfg *config.Config) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	allowed, err := walletv5.IsSignatureAllowed(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Signature allowed:", allowed)
+
+	installed, err := walletv5.GetExtensions(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	if len(installed) == 0 {
+		fmt.Println("No extensions")
+	}
+	for _, extension := range installed {
+		fmt.Println(cfg.FormatAddress(extension))
+	}
+	return nil
+}
+
+func changeExtension(cfg *config.Config, action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	extensionFlag := fs.String("extension", "", "extension address")
+	_ = fs.Parse(args)
+	if *extensionFlag == "" {
+		usage()
+	}
+
+	extension, err := address.ParseAddr(*extensionFlag)
+	if err != nil {
+		return fmt.Errorf("-extension: %w", err)
+	}
+
+	var extended walletv5.ExtendedAction = walletv5.AddExtension{Extension: extension}
+	if action == "remove-extension" {
+		extended = walletv5.RemoveExtension{Extension: extension}
+	}
+	return sign(cfg, walletv5.Actions{Extended: []walletv5.ExtendedAction{extended}}, false)
+}
+
+// sign signs the actions with the key from the config and sends them in an
+// external message, or prints them as a signed internal request.
+func sign(cfg *config.Config, actions walletv5.Actions, internal bool) error {
+	walletAddress, err := cfg.WalletAddress()
+	if err != nil {
+		return err
+	}
+	keyPair, err := cfg.LoadKey()
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Request)
+	defer cancel()
+
+	// the body of another wallet would be rejected, or worse, read differently
+	if _, err = detect.Expect(ctx, client, walletAddress, detect.V5R1); err != nil {
+		return err
+	}
+	seqno, err := walletv5.GetSeqno(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	id, err := walletv5.GetWalletID(ctx, client, walletAddress)
+	if err != nil {
+		return err
+	}
+	r := walletv5.Request{
+		WalletID:   id,
+		ValidUntil: cfg.ValidUntil(),
+		Seqno:      seqno,
+		Actions:    actions,
+	}
+	keySigner := signer.FromKeyPair(keyPair)
+
+	if internal {
+		body, err := r.SignInternal(ctx, keySigner)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Send to", cfg.FormatAddress(walletAddress), "with this body and TON for the fees:")
+		fmt.Println(base64.StdEncoding.EncodeToString(body.ToBOCWithFlags(false)))
+		return nil
+	}
+
+	externalMessage, err := r.External(ctx, keySigner, walletAddress, nil)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, client.Client(), externalMessage); err != nil {
+		return err
+	}
+	log.Println("Sent from", cfg.FormatAddress(walletAddress))
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package comment builds and reads the text comments of internal messages:
+// plain ones (op 0) of any length and encrypted ones (op 0x2167da4b) which only
+// the sender and the receiver can read.
+package comment
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Opcodes of the message bodies with a comment.
+const (
+	OpText      = 0x00000000
+	OpEncrypted = 0x2167da4b
+)
+
+var (
+	ErrNotComment = errors.New("comment: body is not a comment")
+	ErrEncrypted  = errors.New("comment: comment is encrypted, use Decrypt")
+	ErrNotSnake   = errors.New("comment: body is not a snake of whole bytes")
+)
+
+// Text returns the body of a message with a plain text comment. A comment
+// longer than one cell continues in a chain of references, 127 bytes each.
+func Text(text string) (*cell.Cell, error) {
+	b := cell.BeginCell().MustStoreUInt(OpText, 32)
+	if err := storeSnake(b, []byte(text)); err != nil {
+		return nil, err
+	}
+	return b.EndCell(), nil
+}
+
+// Decode returns the text of a plain comment. It returns ErrEncrypted for an
+// encrypted comment and ErrNotComment for any other body.
+func Decode(body *cell.Cell) (string, error) {
+	if body == nil {
+		return "", ErrNotComment
+	}
+	s := body.BeginParse()
+	if s.BitsLeft() < 32 {
+		return "", ErrNotComment
+	}
+
+	switch s.MustLoadUInt(32) {
+	case OpText:
+	case OpEncrypted:
+		return "", ErrEncrypted
+	default:
+		return "", ErrNotComment
+	}
+
+	data, err := loadSnake(s)
+	if err != nil {
+		return "", err
+	}
+	// the cells are split by bytes, so only the whole text has to be UTF-8
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("comment: text is not UTF-8")
+	}
+	return string(data), nil
+}
+
+// storeSnake stores data in what is left of b and continues in a chain of
+// references, each one the only reference of the cell before it.
+func storeSnake(b *cell.Builder, data []byte) error {
+	space := int(b.BitsLeft() / 8)
+	if len(data) <= space {
+		return b.StoreSlice(data, uint(len(data))*8)
+	}
+
+	// 127 bytes is the most whole bytes of a 1023-bit cell
+	var chunks [][]byte
+	for rest := data[space:]; len(rest) > 0; {
+		n := len(rest)
+		if n > 127 {
+			n = 127
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+
+	// the chain is built from its end, every cell refers to the next one
+	var next *cell.Cell
+	for i := len(chunks) - 1; i >= 0; i-- {
+		c := cell.BeginCell().MustStoreSlice(chunks[i], uint(len(chunks[i]))*8)
+		if next != nil {
+			c.MustStoreRef(next)
+		}
+		next = c.EndCell()
+	}
+
+	if err := b.StoreSlice(data[:space], uint(space)*8); err != nil {
+		return err
+	}
+	return b.StoreRef(next)
+}
+
+// loadSnake reads the rest of s and every cell of its reference chain.
+func loadSnake(s *cell.Slice) ([]byte, error) {
+	var data []byte
+	for {
+		if s.BitsLeft()%8 != 0 || s.RefsNum() > 1 {
+			return nil, ErrNotSnake
+		}
+		part, err := s.LoadSlice(s.BitsLeft())
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, part...)
+
+		if s.RefsNum() == 0 {
+			return data, nil
+		}
+		if s, err = s.LoadRef(); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package comment
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	// 123 bytes fit into the first cell next to the op, 127 into every next one
+	tests := []struct {
+		name  string
+		text  string
+		cells int
+	}{
+		{"empty", "", 1},
+		{"short", "Hello, TON!", 1},
+		{"first cell full", strings.Repeat("a", 123), 1},
+		{"one byte more", strings.Repeat("a", 124), 2},
+		{"second cell full", strings.Repeat("a", 123+127), 2},
+		{"third cell", strings.Repeat("a", 123+127+1), 3},
+		{"runes across cells", strings.Repeat("Тон💎", 40), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Text(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := chainLength(body); tt.cells != 0 && n != tt.cells {
+				t.Errorf("%d cells, want %d", n, tt.cells)
+			}
+			got, err := Decode(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.text {
+				t.Fatalf("Decode = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	encrypted := cell.BeginCell().MustStoreUInt(OpEncrypted, 32).MustStoreSlice(make([]byte, 64), 512).EndCell()
+	tests := []struct {
+		name string
+		body *cell.Cell
+		err  error
+	}{
+		{"nil", nil, ErrNotComment},
+		{"short", cell.BeginCell().MustStoreUInt(0, 16).EndCell(), ErrNotComment},
+		{"other op", cell.BeginCell().MustStoreUInt(0x5fcc3d14, 32).EndCell(), ErrNotComment},
+		{"encrypted", encrypted, ErrEncrypted},
+		{"not whole bytes", cell.BeginCell().MustStoreUInt(OpText, 32).MustStoreUInt(1, 4).EndCell(), ErrNotSnake},
+		{"two refs", cell.BeginCell().MustStoreUInt(OpText, 32).
+			MustStoreRef(cell.BeginCell().EndCell()).MustStoreRef(cell.BeginCell().EndCell()).EndCell(), ErrNotSnake},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.body); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("not UTF-8", func(t *testing.T) {
+		body := cell.BeginCell().MustStoreUInt(OpText, 32).MustStoreSlice([]byte{0xff, 0xfe}, 16).EndCell()
+		if _, err := Decode(body); err == nil {
+			t.Fatal("invalid UTF-8 was decoded")
+		}
+	})
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	newKey := func() ed25519.PrivateKey {
+		_, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	senderKey, receiverKey, otherKey := newKey(), newKey(), newKey()
+	receiverPublicKey := receiverKey.Public().(ed25519.PublicKey)
+	sender := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	otherSender := address.NewAddress(0, 0, make([]byte, 32))
+
+	for _, text := range []string{"", "Hello, TON!", strings.Repeat("x", 15), strings.Repeat("x", 16), strings.Repeat("Тон💎", 100)} {
+		body, err := Encrypt(text, sender, senderKey, receiverPublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(body) {
+			t.Fatal("IsEncrypted is false")
+		}
+		if _, err = Decode(body); !errors.Is(err, ErrEncrypted) {
+			t.Fatalf("Decode error %v, want ErrEncrypted", err)
+		}
+
+		tests := []struct {
+			name   string
+			sender *address.Address
+			key    ed25519.PrivateKey
+			err    error
+		}{
+			{"receiver", sender, receiverKey, nil},
+			{"sender", sender, senderKey, nil},
+			{"other key", sender, otherKey, ErrWrongKey},
+			{"other sender address", otherSender, receiverKey, ErrWrongKey},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := Decrypt(body, tt.sender, tt.key)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				if err == nil && got != text {
+					t.Fatalf("Decrypt = %q, want %q", got, text)
+				}
+			})
+		}
+	}
+
+	t.Run("corrupted", func(t *testing.T) {
+		short := cell.BeginCell().MustStoreUInt(OpEncrypted, 32).MustStoreSlice(make([]byte, 40), 320).EndCell()
+		if _, err := Decrypt(short, sender, receiverKey); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("error %v, want ErrCorrupted", err)
+		}
+		plain, _ := Text("plain")
+		if _, err := Decrypt(plain, sender, receiverKey); !errors.Is(err, ErrNotEncrypted) {
+			t.Fatalf("error %v, want ErrNotEncrypted", err)
+		}
+	})
+}
+
+// chainLength counts the cells of a snake.
+func chainLength(c *cell.Cell) int {
+	n := 1
+	for s := c.BeginParse(); s.RefsNum() > 0; n++ {
+		s = s.MustLoadRef()
+	}
+	return n
+}
@@ -0,0 +1,177 @@
+package comment
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/walletv3"
+)
+
+// The encrypted comment is the one of the wallet apps:
+//
+//	op 0x2167da4b | sender public key XOR receiver public key (32 bytes) | msg_key (16 bytes) | AES-256-CBC data
+//
+// data is a random prefix, whose first byte is its length (16 to 31 bytes, so
+// the data is whole 16-byte blocks), followed by the text. msg_key is the first
+// 16 bytes of HMAC-SHA512 of the data keyed with the sender wallet address, and
+// the AES key and IV are HMAC-SHA512 of msg_key keyed with the X25519 secret
+// shared by the two keys. Both sides read the comment with their own private key.
+
+var (
+	ErrNotEncrypted = errors.New("comment: body is not an encrypted comment")
+	ErrWrongKey     = errors.New("comment: the comment is not for this key or the sender address is wrong")
+	ErrCorrupted    = errors.New("comment: encrypted comment is corrupted")
+)
+
+// Encrypt returns the body of a message with a comment which only the owners of
+// ourKey and theirPublicKey can read. sender is the address of the wallet which
+// sends the message, the receiver needs it to decrypt.
+func Encrypt(text string, sender *address.Address, ourKey ed25519.PrivateKey, theirPublicKey ed25519.PublicKey) (*cell.Cell, error) {
+	if len(theirPublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("comment: receiver public key must be %d bytes", ed25519.PublicKeySize)
+	}
+	sharedSecret, err := adnl.SharedKey(ourKey, theirPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("comment: shared secret: %w", err)
+	}
+
+	// random prefix of 16..31 bytes which pads the data to whole AES blocks
+	prefixLength := (16+15+len(text))&^15 - len(text)
+	data := make([]byte, prefixLength+len(text))
+	if _, err = rand.Read(data[:prefixLength]); err != nil {
+		return nil, err
+	}
+	data[0] = byte(prefixLength)
+	copy(data[prefixLength:], text)
+
+	msgKey := hmacSHA512(salt(sender), data)[:16]
+	block, iv, err := cbcCipher(sharedSecret, msgKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+
+	ourPublicKey := ourKey.Public().(ed25519.PublicKey)
+	payload := make([]byte, 0, 32+16+len(encrypted))
+	for i := range ourPublicKey {
+		payload = append(payload, ourPublicKey[i]^theirPublicKey[i]) // either side gets the other key with its own one
+	}
+	payload = append(payload, msgKey...)
+	payload = append(payload, encrypted...)
+
+	b := cell.BeginCell().MustStoreUInt(OpEncrypted, 32)
+	if err = storeSnake(b, payload); err != nil {
+		return nil, err
+	}
+	return b.EndCell(), nil
+}
+
+// Decrypt returns the text of an encrypted comment. ourKey is the key of the
+// sender or of the receiver, sender is the source address of the message.
+func Decrypt(body *cell.Cell, sender *address.Address, ourKey ed25519.PrivateKey) (string, error) {
+	if body == nil {
+		return "", ErrNotEncrypted
+	}
+	s := body.BeginParse()
+	if s.BitsLeft() < 32 || s.MustLoadUInt(32) != OpEncrypted {
+		return "", ErrNotEncrypted
+	}
+
+	payload, err := loadSnake(s)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) < 32+16+16 || (len(payload)-32-16)%16 != 0 {
+		return "", ErrCorrupted
+	}
+	keysXOR, msgKey, encrypted := payload[:32], payload[32:48], payload[48:]
+
+	ourPublicKey := ourKey.Public().(ed25519.PublicKey)
+	theirPublicKey := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	for i := range theirPublicKey {
+		theirPublicKey[i] = keysXOR[i] ^ ourPublicKey[i]
+	}
+	sharedSecret, err := adnl.SharedKey(ourKey, theirPublicKey)
+	if err != nil {
+		return "", ErrWrongKey // the XOR with another key does not give a valid point
+	}
+
+	block, iv, err := cbcCipher(sharedSecret, msgKey)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, encrypted)
+
+	// msg_key is the checksum of the data, a wrong key or sender gives other bytes
+	if !hmac.Equal(hmacSHA512(salt(sender), data)[:16], msgKey) {
+		return "", ErrWrongKey
+	}
+	prefixLength := int(data[0])
+	if prefixLength < 16 || prefixLength > len(data) {
+		return "", ErrCorrupted
+	}
+	text := data[prefixLength:]
+	if !utf8.Valid(text) {
+		return "", ErrCorrupted
+	}
+	return string(text), nil
+}
+
+// IsEncrypted reports whether body is an encrypted comment.
+func IsEncrypted(body *cell.Cell) bool {
+	if body == nil || body.BitsSize() < 32 {
+		return false
+	}
+	return body.BeginParse().MustLoadUInt(32) == OpEncrypted
+}
+
+// ReceiverKey returns the public key of the wallet a comment is encrypted for,
+// with the get_public_key get method from Chapter 4. Wallets V3 and later have it.
+func ReceiverKey(ctx context.Context, api walletv3.TonAPI, receiver *address.Address) (ed25519.PublicKey, error) {
+	publicKey, err := walletv3.GetPublicKey(ctx, api, receiver)
+	if err != nil {
+		return nil, fmt.Errorf("comment: receiver key: %w", err)
+	}
+	if bytes.Equal(publicKey, make([]byte, ed25519.PublicKeySize)) {
+		return nil, fmt.Errorf("comment: receiver %s has no public key", receiver.String())
+	}
+	return publicKey, nil
+}
+
+// salt is the sender address in the form the wallet apps use: bounceable, URL-safe and without the testnet flag.
+func salt(sender *address.Address) []byte {
+	a := *sender
+	a.SetBounce(true)
+	a.SetTestnetOnly(false)
+	return []byte(a.String())
+}
+
+func cbcCipher(sharedSecret, msgKey []byte) (cipher.Block, []byte, error) {
+	x := hmacSHA512(sharedSecret, msgKey)
+	block, err := aes.NewCipher(x[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, x[32:48], nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	h := hmac.New(sha512.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
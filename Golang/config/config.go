@@ -0,0 +1,270 @@
+// Package config loads the settings the examples used to have as string literals:
+// the network, the liteserver config, the wallet address, the key, the subwallet ID,
+// timeouts and fee limits.
+//
+// The settings are read from a TOML or YAML file and then from environment
+// variables, so one value can be changed without editing the file:
+//
+//	network = "testnet"  # mainnet, testnet, local or a profile from [networks]
+//	wallet = "EQ..."
+//	key = "my wallet"    # name of the key in the keystore
+//
+//	[timeouts]
+//	valid_for = "1m"
+//
+//	[fees]
+//	max_amount = "1"     # TON
+//
+// A private network, for example the one CI runs without internet, gets its own profile:
+//
+//	network = "ci"
+//
+//	[networks.ci]
+//	liteserver_config = "ci.config.json"  # a global config JSON on disk
+//	testnet = true                       # addresses are printed with the testnet-only flag
+//	subwallet_id = 698983191
+//
+// The file is the one from TON_CONFIG, or ton.toml, ton.yaml or ton.yml in the
+// current directory. Without a file only the defaults and the environment are used.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable with the path of the config file.
+const FileEnv = "TON_CONFIG"
+
+// DefaultFiles are looked for in the current directory when FileEnv is not set.
+var DefaultFiles = []string{"ton.toml", "ton.yaml", "ton.yml"}
+
+var (
+	ErrUnknownFormat = errors.New("config: file must be .toml, .yaml or .yml")
+	ErrNoWallet      = errors.New("config: wallet address is not set (wallet or TON_WALLET)")
+	ErrNoKey         = errors.New("config: key is not set (key or TON_KEY)")
+	ErrAmountLimit   = errors.New("config: amount is above fees.max_amount")
+	ErrNoMaxAmount   = errors.New("config: amount limit is not set (fees.max_amount or TON_MAX_AMOUNT)")
+	ErrFeeValueLimit = errors.New("config: TON attached for fees is above fees.max_fee_value")
+)
+
+// Config is the whole configuration.
+type Config struct {
+	Network          string             `toml:"network" yaml:"network"`                     // name of the network profile: mainnet, testnet, local or one from Networks
+	LiteserverConfig string             `toml:"liteserver_config" yaml:"liteserver_config"` // URL or path of a global config JSON, from the profile if not set
+	Wallet           string             `toml:"wallet" yaml:"wallet"`                       // address of the wallet the examples send from
+	Keystore         string             `toml:"keystore" yaml:"keystore"`                   // keystore file
+	Key              string             `toml:"key" yaml:"key"`                             // name of the key of the wallet in the keystore
+	SubwalletID      uint32             `toml:"subwallet_id" yaml:"subwallet_id"`           // from the profile if not set
+	Timeouts         Timeouts           `toml:"timeouts" yaml:"timeouts"`
+	Fees             Fees               `toml:"fees" yaml:"fees"`
+	Networks         map[string]Network `toml:"networks" yaml:"networks"` // own profiles, they are added to the built-in ones or replace them
+
+	// File is the file the config was read from, empty if there was none.
+	File string `toml:"-" yaml:"-"`
+}
+
+// Timeouts are written as Go durations, for example "30s" or "2m".
+type Timeouts struct {
+	Request  time.Duration `toml:"request" yaml:"request"`     // connecting to liteservers and every request
+	ValidFor time.Duration `toml:"valid_for" yaml:"valid_for"` // how long a signed message stays valid
+}
+
+// Fees are written in TON, for example "0.05". Empty means no limit.
+//
+// Neither limit is a limit on the fees the network takes, which are only known
+// after the transaction: MaxFeeValue caps the TON attached to a message which
+// is only there to pay fees, like the forward TON of a jetton or NFT transfer,
+// a plugin or a multisig order, what is not spent comes back.
+type Fees struct {
+	MaxAmount   string `toml:"max_amount" yaml:"max_amount"`       // the most one message may carry
+	MaxFeeValue string `toml:"max_fee_value" yaml:"max_fee_value"` // the most TON one message may attach to pay fees
+}
+
+// env maps the environment variables to the fields they override.
+var env = []struct {
+	name string
+	set  func(c *Config, value string) error
+}{
+	{"TON_NETWORK", func(c *Config, v string) error { c.Network = v; return nil }},
+	{"TON_LITESERVER_CONFIG", func(c *Config, v string) error { c.LiteserverConfig = v; return nil }},
+	{"TON_WALLET", func(c *Config, v string) error { c.Wallet = v; return nil }},
+	{"TON_KEYSTORE", func(c *Config, v string) error { c.Keystore = v; return nil }},
+	{"TON_KEY", func(c *Config, v string) error { c.Key = v; return nil }},
+	{"TON_SUBWALLET_ID", func(c *Config, v string) error {
+		id, err := strconv.ParseUint(v, 10, 32)
+		c.SubwalletID = uint32(id)
+		return err
+	}},
+	{"TON_REQUEST_TIMEOUT", func(c *Config, v string) (err error) { c.Timeouts.Request, err = time.ParseDuration(v); return }},
+	{"TON_VALID_FOR", func(c *Config, v string) (err error) { c.Timeouts.ValidFor, err = time.ParseDuration(v); return }},
+	{"TON_MAX_AMOUNT", func(c *Config, v string) error { c.Fees.MaxAmount = v; return nil }},
+	{"TON_MAX_FEE_VALUE", func(c *Config, v string) error { c.Fees.MaxFeeValue = v; return nil }},
+}
+
+// Default returns the config used when nothing is set.
+func Default() *Config {
+	mainnet := Networks["mainnet"]
+	return &Config{
+		Network:          "mainnet",
+		LiteserverConfig: mainnet.LiteserverConfig,
+		Keystore:         "keystore.json",
+		SubwalletID:      mainnet.SubwalletID,
+		Timeouts: Timeouts{
+			Request:  30 * time.Second,
+			ValidFor: time.Minute,
+		},
+	}
+}
+
+// Load reads the config file at path, or the default one if path is empty,
+// applies the environment variables and validates the result.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = findFile()
+	}
+
+	// the first pass only finds out the network, its profile gives the defaults for the second one
+	first := Default()
+	if err := first.read(path); err != nil {
+		return nil, err
+	}
+	network, ok := first.profile(first.Network)
+	if !ok {
+		return nil, fmt.Errorf("config: unknown network %q, known: %s", first.Network, strings.Join(first.networkNames(), ", "))
+	}
+
+	c := Default()
+	c.Network = first.Network
+	c.LiteserverConfig = network.LiteserverConfig
+	c.SubwalletID = network.SubwalletID
+	if err := c.read(path); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// read applies the file at path, if there is one, and then the environment variables.
+func (c *Config) read(path string) error {
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range env {
+		value, ok := os.LookupEnv(e.name)
+		if !ok {
+			continue
+		}
+		if err := e.set(c, value); err != nil {
+			return fmt.Errorf("config: %s=%q: %w", e.name, value, err)
+		}
+	}
+	return nil
+}
+
+func findFile() string {
+	if path := os.Getenv(FileEnv); path != "" {
+		return path
+	}
+	for _, name := range DefaultFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config %s: unknown setting %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true) // a mistyped name is an error instead of a silently missing setting
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%w, got %s", ErrUnknownFormat, path)
+	}
+
+	c.File = path
+	return nil
+}
+
+// Validate checks every setting and returns all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(setting, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{setting}, args...)...))
+	}
+
+	if _, ok := c.profile(c.Network); !ok {
+		invalid("network", "unknown network %q, known: %s", c.Network, strings.Join(c.networkNames(), ", "))
+	}
+	if c.LiteserverConfig == "" {
+		invalid("liteserver_config", "is empty")
+	}
+	if c.Wallet != "" {
+		if _, err := address.ParseAddr(c.Wallet); err != nil {
+			invalid("wallet", "%q is not a valid address: %v", c.Wallet, err)
+		}
+	}
+	if c.Keystore == "" {
+		invalid("keystore", "is empty")
+	}
+	if c.Timeouts.Request <= 0 {
+		invalid("timeouts.request", "must be positive, got %s", c.Timeouts.Request)
+	}
+	if c.Timeouts.ValidFor <= 0 {
+		invalid("timeouts.valid_for", "must be positive, got %s", c.Timeouts.ValidFor)
+	}
+	if c.Fees.MaxAmount != "" {
+		if _, err := tlb.FromTON(c.Fees.MaxAmount); err != nil {
+			invalid("fees.max_amount", "%q is not an amount of TON: %v", c.Fees.MaxAmount, err)
+		}
+	}
+	if c.Fees.MaxFeeValue != "" {
+		if _, err := tlb.FromTON(c.Fees.MaxFeeValue); err != nil {
+			invalid("fees.max_fee_value", "%q is not an amount of TON: %v", c.Fees.MaxFeeValue, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	source := "config"
+	if c.File != "" {
+		source += " " + c.File
+	}
+	return fmt.Errorf("%s: %w", source, errors.Join(errs...))
+}
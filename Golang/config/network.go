@@ -0,0 +1,69 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// Liteserver configs of the public networks.
+const (
+	MainnetConfigURL = "https://ton-blockchain.github.io/global.config.json"
+	TestnetConfigURL = "https://ton-blockchain.github.io/testnet-global.config.json"
+)
+
+// Network is a profile of one TON network. The settings of the config which
+// are not set explicitly are taken from it.
+type Network struct {
+	LiteserverConfig string `toml:"liteserver_config" yaml:"liteserver_config"` // URL or path of a global config JSON
+	Testnet          bool   `toml:"testnet" yaml:"testnet"`                     // user-friendly addresses get the testnet-only flag
+	SubwalletID      uint32 `toml:"subwallet_id" yaml:"subwallet_id"`           // default subwallet ID of wallets, 698983191 if 0
+}
+
+// Networks are the built-in profiles. "local" is a private network, for example
+// the one CI runs, with the liteservers in local.config.json in the current directory.
+var Networks = map[string]Network{
+	"mainnet": {LiteserverConfig: MainnetConfigURL, SubwalletID: 698983191},
+	"testnet": {LiteserverConfig: TestnetConfigURL, Testnet: true, SubwalletID: 698983191},
+	"local":   {LiteserverConfig: "local.config.json", Testnet: true, SubwalletID: 698983191},
+}
+
+// profile returns the profile named name, from the config file first and from Networks otherwise.
+func (c *Config) profile(name string) (Network, bool) {
+	if n, ok := c.Networks[name]; ok {
+		if n.SubwalletID == 0 {
+			n.SubwalletID = 698983191 // the one of the wallet apps, a profile in the file may leave it out
+		}
+		return n, true
+	}
+	n, ok := Networks[name]
+	return n, ok
+}
+
+// networkNames lists every profile the config knows, for error messages.
+func (c *Config) networkNames() []string {
+	var names []string
+	for name := range Networks {
+		names = append(names, name)
+	}
+	for name := range c.Networks {
+		if _, ok := Networks[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Testnet reports whether addresses of the network get the testnet-only flag.
+func (c *Config) Testnet() bool {
+	n, _ := c.profile(c.Network)
+	return n.Testnet
+}
+
+// FormatAddress returns the user-friendly form of addr for the network of the config.
+func (c *Config) FormatAddress(addr *address.Address) string {
+	a := *addr // do not change the flags of the caller's address
+	a.SetTestnetOnly(c.Testnet())
+	return a.String()
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tlb"
+
+	"main/keys"
+	"main/keystore"
+)
+
+// WalletAddress returns the wallet address, or ErrNoWallet if it is not set.
+// The address is printed with the testnet-only flag on a test network.
+func (c *Config) WalletAddress() (*address.Address, error) {
+	if c.Wallet == "" {
+		return nil, ErrNoWallet
+	}
+	walletAddress, err := address.ParseAddr(c.Wallet)
+	if err != nil {
+		return nil, err
+	}
+	walletAddress.SetTestnetOnly(c.Testnet())
+	return walletAddress, nil
+}
+
+// LoadKey loads the key of the wallet from the keystore. The passphrase is
+// taken from the TON_KEYSTORE_PASSPHRASE environment variable.
+func (c *Config) LoadKey() (*keys.KeyPair, error) {
+	if c.Key == "" {
+		return nil, ErrNoKey
+	}
+	return keystore.LoadKey(c.Keystore, c.Key, os.Getenv(keystore.PassphraseEnv))
+}
+
+// AddConnections connects the pool to the liteservers from LiteserverConfig,
+// which is downloaded if it is a URL and read from disk otherwise.
+func (c *Config) AddConnections(ctx context.Context, pool *liteclient.ConnectionPool) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	if strings.HasPrefix(c.LiteserverConfig, "http://") || strings.HasPrefix(c.LiteserverConfig, "https://") {
+		if err := pool.AddConnectionsFromConfigUrl(ctx, c.LiteserverConfig); err != nil {
+			return fmt.Errorf("config: liteservers from %s: %w", c.LiteserverConfig, err)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(c.LiteserverConfig)
+	if err != nil {
+		return fmt.Errorf("config: liteserver_config: %w", err)
+	}
+	var global liteclient.GlobalConfig
+	if err = json.Unmarshal(data, &global); err != nil {
+		return fmt.Errorf("config: liteserver_config %s: %w", c.LiteserverConfig, err)
+	}
+	if err = pool.AddConnectionsFromConfig(ctx, &global); err != nil {
+		return fmt.Errorf("config: liteservers from %s: %w", c.LiteserverConfig, err)
+	}
+	return nil
+}
+
+// ValidUntil returns the expiration time for a message signed now.
+func (c *Config) ValidUntil() time.Time {
+	return time.Now().Add(c.Timeouts.ValidFor).UTC()
+}
+
+// CheckAmount returns ErrAmountLimit if amount is above fees.max_amount.
+func (c *Config) CheckAmount(amount tlb.Coins) error {
+	return checkLimit(amount, c.Fees.MaxAmount, ErrAmountLimit)
+}
+
+// RequireMaxAmount returns ErrNoMaxAmount if fees.max_amount is not set: CheckAmount
+// then lets any amount through, and a command whose other checks start from
+// that limit would check nothing.
+func (c *Config) RequireMaxAmount() error {
+	if c.Fees.MaxAmount == "" {
+		return ErrNoMaxAmount
+	}
+	return nil
+}
+
+// CheckFeeValue returns ErrFeeValueLimit if fee, the TON attached to a message
+// only to pay the fees of what it starts, is above fees.max_fee_value.
+func (c *Config) CheckFeeValue(fee tlb.Coins) error {
+	return checkLimit(fee, c.Fees.MaxFeeValue, ErrFeeValueLimit)
+}
+
+func checkLimit(value tlb.Coins, limit string, errLimit error) error {
+	if limit == "" {
+		return nil
+	}
+	max := tlb.MustFromTON(limit) // checked by Validate
+	if value.NanoTON().Cmp(max.NanoTON()) > 0 {
+		return fmt.Errorf("%w: %s > %s TON", errLimit, value.String(), limit)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Package contract has the parts every contract package of this repository
+// needs: the compiled code from a BOC and the state init which deploys a
+// contract, whose hash is its address.
+package contract
+
+import (
+	"encoding/base64"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// StateInit returns the state init of a contract with the given code and data.
+func StateInit(code, data *cell.Cell) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreBoolBit(false). // No split_depth
+		MustStoreBoolBit(false). // No special
+		MustStoreBoolBit(true).  // We have code
+		MustStoreRef(code).
+		MustStoreBoolBit(true). // We have data
+		MustStoreRef(data).
+		MustStoreBoolBit(false). // No library
+		EndCell()
+}
+
+// LoadCode decodes a code cell from a BOC in base64.
+func LoadCode(boc string) (*cell.Cell, error) {
+	codeCellBytes, err := base64.StdEncoding.DecodeString(boc)
+	if err != nil {
+		return nil, err
+	}
+	return cell.FromBOC(codeCellBytes)
+}
+
+// MustLoadCode is LoadCode which panics, for the code compiled into a package.
+func MustLoadCode(boc string) *cell.Cell {
+	code, err := LoadCode(boc)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
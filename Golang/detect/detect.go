@@ -0,0 +1,135 @@
+// Package detect finds out which wallet contract runs at an address by the hash
+// of its code, the one Chapter 3 logs, and returns the wallet with its data
+// parsed. Wallet.Transfer then builds the right message for any of them, so a
+// wallet V4 is never sent the body of a wallet V3.
+package detect
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/highload"
+	"main/highloadv3"
+	"main/walletv3"
+	"main/walletv4"
+	"main/walletv5"
+)
+
+// Version is a wallet contract.
+type Version string
+
+const (
+	V1R1         Version = "wallet V1R1"
+	V1R2         Version = "wallet V1R2"
+	V1R3         Version = "wallet V1R3"
+	V2R1         Version = "wallet V2R1"
+	V2R2         Version = "wallet V2R2"
+	V3R1         Version = "wallet V3R1"
+	V3R2         Version = "wallet V3R2"
+	V4R1         Version = "wallet V4R1"
+	V4R2         Version = "wallet V4R2"
+	V5R1         Version = "wallet V5R1"
+	HighloadV2   Version = "highload wallet V2"
+	HighloadV2R2 Version = "highload wallet V2R2"
+	HighloadV3   Version = "highload wallet V3"
+	MultisigV2   Version = "multisig wallet V2"
+)
+
+var (
+	ErrNotActive   = errors.New("detect: the account is not active, there is no code to detect")
+	ErrUnknownCode = errors.New("detect: unknown contract code")
+	ErrWrongWallet = errors.New("detect: the address runs another wallet")
+)
+
+// Known maps the hex hash of a code cell to its contract. Register adds more.
+var Known = map[string]Version{
+	"a0cfc2c48aee16a271f2cfc0b7382d81756cecb1017d077faaab3bb602f6868c": V1R1,
+	"d4902fcc9fad74698fa8e353220a68da0dcf72e32bcb2eb9ee04217c17d3062c": V1R2,
+	"587cc789eff1c84f46ec3797e45fc809a14ff5ae24f1e0c7a6a99cc9dc9061ff": V1R3,
+	"5c9a5e68c108e18721a07c42f9956bfb39ad77ec6d624b60c576ec88eee65329": V2R1,
+	"fe9530d3243853083ef2ef0b4c2908c0abf6fa1c31ea243aacaa5bf8c7d753f1": V2R2,
+	"b61041a58a7980b946e8fb9e198e3c904d24799ffa36574ea4251c41a566f581": V3R1,
+	"84dafa449f98a6987789ba232358072bc0f76dc4524002a5d0918b9a75d2d599": V3R2,
+	"64dd54805522c5be8a9db59cea0105ccf0d08786ca79beb8cb79e880a8d7322d": V4R1,
+	"feb5ff6820e2ff0d9483e7e0d62c817d846789fb4ae580c878866d959dabd5c0": V4R2,
+	"20834b7b72b112147e1b2fb457b84e74d1a30f04f737d4f62a668e9552d2b72f": V5R1,
+	"9494d1cc8edf12f05671a1a9ba09921096eb50811e1924ec65c3c629fbb80812": HighloadV2,
+	"203dd4f358adb49993129aa925cac39916b68a0e4f78d26e8f2c2b69eafa5679": HighloadV2R2,
+	"11acad7955844090f283bf238bc1449871f783e7cc0979408d3f4859483e8525": HighloadV3,
+}
+
+func init() {
+	// The code of these packages, in case it is compiled differently from the
+	// standard one: Chapter 3 compiles wallet V3 itself and gets another hash
+	Register(walletv3.Code(), V3R2)
+	Register(walletv4.Code(), V4R2)
+	Register(walletv5.Code(), V5R1)
+	Register(highload.Code(), HighloadV2)
+	Register(highloadv3.Code(), HighloadV3)
+	// The multisig code is not in this repository (see package multisig), so
+	// MultisigV2 is detected once the caller has registered the code it deploys.
+}
+
+// Register adds the code of a contract which is parsed like v, for example
+// a wallet compiled with another version of the compiler.
+func Register(code *cell.Cell, v Version) {
+	Known[hex.EncodeToString(code.Hash())] = v
+}
+
+// Identify returns the contract of a code cell.
+func Identify(code *cell.Cell) (Version, error) {
+	hash := hex.EncodeToString(code.Hash())
+	v, ok := Known[hash]
+	if !ok {
+		return "", fmt.Errorf("%w: code hash %s", ErrUnknownCode, hash)
+	}
+	return v, nil
+}
+
+// AccountAPI is the part of *ton.APIClient used to read the account.
+type AccountAPI interface {
+	CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+	GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error)
+}
+
+// Detect reads the account at walletAddress and returns its wallet.
+func Detect(ctx context.Context, api AccountAPI, walletAddress *address.Address) (Wallet, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := api.GetAccount(ctx, block, walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("detect: get account: %w", err)
+	}
+	if !account.IsActive || account.Code == nil || account.Data == nil {
+		return nil, ErrNotActive
+	}
+
+	v, err := Identify(account.Code)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(v, walletAddress, account.Data)
+}
+
+// Expect is Detect which returns ErrWrongWallet if the wallet is none of versions.
+func Expect(ctx context.Context, api AccountAPI, walletAddress *address.Address, versions ...Version) (Wallet, error) {
+	w, err := Detect(ctx, api, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if w.Version() == v {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is %s", ErrWrongWallet, walletAddress.String(), w.Version())
+}
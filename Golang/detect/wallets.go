@@ -0,0 +1,352 @@
+package detect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/highload"
+	"main/highloadv3"
+	"main/message"
+	"main/multisig"
+	"main/signer"
+	"main/walletv3"
+	"main/walletv4"
+	"main/walletv5"
+)
+
+var (
+	ErrSignatureDisabled = errors.New("detect: the wallet V5 accepts no signed requests, only its extensions")
+	ErrNoJournal         = errors.New("detect: a highload wallet sends with query_ids from a journal, set Journal")
+	ErrUseOrders         = errors.New("detect: a multisig sends through orders its signers approve, use cmd/multisig order")
+)
+
+// Wallet is a detected wallet with the data it had when it was detected.
+type Wallet interface {
+	Version() Version
+	Address() *address.Address
+	PublicKey() ed25519.PublicKey
+	// MaxMessages is how many internal messages one transfer carries.
+	MaxMessages() int
+	// Transfer signs messages with s and returns the external message which sends them.
+	Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error)
+}
+
+// The files UseJournal keeps the query_ids of highload wallets in, the same
+// as cmd/highload and cmd/highloadv3.
+const (
+	HighloadJournal   = "highload-queries.json"
+	HighloadV3Journal = "highloadv3-queries.json"
+)
+
+// UseJournal gives w, if it is a highload wallet, the journal of its query_ids
+// in dir, so Transfer never repeats one. The other wallets need none.
+func UseJournal(w Wallet, dir string) {
+	switch w := w.(type) {
+	case *HighloadWalletV2:
+		w.Journal = highload.OpenJournal(filepath.Join(dir, HighloadJournal), w.address)
+	case *HighloadWalletV3:
+		w.Journal = highloadv3.OpenJournal(filepath.Join(dir, HighloadV3Journal), w.address, w.Timeout)
+	}
+}
+
+// Parse parses the data cell of a wallet of version v at walletAddress.
+func Parse(v Version, walletAddress *address.Address, data *cell.Cell) (w Wallet, err error) {
+	defer func() {
+		if r := recover(); r != nil { // the Must loads panic on data which is too short
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			w, err = nil, fmt.Errorf("detect: %s data: %w", v, err)
+		}
+	}()
+
+	s := data.BeginParse()
+	b := base{address: walletAddress, version: v}
+
+	switch v {
+	case V1R1, V1R2, V1R3, V2R1, V2R2:
+		// seqno:uint32 public_key:bits256
+		w := &SimpleWallet{base: b}
+		w.Seqno = uint32(s.MustLoadUInt(32))
+		return w, w.loadKey(s)
+	case V3R1, V3R2:
+		// seqno:uint32 subwallet_id:uint32 public_key:bits256
+		w := &WalletV3{base: b}
+		w.Seqno = uint32(s.MustLoadUInt(32))
+		w.SubwalletID = uint32(s.MustLoadUInt(32))
+		return w, w.loadKey(s)
+	case V4R1, V4R2:
+		// the same as V3 and plugins:(HashmapE 264 ^Cell)
+		w := &WalletV4{WalletV3: WalletV3{base: b}}
+		w.Seqno = uint32(s.MustLoadUInt(32))
+		w.SubwalletID = uint32(s.MustLoadUInt(32))
+		if err := w.loadKey(s); err != nil {
+			return nil, err
+		}
+		plugins, err := s.LoadDict(8 + 256)
+		if err != nil {
+			return nil, err
+		}
+		w.Plugins = len(plugins.All())
+		return w, nil
+	case V5R1:
+		// is_signature_allowed:Bool seqno:uint32 wallet_id:uint32 public_key:bits256 extensions:(HashmapE 256 int1)
+		w := &WalletV5{base: b}
+		w.SignatureAllowed = s.MustLoadBoolBit()
+		w.Seqno = uint32(s.MustLoadUInt(32))
+		w.WalletID = uint32(s.MustLoadUInt(32))
+		if err := w.loadKey(s); err != nil {
+			return nil, err
+		}
+		extensions, err := s.LoadDict(256)
+		if err != nil {
+			return nil, err
+		}
+		w.Extensions = len(extensions.All())
+		return w, nil
+	case HighloadV2, HighloadV2R2:
+		state, err := highload.ParseData(data)
+		if err != nil {
+			return nil, err
+		}
+		b.publicKey = state.PublicKey
+		return &HighloadWalletV2{
+			base:        b,
+			SubwalletID: state.SubwalletID,
+			LastCleaned: state.LastCleaned,
+			OldQueries:  state.OldQueries,
+		}, nil
+	case HighloadV3:
+		// public_key:bits256 subwallet_id:uint32 old_queries queries last_clean_time:uint64 timeout:uint22
+		w := &HighloadWalletV3{base: b}
+		if err := w.loadKey(s); err != nil {
+			return nil, err
+		}
+		w.SubwalletID = uint32(s.MustLoadUInt(32))
+		s.MustLoadMaybeRef() // old_queries, we only skip the two dictionaries
+		s.MustLoadMaybeRef() // queries
+		w.LastCleanTime = s.MustLoadUInt(64)
+		w.Timeout = uint32(s.MustLoadUInt(22))
+		return w, nil
+	case MultisigV2:
+		state, allowArbitrarySeqno, err := multisig.ParseData(data)
+		if err != nil {
+			return nil, err
+		}
+		return &MultisigWallet{
+			base:                b,
+			NextOrderSeqno:      state.NextOrderSeqno,
+			Params:              state.Params,
+			AllowArbitrarySeqno: allowArbitrarySeqno,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCode, v)
+	}
+}
+
+// base is what every wallet has.
+type base struct {
+	address   *address.Address
+	version   Version
+	publicKey ed25519.PublicKey
+}
+
+func (b *base) Version() Version             { return b.version }
+func (b *base) Address() *address.Address    { return b.address }
+func (b *base) PublicKey() ed25519.PublicKey { return b.publicKey }
+
+func (b *base) loadKey(s *cell.Slice) error {
+	key, err := s.LoadSlice(256)
+	if err != nil {
+		return err
+	}
+	b.publicKey = key
+	return nil
+}
+
+// SimpleWallet is a wallet V1 or V2.
+type SimpleWallet struct {
+	base
+	Seqno uint32
+}
+
+func (w *SimpleWallet) MaxMessages() int { return 4 }
+
+// Transfer builds the body Chapter 2 describes for wallet V3 without the
+// subwallet ID, and without valid_until for V1.
+func (w *SimpleWallet) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	if len(messages) > w.MaxMessages() {
+		return nil, fmt.Errorf("detect: %s sends at most %d messages at once", w.version, w.MaxMessages())
+	}
+
+	toSign := cell.BeginCell().MustStoreUInt(uint64(w.Seqno), 32)
+	if w.version == V2R1 || w.version == V2R2 {
+		toSign.MustStoreUInt(uint64(validUntil.Unix()), 32) // message expiration time, since V2
+	}
+	for i, m := range messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("detect: message %d: %w", i+1, err)
+		}
+		toSign.MustStoreUInt(uint64(m.Mode), 8).MustStoreRef(m.Message)
+	}
+
+	signature, err := s.Sign(ctx, toSign.EndCell().Hash())
+	if err != nil {
+		return nil, err
+	}
+	body := cell.BeginCell().
+		MustStoreSlice(signature, 512).
+		MustStoreBuilder(toSign).
+		EndCell()
+	return message.External(w.address, nil, body), nil
+}
+
+// WalletV3 is a wallet V3, see package walletv3.
+type WalletV3 struct {
+	base
+	Seqno       uint32
+	SubwalletID uint32
+}
+
+func (w *WalletV3) MaxMessages() int { return walletv3.MaxMessages }
+
+func (w *WalletV3) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	t := walletv3.Transfer{SubwalletID: w.SubwalletID, ValidUntil: validUntil, Seqno: w.Seqno, Messages: messages}
+	return t.External(ctx, s, w.address, nil)
+}
+
+// WalletV4 is a wallet V4, see package walletv4.
+type WalletV4 struct {
+	WalletV3
+	Plugins int // how many plugins are installed
+}
+
+func (w *WalletV4) MaxMessages() int { return walletv4.MaxMessages }
+
+func (w *WalletV4) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	t := walletv4.Transfer{SubwalletID: w.SubwalletID, ValidUntil: validUntil, Seqno: w.Seqno, Messages: messages}
+	return t.External(ctx, s, w.address, nil)
+}
+
+// WalletV5 is a wallet V5, see package walletv5.
+type WalletV5 struct {
+	base
+	SignatureAllowed bool
+	Seqno            uint32
+	WalletID         uint32
+	Extensions       int // how many extensions are installed
+}
+
+func (w *WalletV5) MaxMessages() int { return walletv5.MaxMessages }
+
+func (w *WalletV5) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	if !w.SignatureAllowed {
+		return nil, ErrSignatureDisabled
+	}
+	r := walletv5.Request{
+		WalletID:   w.WalletID,
+		ValidUntil: validUntil,
+		Seqno:      w.Seqno,
+		Actions:    walletv5.Actions{Messages: messages},
+	}
+	return r.External(ctx, s, w.address, nil)
+}
+
+// HighloadWalletV2 is a highload wallet V2, see package highload.
+type HighloadWalletV2 struct {
+	base
+	SubwalletID uint32
+	LastCleaned uint64
+	OldQueries  []highload.QueryIDParts // the query_ids it processed and still remembers
+
+	// Journal issues the query_ids of Transfer, the caller sets it; see
+	// highload.OpenJournal.
+	Journal *highload.Journal
+}
+
+func (w *HighloadWalletV2) MaxMessages() int { return highload.MaxMessages }
+
+// Transfer takes the query_id from Journal, it holds the expiration time
+// validUntil. A retry sends the same external message again: another Transfer
+// issues another query_id, and the wallet would send the messages twice.
+func (w *HighloadWalletV2) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	if w.Journal == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoJournal, w.version)
+	}
+	queryID, err := w.Journal.Issue(ctx, time.Until(validUntil), fmt.Sprintf("%d messages", len(messages)))
+	if err != nil {
+		return nil, err
+	}
+	q := highload.Query{
+		SubwalletID: w.SubwalletID,
+		QueryID:     queryID,
+		Messages:    messages,
+	}
+	return q.External(ctx, s, w.address)
+}
+
+// HighloadWalletV3 is a highload wallet V3, see package highloadv3.
+type HighloadWalletV3 struct {
+	base
+	SubwalletID   uint32
+	LastCleanTime uint64
+	Timeout       uint32 // seconds a message stays valid after its created_at
+
+	// Journal issues the query_ids of Transfer, the caller sets it; see
+	// highloadv3.OpenJournal.
+	Journal *highloadv3.Journal
+}
+
+// MaxMessages is what one internal_transfer carries, PackActions chains more
+// but Transfer sends one.
+func (w *HighloadWalletV3) MaxMessages() int { return highloadv3.MaxActions }
+
+// Transfer takes the query_id from Journal, with the value of
+// highloadv3.TransferValue. The wallet has its own timeout, validUntil is not used.
+func (w *HighloadWalletV3) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	if len(messages) > w.MaxMessages() {
+		return nil, fmt.Errorf("detect: %s sends at most %d messages at once", w.version, w.MaxMessages())
+	}
+	if w.Journal == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoJournal, w.version)
+	}
+	queryID, err := w.Journal.Issue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b := highloadv3.Batch{
+		SubwalletID: w.SubwalletID,
+		Timeout:     w.Timeout,
+		QueryID:     queryID,
+		Value:       highloadv3.TransferValue(len(messages)), // the wallet sends it to itself, only the fees are spent
+		Mode:        message.FlagPayFeesSeparately,
+		Messages:    messages,
+	}
+	return b.External(ctx, s, w.address, nil)
+}
+
+// MultisigWallet is a multisig wallet V2, see package multisig. It has no
+// key, PublicKey is nil: the signers are wallets which approve its orders.
+type MultisigWallet struct {
+	base
+	NextOrderSeqno *big.Int
+	multisig.Params
+	AllowArbitrarySeqno bool
+}
+
+// MaxMessages is how many actions one order carries.
+func (w *MultisigWallet) MaxMessages() int { return multisig.MaxActions }
+
+// Transfer returns ErrUseOrders: no key signs for a multisig, a signer
+// creates an order from its own wallet and the others approve it.
+func (w *MultisigWallet) Transfer(ctx context.Context, s signer.Signer, messages []message.Out, validUntil time.Time) (*cell.Cell, error) {
+	return nil, fmt.Errorf("%w: %d of %d signers approve", ErrUseOrders, w.Threshold, len(w.Signers))
+}
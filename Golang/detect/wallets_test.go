@@ -0,0 +1,124 @@
+package detect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/multisig"
+	"main/signer"
+)
+
+func TestParseMultisig(t *testing.T) {
+	signer := func(b byte) *address.Address {
+		return address.NewAddress(0, 0, append(make([]byte, 31), b))
+	}
+	tests := []struct {
+		name string
+		p    multisig.Params
+		arb  bool
+	}{
+		{"2-of-3", multisig.Params{Threshold: 2, Signers: []*address.Address{signer(1), signer(2), signer(3)}}, false},
+		{"with proposers", multisig.Params{Threshold: 1, Signers: []*address.Address{signer(1)},
+			Proposers: []*address.Address{signer(4), signer(5)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := multisig.Data(&tt.p, tt.arb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := Parse(MultisigV2, signer(9), data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, ok := w.(*MultisigWallet)
+			if !ok {
+				t.Fatalf("Parse returned %T", w)
+			}
+			if m.Threshold != tt.p.Threshold || len(m.Signers) != len(tt.p.Signers) || len(m.Proposers) != len(tt.p.Proposers) ||
+				m.AllowArbitrarySeqno != tt.arb || m.NextOrderSeqno.Sign() != 0 {
+				t.Fatalf("parsed %+v", m)
+			}
+			for i, s := range m.Signers {
+				if s.String() != tt.p.Signers[i].String() {
+					t.Errorf("signer %d is %s, want %s", i, s, tt.p.Signers[i])
+				}
+			}
+			if m.PublicKey() != nil {
+				t.Error("a multisig has a public key")
+			}
+			if _, err := m.Transfer(context.Background(), nil, nil, time.Now()); !errors.Is(err, ErrUseOrders) {
+				t.Fatalf("Transfer error %v, want ErrUseOrders", err)
+			}
+		})
+	}
+
+	t.Run("signers_num mismatch", func(t *testing.T) {
+		data, err := multisig.Data(&tests[0].p, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := data.BeginParse()
+		broken := cell.BeginCell().
+			MustStoreBigUInt(s.MustLoadBigUInt(256), 256).
+			MustStoreUInt(s.MustLoadUInt(8), 8).
+			MustStoreRef(s.MustLoadRef().MustToCell()).
+			MustStoreUInt(s.MustLoadUInt(8)+1, 8).
+			MustStoreBuilder(s.MustToCell().ToBuilder()).
+			EndCell()
+		if _, err := Parse(MultisigV2, signer(9), broken); err == nil {
+			t.Fatal("a wrong signers_num was parsed")
+		}
+	})
+}
+
+func TestHighloadJournal(t *testing.T) {
+	ctx := context.Background()
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySigner := signer.NewKeySigner(privateKey)
+	walletAddress := address.NewAddress(0, 0, make([]byte, 32))
+	validUntil := time.Now().Add(time.Minute)
+	messages := []message.Out{{Mode: message.ModeDefault, Message: (&message.Internal{
+		Dest: walletAddress, Value: tlb.MustFromTON("0.1"),
+	}).MustToCell()}}
+
+	v2 := &HighloadWalletV2{base: base{address: walletAddress, version: HighloadV2R2}, SubwalletID: 1}
+	v3 := &HighloadWalletV3{base: base{address: walletAddress, version: HighloadV3}, SubwalletID: 1, Timeout: 128}
+	for _, w := range []Wallet{v2, v3} {
+		if _, err := w.Transfer(ctx, keySigner, nil, validUntil); !errors.Is(err, ErrNoJournal) {
+			t.Fatalf("%s without a journal: error %v, want ErrNoJournal", w.Version(), err)
+		}
+	}
+
+	dir := t.TempDir()
+	for _, w := range []Wallet{v2, v3} {
+		UseJournal(w, dir)
+		for i := 0; i < 2; i++ {
+			if _, err := w.Transfer(ctx, keySigner, messages, validUntil); err != nil {
+				t.Fatalf("%s: %v", w.Version(), err)
+			}
+		}
+	}
+
+	records, err := v2.Journal.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].QueryID == records[1].QueryID {
+		t.Errorf("highload wallet V2 query_ids %+v, want two different ones", records)
+	}
+	if q, err := v3.Journal.Issue(ctx); err != nil || q.Seqno() != 2 {
+		t.Errorf("highload wallet V3 issues %v, %v after two transfers, want seqno 2", q, err)
+	}
+}
@@ -3,8 +3,11 @@ module main
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/xssnick/tonutils-go v1.7.4
 	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,218 @@
+package highload
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/signer"
+)
+
+// Limits of one external message, config param 43 of the mainnet.
+const (
+	MaxExternalCells = 1 << 13
+	MaxExternalBits  = 1 << 21
+)
+
+// GasLimit is the most gas a transaction of the basechain may use after
+// accept_message, config param 21 of the mainnet.
+const GasLimit = 1_000_000
+
+// A rough gas estimate from what highload_wallet.fc runs: the signature check
+// and old_queries for the query, a dictionary lookup and SENDRAWMSG for every
+// message. The real gas also grows with the number of old queries.
+const (
+	gasPerQuery   = 6000
+	gasPerMessage = 900
+)
+
+// Per message, the dictionary adds a fork and a value cell with the mode.
+const (
+	dictCellsPerMessage = 2
+	dictBitsPerMessage  = 2*(2+16) + 8 // two labels of at most 16 bits with their headers, the mode
+	queryBits           = 512 + 32 + 64 + 1
+	externalBits        = 2 + 2 + 267 + 4 + 2 // ext_in_msg_info, src, dest, import fee, no state init and a body ref
+)
+
+var ErrMessageTooBig = errors.New("highload: a message does not fit in an external message alone")
+
+// Chunk is a part of a batch which is sent in one query.
+type Chunk struct {
+	Messages []message.Out
+	First    int    // the index of its first message in the messages given to Split
+	Cells    int    // cells of the external message, an estimate
+	Bits     int    // bits of the external message, an estimate
+	Gas      int64  // gas of the transaction, an estimate
+	QueryID  uint64 // set by BatchSender.Send
+	Sent     bool   // the liteserver accepted the external message
+	Status   QueryStatus
+	Err      error // why the chunk was not sent
+}
+
+// Split splits messages into chunks which fit into one query each: at most
+// MaxMessages messages, MaxExternalCells cells, MaxExternalBits bits and
+// GasLimit gas. The order of the messages is kept.
+func Split(messages []message.Out) ([]*Chunk, error) {
+	var chunks []*Chunk
+	current := newChunk()
+	for i, m := range messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("highload: message %d: %w", i+1, err)
+		}
+		cells, bits := treeSize(m.Message)
+		cells += dictCellsPerMessage
+		bits += dictBitsPerMessage
+
+		if !current.fits(cells, bits) {
+			if len(current.Messages) == 0 {
+				return nil, fmt.Errorf("%w: message %d has %d cells", ErrMessageTooBig, i+1, cells)
+			}
+			chunks = append(chunks, current)
+			current = newChunk()
+			current.First = i
+			if !current.fits(cells, bits) {
+				return nil, fmt.Errorf("%w: message %d has %d cells", ErrMessageTooBig, i+1, cells)
+			}
+		}
+		current.Messages = append(current.Messages, m)
+		current.Cells += cells
+		current.Bits += bits
+		current.Gas += gasPerMessage
+	}
+	if len(current.Messages) != 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks, nil
+}
+
+func newChunk() *Chunk {
+	// The external message, the body and the root of the dictionary
+	return &Chunk{Cells: 3, Bits: externalBits + queryBits, Gas: gasPerQuery}
+}
+
+func (c *Chunk) fits(cells, bits int) bool {
+	return len(c.Messages) < MaxMessages &&
+		c.Cells+cells <= MaxExternalCells &&
+		c.Bits+bits <= MaxExternalBits &&
+		c.Gas+gasPerMessage <= GasLimit
+}
+
+// treeSize returns the cells and bits of the tree of c, the same cell counted once like in a BOC.
+func treeSize(c *cell.Cell) (cells, bits int) {
+	seen := map[string]bool{}
+	var walk func(c *cell.Cell)
+	walk = func(c *cell.Cell) {
+		hash := hex.EncodeToString(c.Hash())
+		if seen[hash] {
+			return
+		}
+		seen[hash] = true
+		cells++
+		bits += int(c.BitsSize())
+
+		s := c.BeginParse()
+		for s.RefsNum() > 0 {
+			ref, err := s.LoadRef()
+			if err != nil {
+				return
+			}
+			refCell, err := ref.ToCell()
+			if err != nil {
+				return
+			}
+			walk(refCell)
+		}
+	}
+	walk(c)
+	return cells, bits
+}
+
+// BatchSender sends chunks from a highload wallet v2, every one with its own
+// query_id from Journal.
+type BatchSender struct {
+	Client      ton.LiteClient
+	Journal     *Journal
+	Signer      signer.Signer
+	Wallet      *address.Address
+	SubwalletID uint32
+	Timeout     time.Duration // how long the query_ids are valid
+
+	// Save, if set, is called with all the chunks after a chunk got its
+	// query_id and before it is sent, to keep them in a Plan. The chunk is
+	// not sent if Save fails.
+	Save func(chunks []*Chunk) error
+}
+
+// Send sends every chunk which has no query_id yet, or whose query_id
+// Refresh recorded as expired, and records the result in it. A chunk which
+// fails does not stop the others. It returns how many chunks failed.
+//
+// A failed chunk is sent again only after Refresh finds its query_id expired:
+// the message may still reach the wallet although the liteserver answered
+// with an error, and a new query_id would send the payouts twice. A chunk
+// whose query_id is unknown, forgotten by the wallet, is never sent again.
+func (b *BatchSender) Send(ctx context.Context, chunks []*Chunk) (failed int) {
+	for i, c := range chunks {
+		if c.QueryID != 0 && c.Status != StatusExpired {
+			continue
+		}
+		c.Sent = false
+		c.Err = b.send(ctx, chunks, c, fmt.Sprintf("chunk %d of %d, %d messages", i+1, len(chunks), len(c.Messages)))
+		if c.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (b *BatchSender) send(ctx context.Context, chunks []*Chunk, c *Chunk, note string) error {
+	// The query_id is recorded before the message is sent, so it is never issued again
+	queryID, err := b.Journal.Issue(ctx, b.Timeout, note)
+	if err != nil {
+		return err
+	}
+	c.QueryID = queryID
+	c.Status = StatusPending
+	if b.Save != nil {
+		if err = b.Save(chunks); err != nil {
+			return err
+		}
+	}
+
+	q := Query{SubwalletID: b.SubwalletID, QueryID: queryID, Messages: c.Messages}
+	externalMessage, err := q.External(ctx, b.Signer, b.Wallet)
+	if err != nil {
+		return err
+	}
+	if err = message.Send(ctx, b.Client, externalMessage); err != nil {
+		return err
+	}
+	c.Sent = true
+	return nil
+}
+
+// Refresh reconciles the journal with the wallet and copies the status of
+// the query_id of every sent chunk into it.
+func (b *BatchSender) Refresh(ctx context.Context, api AccountAPI, chunks []*Chunk) error {
+	records, err := b.Journal.Reconcile(ctx, api, b.Wallet)
+	if err != nil {
+		return err
+	}
+	statuses := map[uint64]QueryStatus{}
+	for _, r := range records {
+		statuses[r.QueryID] = r.Status
+	}
+	for _, c := range chunks {
+		if status, ok := statuses[c.QueryID]; ok && c.QueryID != 0 {
+			c.Status = status
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package highload
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/signer"
+)
+
+var testWallet = address.NewAddress(0, 0, make([]byte, 32))
+
+// payouts returns n messages, each with a body of cells cells of bits bits.
+func payouts(n, cells, bits int) []message.Out {
+	messages := make([]message.Out, n)
+	for i := range messages {
+		internalMessage := &message.Internal{Dest: testWallet, Value: tlb.FromNanoTON(big.NewInt(int64(i + 1)))}
+		if cells > 0 {
+			internalMessage.Body, internalMessage.BodyRef = tree(uint64(i)<<32, cells, bits), true
+		}
+		messages[i] = message.Out{Mode: message.ModeDefault, Message: internalMessage.MustToCell()}
+	}
+	return messages
+}
+
+// tree returns a tree of n different cells of bits bits, every cell with up to 4 children.
+func tree(id uint64, n, bits int) *cell.Cell {
+	b := cell.BeginCell().MustStoreUInt(id, 64).MustStoreSlice(make([]byte, (bits-64+7)/8), uint(bits-64))
+	rest := n - 1
+	for child := 0; child < 4 && rest > 0; child++ {
+		size := (rest + 3 - child) / (4 - child)
+		b.MustStoreRef(tree(id+uint64(n-rest), size, bits))
+		rest -= size
+	}
+	return b.EndCell()
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []message.Out
+		chunks   []int // messages in every chunk
+	}{
+		{"none", nil, nil},
+		{"254 messages", payouts(MaxMessages, 0, 0), []int{MaxMessages}},
+		{"255 messages", payouts(MaxMessages+1, 0, 0), []int{MaxMessages, 1}},
+		{"cell limit", payouts(5, 2000, 64), []int{4, 1}},
+		{"bit limit", payouts(5, 500, 1023), []int{4, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := Split(tt.messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != len(tt.chunks) {
+				t.Fatalf("%d chunks, want %d", len(chunks), len(tt.chunks))
+			}
+			first := 0
+			for i, c := range chunks {
+				if len(c.Messages) != tt.chunks[i] || c.First != first {
+					t.Errorf("chunk %d has %d messages from %d, want %d from %d", i+1, len(c.Messages), c.First, tt.chunks[i], first)
+				}
+				first += len(c.Messages)
+
+				// The estimate must not be below the real external message
+				q := Query{QueryID: 1, Messages: c.Messages}
+				externalMessage, err := q.External(context.Background(), testSigner(t), testWallet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cells, bits := treeSize(externalMessage)
+				if cells > c.Cells || bits > c.Bits || c.Cells > MaxExternalCells || c.Bits > MaxExternalBits {
+					t.Errorf("chunk %d: %d cells and %d bits, estimated %d and %d", i+1, cells, bits, c.Cells, c.Bits)
+				}
+			}
+		})
+	}
+
+	t.Run("too big", func(t *testing.T) {
+		messages := append(payouts(1, 0, 0), payouts(1, MaxExternalCells, 64)...)
+		if _, err := Split(messages); !errors.Is(err, ErrMessageTooBig) {
+			t.Fatalf("error %v, want ErrMessageTooBig", err)
+		}
+	})
+}
+
+// sendCounter is a liteserver which accepts every external message.
+type sendCounter struct {
+	sent int
+}
+
+func (s *sendCounter) QueryLiteserver(_ context.Context, _ tl.Serializable, result tl.Serializable) error {
+	s.sent++
+	*result.(*tl.Serializable) = ton.SendMessageStatus{Status: 1}
+	return nil
+}
+
+func (s *sendCounter) StickyContext(ctx context.Context) context.Context { return ctx }
+func (s *sendCounter) StickyNodeID(context.Context) uint32               { return 0 }
+
+func TestBatchSender(t *testing.T) {
+	ctx := context.Background()
+	client := &sendCounter{}
+	sender := BatchSender{
+		Client:  client,
+		Journal: OpenJournal(filepath.Join(t.TempDir(), "queries.json"), testWallet),
+		Signer:  testSigner(t),
+		Wallet:  testWallet,
+		Timeout: time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		queryID uint64
+		status  QueryStatus
+		resend  bool
+	}{
+		{"new", 0, "", true},
+		{"pending", 1, StatusPending, false},
+		{"processed", 2, StatusProcessed, false},
+		{"unknown", 3, StatusUnknown, false},
+		{"expired", 4, StatusExpired, true},
+	}
+	var chunks []*Chunk
+	for _, tt := range tests {
+		chunks = append(chunks, &Chunk{Messages: payouts(1, 0, 0), QueryID: tt.queryID, Status: tt.status})
+	}
+	saved := 0
+	sender.Save = func([]*Chunk) error { saved++; return nil }
+
+	if failed := sender.Send(ctx, chunks); failed != 0 {
+		t.Fatalf("%d chunks failed", failed)
+	}
+	if client.sent != 2 || saved != 2 {
+		t.Errorf("%d chunks sent and %d saved, want 2", client.sent, saved)
+	}
+	for i, tt := range tests {
+		c := chunks[i]
+		if tt.resend != (c.QueryID != tt.queryID) || tt.resend != c.Sent {
+			t.Errorf("%s: query_id %d, sent %v", tt.name, c.QueryID, c.Sent)
+		}
+		if tt.resend && c.Status != StatusPending {
+			t.Errorf("%s: status %s after sending", tt.name, c.Status)
+		}
+	}
+
+	t.Run("save fails", func(t *testing.T) {
+		errSave := errors.New("disk full")
+		sender.Save = func([]*Chunk) error { return errSave }
+		client.sent = 0
+		c := &Chunk{Messages: payouts(1, 0, 0)}
+		if failed := sender.Send(ctx, []*Chunk{c}); failed != 1 || !errors.Is(c.Err, errSave) {
+			t.Fatalf("%d failed, error %v", failed, c.Err)
+		}
+		if client.sent != 0 || c.QueryID == 0 || c.Status != StatusPending {
+			t.Errorf("sent %d, query_id %d, status %s", client.sent, c.QueryID, c.Status)
+		}
+		// pending, so not sent again before it expires
+		if failed := sender.Send(ctx, []*Chunk{c}); failed != 0 || client.sent != 0 {
+			t.Errorf("sent again: %d failed, %d sent", failed, client.sent)
+		}
+	})
+}
+
+func TestPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payouts.csv.plan.json")
+	chunks, err := Split(payouts(MaxMessages+1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks[0].QueryID, chunks[0].Status, chunks[0].Sent = 7, StatusPending, true
+	if err = SavePlan(path, NewPlan(testWallet, "payouts.csv", "sum", chunks)); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPlan(path, testWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Split(payouts(MaxMessages+1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Apply(again); err != nil {
+		t.Fatal(err)
+	}
+	if again[0].QueryID != 7 || again[0].Status != StatusPending || !again[0].Sent || again[1].QueryID != 0 {
+		t.Errorf("applied %+v and %+v", *again[0], *again[1])
+	}
+
+	other, err := Split(payouts(MaxMessages+2, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Apply(other); !errors.Is(err, ErrPlanChanged) {
+		t.Errorf("another split: error %v, want ErrPlanChanged", err)
+	}
+	if _, err = LoadPlan(path, address.NewAddress(0, 0, append(make([]byte, 31), 1))); !errors.Is(err, ErrPlanWallet) {
+		t.Errorf("another wallet: error %v, want ErrPlanWallet", err)
+	}
+	if _, err = LoadPlan(path+".missing", testWallet); !errors.Is(err, ErrNoPlan) {
+		t.Errorf("no file: error %v, want ErrNoPlan", err)
+	}
+}
+
+func testSigner(t *testing.T) signer.Signer {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer.NewKeySigner(privateKey)
+}
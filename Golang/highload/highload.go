@@ -0,0 +1,147 @@
+// Package highload builds deploy and transfer messages for the highload wallet v2
+// contract from Chapter 5.
+package highload
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/signer"
+	"main/walletv3"
+)
+
+// MaxMessages is how many internal messages one query can carry.
+const MaxMessages = 254
+
+// codeBOC is the compiled highload wallet v2 code from Chapter 5.
+const codeBOC = "te6ccgEBCQEA5QABFP8A9KQT9LzyyAsBAgEgAgMCAUgEBQHq8oMI1xgg0x/TP/gjqh9TILnyY+1E0NMf0z/T//QE0VNggED0Dm+hMfJgUXO68qIH+QFUEIf5EPKjAvQE0fgAf44WIYAQ9HhvpSCYAtMH1DAB+wCRMuIBs+ZbgyWhyEA0gED0Q4rmMQHIyx8Tyz/L//QAye1UCAAE0DACASAGBwAXvZznaiaGmvmOuF/8AEG+X5dqJoaY+Y6Z/p/5j6AmipEEAgegc30JjJLb/JXdHxQANCCAQPSWb6VsEiCUMFMDud4gkzM2AZJsIeKz"
+
+var ErrTooManyMessages = errors.New("highload: query can carry at most 254 messages")
+
+var code = contract.MustLoadCode(codeBOC)
+
+// Code returns the highload wallet v2 code cell.
+func Code() *cell.Cell {
+	return code
+}
+
+// Data returns the initial data cell of a highload wallet.
+func Data(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(uint64(subwalletID), 32). // Subwallet ID
+		MustStoreUInt(0, 64).                   // Last cleaned
+		MustStoreSlice(publicKey, 256).         // Public Key
+		MustStoreBoolBit(false).                // indicate that the dictionary is empty
+		EndCell()
+}
+
+// StateInit returns the state init which deploys a highload wallet.
+func StateInit(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return contract.StateInit(Code(), Data(publicKey, subwalletID))
+}
+
+// Address returns the address of a highload wallet in workchain 0.
+func Address(publicKey ed25519.PublicKey, subwalletID uint32) *address.Address {
+	return address.NewAddress(0, 0, StateInit(publicKey, subwalletID).Hash())
+}
+
+// NewQueryID returns a query_id which expires after timeout: the expiration time
+// is in the high 32 bits and a random number is in the low 32 bits.
+// Two query_ids may get the same random number, Journal.Issue never repeats one.
+func NewQueryID(timeout time.Duration) uint64 {
+	expireAt := time.Now().Add(timeout).UTC().Unix() << 32
+	return uint64(expireAt) + uint64(rand.Uint32())
+}
+
+// Query is one signed request to a highload wallet.
+type Query struct {
+	SubwalletID uint32
+	QueryID     uint64
+	Messages    []message.Out
+}
+
+// Payload returns the part of the message which is signed.
+func (q *Query) Payload() (*cell.Builder, error) {
+	if len(q.Messages) > MaxMessages {
+		return nil, ErrTooManyMessages
+	}
+
+	dictionary := cell.NewDict(16) // the key is the index of the message, the value is mode + message
+	for i, m := range q.Messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("highload: message %d: %w", i, err)
+		}
+		messageData := cell.BeginCell().
+			MustStoreUInt(uint64(m.Mode), 8). // message mode
+			MustStoreRef(m.Message).
+			EndCell()
+
+		if err := dictionary.SetIntKey(big.NewInt(int64(i)), messageData); err != nil {
+			return nil, fmt.Errorf("highload: add message %d: %w", i, err)
+		}
+	}
+
+	return cell.BeginCell().
+		MustStoreUInt(uint64(q.SubwalletID), 32). // subwallet_id
+		MustStoreUInt(q.QueryID, 64).
+		MustStoreDict(dictionary), nil
+}
+
+// Sign signs the query with s and returns the body of the external message.
+func (q *Query) Sign(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	toSign, err := q.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.Sign(ctx, toSign.EndCell().Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreSlice(signature, 512). // store signature
+		MustStoreBuilder(toSign).       // store our message
+		EndCell(), nil
+}
+
+// External signs the query and wraps it into an external message to walletAddress.
+func (q *Query) External(ctx context.Context, s signer.Signer, walletAddress *address.Address) (*cell.Cell, error) {
+	body, err := q.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return message.External(walletAddress, nil, body), nil
+}
+
+// GetProcessed runs the "processed?" get method of a wallet. processed is true
+// if the query is in old_queries; forgotten is true if it is not there but is
+// older than last_cleaned, so the wallet does not know any more whether it
+// processed it.
+func GetProcessed(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address, queryID uint64) (processed, forgotten bool, err error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, false, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "processed?", new(big.Int).SetUint64(queryID))
+	if err != nil {
+		return false, false, fmt.Errorf("highload: run processed?: %w", err)
+	}
+	answer, err := result.Int(0)
+	if err != nil {
+		return false, false, fmt.Errorf("highload: read processed?: %w", err)
+	}
+	// found ? true : - (query_id <= last_cleaned), so -1 is processed and 1 is forgotten
+	return answer.Sign() < 0, answer.Sign() > 0, nil
+}
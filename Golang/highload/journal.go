@@ -0,0 +1,229 @@
+package highload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"main/jsonfile"
+)
+
+// QueryStatus is what a Journal knows about a query_id.
+type QueryStatus string
+
+const (
+	StatusPending   QueryStatus = "pending"   // issued, the wallet has not processed it and it has not expired
+	StatusProcessed QueryStatus = "processed" // the wallet sent its messages
+	StatusExpired   QueryStatus = "expired"   // not processed before it expired, the wallet will never accept it
+	StatusUnknown   QueryStatus = "unknown"   // the wallet forgot it, it may have been processed or not
+)
+
+// expireMargin is how long after the expiration of a query_id Reconcile waits
+// before it records it as expired: the liteserver answers from a block which
+// may be a little behind, and a block before the expiration may still have it.
+const expireMargin = 30 * time.Second
+
+const journalVersion = 1
+
+var (
+	ErrJournalWallet  = errors.New("highload: the journal is of another wallet")
+	ErrJournalVersion = errors.New("highload: unsupported journal version")
+	ErrJournalLocked  = jsonfile.ErrLocked // another sender holds the journal longer than the context allows
+)
+
+// QueryRecord is one query_id issued by a Journal.
+type QueryRecord struct {
+	QueryID  uint64      `json:"query_id"`
+	IssuedAt time.Time   `json:"issued_at"`
+	Status   QueryStatus `json:"status"`
+	Note     string      `json:"note,omitempty"` // what the query sends, for the people reading the journal
+}
+
+// ExpireAt returns the expiration time in the high 32 bits of the query_id.
+func (r *QueryRecord) ExpireAt() time.Time {
+	return SplitQueryID(r.QueryID).ExpireAt
+}
+
+// Journal is a JSON file with the query_ids issued for one highload wallet.
+// NewQueryID takes a random low part, so two queries may get the same
+// query_id; Issue takes a counter which is kept in the file instead, and the
+// file is locked while it is changed (see package jsonfile), so the query_ids
+// stay unique across restarts and between senders in other processes which
+// use the same file.
+type Journal struct {
+	path   string
+	wallet string
+	mu     sync.Mutex // between the senders of this process, the lock file is between processes
+}
+
+type journalFile struct {
+	Version int            `json:"version"`
+	Wallet  string         `json:"wallet"`
+	Counter uint32         `json:"counter"` // the low 32 bits of the next query_id
+	Queries []*QueryRecord `json:"queries"`
+}
+
+// OpenJournal returns the journal of walletAddress in the file at path. The
+// file is created by the first Issue.
+func OpenJournal(path string, walletAddress *address.Address) *Journal {
+	return &Journal{path: path, wallet: rawAddress(walletAddress)}
+}
+
+// Issue returns a new query_id which expires after timeout and records it as
+// pending with note.
+func (j *Journal) Issue(ctx context.Context, timeout time.Duration, note string) (uint64, error) {
+	var queryID uint64
+	err := j.update(ctx, func(f *journalFile) error {
+		expireAt := time.Now().Add(timeout).UTC().Unix() << 32
+		queryID = uint64(expireAt) + uint64(f.Counter)
+		f.Counter++ // the same low part comes again after 2^32 queries, long after their expiration
+		f.Queries = append(f.Queries, &QueryRecord{
+			QueryID:  queryID,
+			IssuedAt: time.Now().UTC(),
+			Status:   StatusPending,
+			Note:     note,
+		})
+		return nil
+	})
+	return queryID, err
+}
+
+// Records returns the issued query_ids, the oldest first.
+func (j *Journal) Records() ([]QueryRecord, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]QueryRecord, len(f.Queries))
+	for i, r := range f.Queries {
+		records[i] = *r
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a].IssuedAt.Before(records[b].IssuedAt) })
+	return records, nil
+}
+
+// Reconcile reads old_queries of the wallet (see GetState), records what
+// became of every pending query_id and returns all the records.
+//
+// A query_id the wallet has processed stays in old_queries until 64 seconds
+// after its expiration (see "bound -= (64 << 32)" in highload_wallet.fc), then
+// the wallet forgets it. So a query_id is expired only when it is past its
+// expiration and the wallet still remembers what it processed then; Reconcile
+// run later records a pending query_id as unknown, which the wallet may have
+// processed, and which must never be sent again with a new query_id.
+func (j *Journal) Reconcile(ctx context.Context, api AccountAPI, walletAddress *address.Address) ([]QueryRecord, error) {
+	if rawAddress(walletAddress) != j.wallet {
+		return nil, ErrJournalWallet
+	}
+	records, err := j.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	// One read of the state answers for every query_id, and runs without the
+	// lock: other senders may issue query_ids meanwhile
+	state, err := GetState(ctx, api, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	statuses := map[uint64]QueryStatus{}
+	for _, r := range records {
+		if r.Status != StatusPending {
+			continue
+		}
+		processed, forgotten := state.Processed(r.QueryID)
+		switch {
+		case processed:
+			statuses[r.QueryID] = StatusProcessed
+		case forgotten:
+			statuses[r.QueryID] = StatusUnknown
+		case time.Now().After(r.ExpireAt().Add(expireMargin)):
+			statuses[r.QueryID] = StatusExpired
+		}
+	}
+
+	err = j.update(ctx, func(f *journalFile) error {
+		for _, r := range f.Queries {
+			if status, ok := statuses[r.QueryID]; ok && r.Status == StatusPending {
+				r.Status = status
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return j.Records()
+}
+
+// Prune removes processed and expired query_ids which expired before, so the
+// file does not grow forever. The counter stays, so the query_ids stay unique.
+func (j *Journal) Prune(ctx context.Context, before time.Time) (removed int, err error) {
+	err = j.update(ctx, func(f *journalFile) error {
+		kept := f.Queries[:0]
+		for _, r := range f.Queries {
+			if r.Status != StatusPending && r.ExpireAt().Before(before) {
+				removed++
+				continue
+			}
+			kept = append(kept, r)
+		}
+		f.Queries = kept
+		return nil
+	})
+	return removed, err
+}
+
+// rawAddress returns the raw form of an address, the same wallet may come with other flags.
+func rawAddress(addr *address.Address) string {
+	return fmt.Sprintf("%d:%x", addr.Workchain(), addr.Data())
+}
+
+// update loads the journal, changes it with change and saves it, with the lock held.
+func (j *Journal) update(ctx context.Context, change func(f *journalFile) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	unlock, err := jsonfile.Lock(ctx, j.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := j.load()
+	if err != nil {
+		return err
+	}
+	if err = change(f); err != nil {
+		return err
+	}
+	return jsonfile.Save(j.path, f)
+}
+
+func (j *Journal) load() (*journalFile, error) {
+	var f journalFile
+	exists, err := jsonfile.Load(j.path, &f)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// A random start, so two journals of the same wallet are unlikely to give the same query_id
+		return &journalFile{Version: journalVersion, Wallet: j.wallet, Counter: rand.Uint32()}, nil
+	}
+	if f.Version != journalVersion {
+		return nil, ErrJournalVersion
+	}
+	if f.Wallet != j.wallet {
+		return nil, fmt.Errorf("%w: %s", ErrJournalWallet, f.Wallet)
+	}
+	return &f, nil
+}
@@ -0,0 +1,149 @@
+package highload
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// walletData returns the data of a highload wallet which remembers queries.
+func walletData(t *testing.T, publicKey ed25519.PublicKey, lastCleaned uint64, queries ...uint64) *cell.Cell {
+	oldQueries := cell.NewDict(64)
+	for _, q := range queries {
+		if err := oldQueries.Set(cell.BeginCell().MustStoreUInt(q, 64).EndCell(), cell.BeginCell().EndCell()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cell.BeginCell().
+		MustStoreUInt(7, 32).
+		MustStoreUInt(lastCleaned, 64).
+		MustStoreSlice(publicKey, 256).
+		MustStoreDict(oldQueries).
+		EndCell()
+}
+
+// accountAPI returns an active account with data.
+type accountAPI struct {
+	data *cell.Cell
+}
+
+func (a *accountAPI) CurrentMasterchainInfo(context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{}, nil
+}
+
+func (a *accountAPI) GetAccount(context.Context, *ton.BlockIDExt, *address.Address) (*tlb.Account, error) {
+	return &tlb.Account{IsActive: true, Data: a.data}, nil
+}
+
+func TestJournalIssue(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queries.json")
+
+	// two senders, as two processes, on one file
+	journals := []*Journal{OpenJournal(path, testWallet), OpenJournal(path, testWallet)}
+	results := make(chan uint64)
+	for _, j := range []*Journal{journals[0], journals[1], journals[0], journals[1]} {
+		go func(j *Journal) {
+			for i := 0; i < 10; i++ {
+				q, err := j.Issue(ctx, time.Minute, "test")
+				if err != nil {
+					t.Error(err)
+				}
+				results <- q
+			}
+		}(j)
+	}
+	seen := map[uint64]bool{}
+	for i := 0; i < 40; i++ {
+		q := <-results
+		if seen[q] {
+			t.Fatalf("query_id %d issued twice", q)
+		}
+		seen[q] = true
+	}
+
+	for _, j := range journals {
+		records, err := j.Records()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 40 {
+			t.Fatalf("%d records, want 40", len(records))
+		}
+		for _, r := range records {
+			if !seen[r.QueryID] || r.Status != StatusPending {
+				t.Errorf("record %+v", r)
+			}
+		}
+	}
+
+	other := address.NewAddress(0, 0, append(make([]byte, 31), 1))
+	if _, err := OpenJournal(path, other).Issue(ctx, time.Minute, ""); !errors.Is(err, ErrJournalWallet) {
+		t.Errorf("another wallet: error %v, want ErrJournalWallet", err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	journal := OpenJournal(filepath.Join(t.TempDir(), "queries.json"), testWallet)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration // negative for the ones already expired
+		status  QueryStatus
+	}{
+		{"processed", -10 * time.Minute, StatusProcessed},
+		{"forgotten", -10 * time.Minute, StatusUnknown},
+		{"expired and remembered", -5 * time.Minute, StatusExpired},
+		{"expired within the margin", -10 * time.Second, StatusPending},
+		{"not expired", 10 * time.Minute, StatusPending},
+	}
+	queryIDs := make([]uint64, len(tests))
+	for i, tt := range tests {
+		q, err := journal.Issue(ctx, tt.timeout, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queryIDs[i] = q
+	}
+
+	// The wallet remembers the first query_id and has forgotten the second
+	api := &accountAPI{data: walletData(t, make([]byte, 32), queryIDs[1], queryIDs[0])}
+	records, err := journal.Reconcile(ctx, api, testWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(records []QueryRecord) {
+		t.Helper()
+		statuses := map[uint64]QueryStatus{}
+		for _, r := range records {
+			statuses[r.QueryID] = r.Status
+		}
+		for i, tt := range tests {
+			if statuses[queryIDs[i]] != tt.status {
+				t.Errorf("%s: %s, want %s", tt.name, statuses[queryIDs[i]], tt.status)
+			}
+		}
+	}
+	check(records)
+
+	// Only pending query_ids change: the wallet has forgotten the processed one since
+	api.data = walletData(t, make([]byte, 32), queryIDs[2])
+	if records, err = journal.Reconcile(ctx, api, testWallet); err != nil {
+		t.Fatal(err)
+	}
+	check(records)
+
+	other := address.NewAddress(0, 0, append(make([]byte, 31), 1))
+	if _, err = journal.Reconcile(ctx, api, other); !errors.Is(err, ErrJournalWallet) {
+		t.Errorf("another wallet: error %v, want ErrJournalWallet", err)
+	}
+}
@@ -0,0 +1,99 @@
+package highload
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"main/jsonfile"
+)
+
+const planVersion = 1
+
+var (
+	ErrNoPlan      = errors.New("highload: no plan of the batch")
+	ErrPlanWallet  = errors.New("highload: the plan is of another wallet")
+	ErrPlanVersion = errors.New("highload: unsupported plan version")
+	ErrPlanChanged = errors.New("highload: the batch does not match its plan")
+)
+
+// Plan is a JSON file with the chunks of one batch: which messages went into
+// every chunk and its query_id. BatchSender.Save keeps it up to date, so after
+// a failure or a restart the same messages can be split again, given their
+// query_ids back with Apply and sent with BatchSender.Send, which sends only
+// the chunks whose query_ids expired.
+type Plan struct {
+	Version int         `json:"version"`
+	Wallet  string      `json:"wallet"`
+	Source  string      `json:"source"` // where the messages came from, the CSV file of cmd/highload
+	SHA256  string      `json:"sha256"` // of the source, a changed source would move the messages between chunks
+	Chunks  []PlanChunk `json:"chunks"`
+}
+
+// PlanChunk is one chunk of a Plan.
+type PlanChunk struct {
+	First   int         `json:"first"` // Chunk.First
+	Count   int         `json:"count"` // how many messages
+	QueryID uint64      `json:"query_id,omitempty"`
+	Status  QueryStatus `json:"status,omitempty"`
+	Sent    bool        `json:"sent,omitempty"`
+}
+
+// NewPlan returns the plan of chunks of walletAddress, whose messages came
+// from source with the SHA-256 sum.
+func NewPlan(walletAddress *address.Address, source, sum string, chunks []*Chunk) *Plan {
+	p := &Plan{Version: planVersion, Wallet: rawAddress(walletAddress), Source: source, SHA256: sum}
+	p.Record(chunks)
+	return p
+}
+
+// Record copies the query_id and the status of every chunk into the plan.
+func (p *Plan) Record(chunks []*Chunk) {
+	p.Chunks = make([]PlanChunk, len(chunks))
+	for i, c := range chunks {
+		p.Chunks[i] = PlanChunk{First: c.First, Count: len(c.Messages), QueryID: c.QueryID, Status: c.Status, Sent: c.Sent}
+	}
+}
+
+// Apply gives the chunks, split again from the same messages, the query_ids
+// and the statuses of the plan. It returns ErrPlanChanged if they were split
+// differently.
+func (p *Plan) Apply(chunks []*Chunk) error {
+	if len(chunks) != len(p.Chunks) {
+		return fmt.Errorf("%w: %d chunks, the plan has %d", ErrPlanChanged, len(chunks), len(p.Chunks))
+	}
+	for i, c := range chunks {
+		pc := p.Chunks[i]
+		if c.First != pc.First || len(c.Messages) != pc.Count {
+			return fmt.Errorf("%w: chunk %d has messages %d-%d, the plan has %d-%d",
+				ErrPlanChanged, i+1, c.First+1, c.First+len(c.Messages), pc.First+1, pc.First+pc.Count)
+		}
+		c.QueryID, c.Status, c.Sent = pc.QueryID, pc.Status, pc.Sent
+	}
+	return nil
+}
+
+// SavePlan writes p to the file at path.
+func SavePlan(path string, p *Plan) error {
+	return jsonfile.Save(path, p)
+}
+
+// LoadPlan reads the plan of walletAddress from the file at path.
+func LoadPlan(path string, walletAddress *address.Address) (*Plan, error) {
+	var p Plan
+	exists, err := jsonfile.Load(path, &p)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNoPlan, path)
+	}
+	if p.Version != planVersion {
+		return nil, ErrPlanVersion
+	}
+	if p.Wallet != rawAddress(walletAddress) {
+		return nil, fmt.Errorf("%w: %s", ErrPlanWallet, p.Wallet)
+	}
+	return &p, nil
+}
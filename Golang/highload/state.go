@@ -0,0 +1,111 @@
+package highload
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var ErrNotActive = errors.New("highload: the account is not active")
+
+// AccountAPI is the part of *ton.APIClient used to read the account.
+type AccountAPI interface {
+	CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+	GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error)
+}
+
+// State is the data of a highload wallet, the reverse of Data.
+type State struct {
+	SubwalletID uint32
+	LastCleaned uint64 // the newest query_id the wallet has forgotten
+	PublicKey   ed25519.PublicKey
+	OldQueries  []QueryIDParts // the query_ids the wallet processed and still remembers, the oldest first
+}
+
+// QueryIDParts is a query_id split into its two parts.
+type QueryIDParts struct {
+	QueryID  uint64
+	ExpireAt time.Time // the high 32 bits
+	Random   uint32    // the low 32 bits, random in NewQueryID, a counter in Journal.Issue
+}
+
+// SplitQueryID returns the expiration time and the low part of a query_id.
+func SplitQueryID(queryID uint64) QueryIDParts {
+	return QueryIDParts{
+		QueryID:  queryID,
+		ExpireAt: time.Unix(int64(queryID>>32), 0),
+		Random:   uint32(queryID),
+	}
+}
+
+// ParseData parses the data cell of a highload wallet:
+// subwallet_id:uint32 last_cleaned:uint64 public_key:bits256 old_queries:(HashmapE 64 Cell)
+func ParseData(data *cell.Cell) (*State, error) {
+	s := data.BeginParse()
+	subwalletID, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, fmt.Errorf("highload: subwallet_id: %w", err)
+	}
+	lastCleaned, err := s.LoadUInt(64)
+	if err != nil {
+		return nil, fmt.Errorf("highload: last_cleaned: %w", err)
+	}
+	publicKey, err := s.LoadSlice(256)
+	if err != nil {
+		return nil, fmt.Errorf("highload: public_key: %w", err)
+	}
+	oldQueries, err := s.LoadDict(64)
+	if err != nil {
+		return nil, fmt.Errorf("highload: old_queries: %w", err)
+	}
+
+	state := &State{
+		SubwalletID: uint32(subwalletID),
+		LastCleaned: lastCleaned,
+		PublicKey:   publicKey,
+	}
+	for _, kv := range oldQueries.All() { // the values are empty cells, only the keys matter
+		queryID, err := kv.Key.BeginParse().LoadUInt(64)
+		if err != nil {
+			return nil, fmt.Errorf("highload: old_queries key: %w", err)
+		}
+		state.OldQueries = append(state.OldQueries, SplitQueryID(queryID))
+	}
+	sort.Slice(state.OldQueries, func(i, j int) bool { return state.OldQueries[i].QueryID < state.OldQueries[j].QueryID })
+	return state, nil
+}
+
+// GetState reads the account of a highload wallet and parses its data.
+func GetState(ctx context.Context, api AccountAPI, walletAddress *address.Address) (*State, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := api.GetAccount(ctx, block, walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("highload: get account: %w", err)
+	}
+	if !account.IsActive || account.Data == nil {
+		return nil, ErrNotActive
+	}
+	return ParseData(account.Data)
+}
+
+// Processed answers like the "processed?" get method, without running it:
+// processed is true if queryID is in old_queries, forgotten is true if it is
+// not there but is not newer than LastCleaned.
+func (s *State) Processed(queryID uint64) (processed, forgotten bool) {
+	i := sort.Search(len(s.OldQueries), func(i int) bool { return s.OldQueries[i].QueryID >= queryID })
+	if i < len(s.OldQueries) && s.OldQueries[i].QueryID == queryID {
+		return true, false
+	}
+	return false, queryID <= s.LastCleaned
+}
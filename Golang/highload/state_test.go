@@ -0,0 +1,117 @@
+package highload
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func TestParseData(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := ParseData(Data(publicKey, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.SubwalletID != 42 || state.LastCleaned != 0 || !bytes.Equal(state.PublicKey, publicKey) || len(state.OldQueries) != 0 {
+		t.Errorf("parsed %+v", state)
+	}
+
+	// The keys come back sorted, whatever the order in the dictionary
+	queries := []uint64{30<<32 | 2, 10<<32 | 5, 20<<32 | 1}
+	state, err = ParseData(walletData(t, publicKey, 5<<32, queries...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{10<<32 | 5, 20<<32 | 1, 30<<32 | 2}
+	if len(state.OldQueries) != len(want) || state.LastCleaned != 5<<32 {
+		t.Fatalf("parsed %+v", state)
+	}
+	for i, q := range state.OldQueries {
+		if q.QueryID != want[i] {
+			t.Errorf("old query %d is %d, want %d", i, q.QueryID, want[i])
+		}
+	}
+
+	for _, bits := range []uint{0, 31, 32 + 64, 32 + 64 + 255} {
+		short := cell.BeginCell().MustStoreSlice(make([]byte, 64), bits).EndCell()
+		if _, err := ParseData(short); err == nil {
+			t.Errorf("data of %d bits was parsed", bits)
+		}
+	}
+}
+
+func TestProcessed(t *testing.T) {
+	state, err := ParseData(walletData(t, make([]byte, 32), 100<<32, 150<<32|3, 200<<32|1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		queryID   uint64
+		processed bool
+		forgotten bool
+	}{
+		{"remembered", 150<<32 | 3, true, false},
+		{"the last one", 200<<32 | 1, true, false},
+		{"not processed", 150<<32 | 4, false, false},
+		{"newer than all", 300 << 32, false, false},
+		{"last_cleaned itself", 100 << 32, false, true},
+		{"older", 50<<32 | 9, false, true},
+	}
+	for _, tt := range tests {
+		processed, forgotten := state.Processed(tt.queryID)
+		if processed != tt.processed || forgotten != tt.forgotten {
+			t.Errorf("%s: processed %v, forgotten %v", tt.name, processed, forgotten)
+		}
+	}
+}
+
+func TestSplitQueryID(t *testing.T) {
+	tests := []struct {
+		queryID  uint64
+		expireAt int64
+		random   uint32
+	}{
+		{0, 0, 0},
+		{1700000000<<32 | 7, 1700000000, 7},
+		{1<<63 | (1<<32 - 1), 1 << 31, 1<<32 - 1},
+	}
+	for _, tt := range tests {
+		parts := SplitQueryID(tt.queryID)
+		if parts.QueryID != tt.queryID || !parts.ExpireAt.Equal(time.Unix(tt.expireAt, 0)) || parts.Random != tt.random {
+			t.Errorf("SplitQueryID(%d) = %+v", tt.queryID, parts)
+		}
+	}
+
+	// NewQueryID puts the expiration time in the high part
+	before := time.Now().Add(time.Minute).Truncate(time.Second)
+	parts := SplitQueryID(NewQueryID(time.Minute))
+	if parts.ExpireAt.Before(before) || parts.ExpireAt.After(before.Add(time.Second)) {
+		t.Errorf("NewQueryID expires at %s, want about %s", parts.ExpireAt, before)
+	}
+}
+
+func TestGetState(t *testing.T) {
+	api := &accountAPI{data: walletData(t, make([]byte, 32), 1, 2)}
+	state, err := GetState(context.Background(), api, testWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.SubwalletID != 7 || state.LastCleaned != 1 || len(state.OldQueries) != 1 {
+		t.Errorf("state %+v", state)
+	}
+
+	api.data = nil
+	if _, err = GetState(context.Background(), api, testWallet); !errors.Is(err, ErrNotActive) {
+		t.Errorf("no data: error %v, want ErrNotActive", err)
+	}
+}
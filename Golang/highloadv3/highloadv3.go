@@ -0,0 +1,366 @@
+// Package highloadv3 builds deploy and transfer messages for the highload wallet
+// V3 contract from Chapter 6, the Go port of its TypeScript wrappers.
+//
+// Unlike the highload wallet v2 (package highload) the external message carries
+// one internal message, which the wallet usually sends to itself: its body is
+// internal_transfer with up to 254 out actions, and one of them may carry the
+// next internal_transfer, so a query sends any number of messages. A query_id
+// (see QueryID) is accepted once per timeout, the time after created_at in
+// which the external message is valid.
+package highloadv3
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/signer"
+	"main/walletv3"
+)
+
+// OpInternalTransfer is the op of the internal message the wallet sends to itself.
+const OpInternalTransfer = 0xae42e5a4
+
+// MaxActions is how many out actions one internal_transfer carries.
+const MaxActions = 254
+
+// DefaultSubwalletID and DefaultTimeout are the values of the wrappers of Chapter 6.
+const (
+	DefaultSubwalletID = 239
+	DefaultTimeout     = 128 // seconds
+)
+
+// MaxTimeout is the largest timeout, it is stored in 22 bits.
+const MaxTimeout = 1<<22 - 1
+
+// CreatedAtLag is how far in the past Batch puts created_at: the wallet
+// refuses a message created after the time of its block, and the time of the
+// liteservers is behind ours.
+const CreatedAtLag = time.Minute
+
+// Exit codes the wallet fails with.
+const (
+	ExitInvalidSignature = 33
+	ExitInvalidSubwallet = 34
+	ExitInvalidCreatedAt = 35 // created_at is in the future or more than the timeout ago
+	ExitAlreadyExecuted  = 36 // the query_id was used during the timeout
+)
+
+// actionSendMsg is the prefix of the out action which sends a message.
+const actionSendMsg = 0x0ec3c86d
+
+// codeBOC is the compiled highload wallet V3 code from Chapter 6.
+const codeBOC = "te6cckECEAEAAigAART/APSkE/S88sgLAQIBIAINAgFIAwQAeNAg10vAAQHAYLCRW+EB0NMDAXGwkVvg+kAw+CjHBbORMODTHwGCEK5C5aS6nYBA1yHXTPgqAe1V+wTgMAIBIAUKAgJzBgcAEa3OdqJoa4X/wAIBIAgJABqrtu1E0IEBItch1ws/ABiqO+1E0IMH1yHXCx8CASALDAAbuabu1E0IEBYtch1wsVgA5bi/Ltou37IasJAoQJsO1E0IEBINch9AT0BNM/0xXRBY4b+CMloVIQuZ8ybfgjBaoAFaESuZIwbd6SMDPikjAz4lIwgA30D2+hntAh1yHXCgCVXwN/2zHgkTDiWYAN9A9voZzQAdch1woAk3/bMeCRW+JwgB9vLUgwjXGNEh+QDtRNDT/9Mf9AT0BNM/0xXR+CMhoVIguY4SM234IySqAKESuZJtMt5Y+CMB3lQWdfkQ8qEG0NMf1NMH0wzTCdM/0xXRUWi68qJRWrrypvgjKqFSULzyowT4I7vyo1MEgA30D2+hmdAk1yHXCgDyZJEw4g4B/lMJgA30D2+hjhPQUATXGNIAAfJkyFjPFs+DAc8WjhAwyCTPQM+DhAlQBaGlFM9A4vgAyUA5gA30FwTIy/8Tyx/0ABL0ABLLPxLLFcntVPgPIdDTAAHyZdMCAXGwkl8D4PpAAdcLAcAA8qX6QDH6ADH0AfoAMfoAMYBg1yHTAAEPACDyZdIAAZPUMdGRMOJysfsAtYW/Aw=="
+
+var (
+	ErrTimeout        = errors.New("highloadv3: timeout is 22 bits, at most 4194303 seconds")
+	ErrShortTimeout   = errors.New("highloadv3: timeout must be longer than CreatedAtLag, 60 seconds")
+	ErrTooManyActions = errors.New("highloadv3: internal_transfer can carry at most 254 actions, use PackActions")
+	ErrNoMessages     = errors.New("highloadv3: no messages to send")
+)
+
+var code = contract.MustLoadCode(codeBOC)
+
+// Code returns the highload wallet V3 code cell.
+func Code() *cell.Cell {
+	return code
+}
+
+// Data returns the initial data cell of a wallet:
+// public_key:bits256 subwallet_id:uint32 old_queries:(HashmapE) queries:(HashmapE) last_clean_time:uint64 timeout:uint22
+// timeout is in seconds and is a part of the address, like the subwallet ID.
+// It must be longer than CreatedAtLag: Batch dates its requests that far in
+// the past, and a wallet with a shorter timeout would refuse all of them.
+func Data(publicKey ed25519.PublicKey, subwalletID, timeout uint32) (*cell.Cell, error) {
+	if timeout > MaxTimeout {
+		return nil, fmt.Errorf("%w: %d", ErrTimeout, timeout)
+	}
+	if time.Duration(timeout)*time.Second <= CreatedAtLag {
+		return nil, fmt.Errorf("%w: %d", ErrShortTimeout, timeout)
+	}
+	return cell.BeginCell().
+		MustStoreSlice(publicKey, 256).         // Public Key
+		MustStoreUInt(uint64(subwalletID), 32). // Subwallet ID
+		MustStoreDict(nil).                     // No old queries
+		MustStoreDict(nil).                     // No queries
+		MustStoreUInt(0, 64).                   // Last clean time
+		MustStoreUInt(uint64(timeout), 22).     // Timeout
+		EndCell(), nil
+}
+
+// StateInit returns the state init which deploys a wallet.
+func StateInit(publicKey ed25519.PublicKey, subwalletID, timeout uint32) (*cell.Cell, error) {
+	data, err := Data(publicKey, subwalletID, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return contract.StateInit(Code(), data), nil
+}
+
+// Address returns the address of a wallet in workchain 0.
+func Address(publicKey ed25519.PublicKey, subwalletID, timeout uint32) (*address.Address, error) {
+	stateInit, err := StateInit(publicKey, subwalletID, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return address.NewAddress(0, 0, stateInit.Hash()), nil
+}
+
+// Message is one signed request to a wallet: it sends Message with Mode.
+type Message struct {
+	SubwalletID uint32
+	Message     *cell.Cell // the internal message, see PackActions
+	Mode        message.SendMode
+	QueryID     QueryID
+	CreatedAt   time.Time
+	Timeout     uint32 // the timeout of the wallet, it refuses another one
+}
+
+// Payload returns the cell which is signed:
+// subwallet_id:uint32 message:^Cell mode:uint8 query_id:uint23 created_at:uint64 timeout:uint22
+func (m *Message) Payload() (*cell.Cell, error) {
+	if err := m.Mode.Validate(); err != nil {
+		return nil, fmt.Errorf("highloadv3: %w", err)
+	}
+	if m.Timeout > MaxTimeout {
+		return nil, fmt.Errorf("%w: %d", ErrTimeout, m.Timeout)
+	}
+	return cell.BeginCell().
+		MustStoreUInt(uint64(m.SubwalletID), 32). // subwallet_id
+		MustStoreRef(m.Message).
+		MustStoreUInt(uint64(m.Mode), 8).
+		MustStoreUInt(uint64(m.QueryID.Value()), 23).
+		MustStoreUInt(uint64(m.CreatedAt.Unix()), 64).
+		MustStoreUInt(uint64(m.Timeout), 22).
+		EndCell(), nil
+}
+
+// Sign signs the request with s and returns the body of the external message.
+func (m *Message) Sign(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	toSign, err := m.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.Sign(ctx, toSign.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreSlice(signature, 512). // store signature
+		MustStoreRef(toSign).           // the signed part is a reference, not in the same cell as in v2
+		EndCell(), nil
+}
+
+// External signs the request and wraps it into an external message to walletAddress.
+// stateInit is nil for a wallet which is already deployed; with it the first
+// request deploys the wallet, once the address has TON for the fees.
+func (m *Message) External(ctx context.Context, s signer.Signer, walletAddress *address.Address, stateInit *cell.Cell) (*cell.Cell, error) {
+	body, err := m.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return message.External(walletAddress, stateInit, body), nil
+}
+
+// OutList returns the out action list of the c5 register which sends messages:
+// out_list$_ prev:^OutList action:OutAction, so the first message is the deepest.
+func OutList(messages []message.Out) (*cell.Cell, error) {
+	if len(messages) > MaxActions {
+		return nil, ErrTooManyActions
+	}
+
+	outList := cell.BeginCell().EndCell() // out_list_empty$_
+	for i, m := range messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("highloadv3: message %d: %w", i+1, err)
+		}
+		outList = cell.BeginCell().
+			MustStoreRef(outList).
+			MustStoreUInt(actionSendMsg, 32).
+			MustStoreUInt(uint64(m.Mode), 8). // store mode of our internal message
+			MustStoreRef(m.Message).          // store our internal message as a reference
+			EndCell()
+	}
+	return outList, nil
+}
+
+// InternalTransferBody returns the body of the message the wallet sends to
+// itself to send messages: op, query_id:uint64 and the out list in a reference.
+func InternalTransferBody(queryID QueryID, messages []message.Out) (*cell.Cell, error) {
+	outList, err := OutList(messages)
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreUInt(OpInternalTransfer, 32).
+		MustStoreUInt(uint64(queryID.Value()), 64).
+		MustStoreRef(outList).
+		EndCell(), nil
+}
+
+// PackActions returns the internal message from the wallet to itself which
+// sends messages. If there are more than 254, the first 253 go into this
+// message and the last action is the next internal_transfer with the rest,
+// sent with mode 1, or mode 128 if value is zero. The wallet spends only the
+// fees of value: it sends it to itself.
+func PackActions(walletAddress *address.Address, messages []message.Out, value tlb.Coins, queryID QueryID) (*cell.Cell, error) {
+	if len(messages) == 0 {
+		return nil, ErrNoMessages
+	}
+
+	batch := messages
+	if len(messages) > MaxActions {
+		next, err := PackActions(walletAddress, messages[MaxActions-1:], value, queryID)
+		if err != nil {
+			return nil, err
+		}
+		mode := message.FlagPayFeesSeparately
+		if value.NanoTON().Sign() == 0 {
+			mode = message.ModeCarryAll
+		}
+		batch = append(messages[:MaxActions-1:MaxActions-1], message.Out{Mode: mode, Message: next})
+	}
+
+	body, err := InternalTransferBody(queryID, batch)
+	if err != nil {
+		return nil, err
+	}
+	return (&message.Internal{
+		Bounce:  true,
+		Dest:    walletAddress,
+		Value:   value,
+		Body:    body,
+		BodyRef: true,
+	}).ToCell()
+}
+
+// TransferGas is the value one internal_transfer needs for its gas, with room
+// to spare: the wallet sends it to itself, so what is not spent comes back.
+var TransferGas = tlb.MustFromTON("0.05")
+
+// Transfers returns how many internal_transfers PackActions chains for n messages.
+func Transfers(n int) int {
+	if n <= MaxActions {
+		return 1
+	}
+	return 1 + Transfers(n-(MaxActions-1))
+}
+
+// TransferValue returns the value for a Batch of n messages, TransferGas for
+// every internal_transfer PackActions chains. It is a lot less than the 1 TON
+// of Chapter 6, which the wallet needs to have on top of the messages.
+func TransferValue(n int) tlb.Coins {
+	return tlb.FromNanoTON(new(big.Int).Mul(TransferGas.NanoTON(), big.NewInt(int64(Transfers(n)))))
+}
+
+// Batch is a request which sends any number of messages through PackActions.
+type Batch struct {
+	SubwalletID uint32
+	Timeout     uint32
+	QueryID     QueryID
+	CreatedAt   time.Time // zero means CreatedAtLag ago
+	Value       tlb.Coins // sent with every internal_transfer, see TransferValue
+	Mode        message.SendMode
+	Messages    []message.Out
+}
+
+// External packs the messages, signs the request and wraps it into an external
+// message to walletAddress. stateInit is nil for a wallet which is already deployed.
+func (b *Batch) External(ctx context.Context, s signer.Signer, walletAddress *address.Address, stateInit *cell.Cell) (*cell.Cell, error) {
+	internalMessage, err := PackActions(walletAddress, b.Messages, b.Value, b.QueryID)
+	if err != nil {
+		return nil, err
+	}
+	createdAt := b.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().Add(-CreatedAtLag)
+	}
+	m := Message{
+		SubwalletID: b.SubwalletID,
+		Message:     internalMessage,
+		Mode:        b.Mode,
+		QueryID:     b.QueryID,
+		CreatedAt:   createdAt,
+		Timeout:     b.Timeout,
+	}
+	return m.External(ctx, s, walletAddress, stateInit)
+}
+
+// GetPublicKey runs the "get_public_key" get method, the same as the one of wallet V3.
+func GetPublicKey(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (ed25519.PublicKey, error) {
+	return walletv3.GetPublicKey(ctx, api, walletAddress)
+}
+
+// GetSubwalletID runs the "get_subwallet_id" get method of a wallet.
+func GetSubwalletID(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (uint32, error) {
+	value, err := runGetInt(ctx, api, walletAddress, "get_subwallet_id")
+	return uint32(value), err
+}
+
+// GetTimeout runs the "get_timeout" get method of a wallet, the timeout in seconds.
+func GetTimeout(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (uint32, error) {
+	value, err := runGetInt(ctx, api, walletAddress, "get_timeout")
+	return uint32(value), err
+}
+
+// GetLastCleanTime runs the "get_last_clean_time" get method of a wallet: the
+// time the wallet last moved the queries older than the timeout to old_queries.
+func GetLastCleanTime(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (time.Time, error) {
+	value, err := runGetInt(ctx, api, walletAddress, "get_last_clean_time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(value, 0), nil
+}
+
+// IsProcessed runs the "processed?" get method of a wallet: whether the query
+// with queryID was accepted. With needClean the get method first forgets the
+// queries the next transaction of the wallet would forget, as the wrappers do.
+func IsProcessed(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address, queryID QueryID, needClean bool) (bool, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	clean := big.NewInt(0)
+	if needClean {
+		clean.SetInt64(-1) // true
+	}
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "processed?", big.NewInt(int64(queryID.Value())), clean)
+	if err != nil {
+		return false, fmt.Errorf("highloadv3: run processed?: %w", err)
+	}
+	processed, err := result.Int(0)
+	if err != nil {
+		return false, fmt.Errorf("highloadv3: read processed?: %w", err)
+	}
+	return processed.Sign() != 0, nil // -1 is true
+}
+
+func runGetInt(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address, method string) (int64, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, method)
+	if err != nil {
+		return 0, fmt.Errorf("highloadv3: run %s: %w", method, err)
+	}
+	value, err := result.Int(0)
+	if err != nil {
+		return 0, fmt.Errorf("highloadv3: read %s: %w", method, err)
+	}
+	return value.Int64(), nil
+}
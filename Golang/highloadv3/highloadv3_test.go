@@ -0,0 +1,269 @@
+package highloadv3
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/jsonfile"
+	"main/message"
+)
+
+func TestQueryID(t *testing.T) {
+	tests := []struct {
+		name  string
+		q     QueryID
+		seqno uint32
+		next  QueryID
+		err   error
+	}{
+		{"first", QueryID{}, 0, QueryID{BitNumber: 1}, nil},
+		{"last bit of a shift", QueryID{Shift: 5, BitNumber: MaxBitNumber}, 5*(MaxBitNumber+1) + MaxBitNumber, QueryID{Shift: 6}, nil},
+		{"last shift", QueryID{Shift: MaxShift, BitNumber: MaxBitNumber - 2}, MaxSeqno - 1, QueryID{Shift: MaxShift, BitNumber: MaxBitNumber - 1}, nil},
+		{"last before the emergency one", QueryID{Shift: MaxShift, BitNumber: MaxBitNumber - 1}, MaxSeqno, QueryID{}, ErrOverload},
+		{"emergency", EmergencyQueryID, MaxSeqno + 1, QueryID{}, ErrOverload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.Seqno(); got != tt.seqno {
+				t.Errorf("Seqno = %d, want %d", got, tt.seqno)
+			}
+			if back, err := QueryIDFromSeqno(tt.seqno); err != nil || back != tt.q {
+				t.Errorf("QueryIDFromSeqno(%d) = %v, %v", tt.seqno, back, err)
+			}
+			if parsed, err := ParseQueryID(tt.q.Value()); err != nil || parsed != tt.q {
+				t.Errorf("ParseQueryID(%d) = %v, %v", tt.q.Value(), parsed, err)
+			}
+			if tt.q.HasNext() != (tt.err == nil) {
+				t.Errorf("HasNext = %v", tt.q.HasNext())
+			}
+			next, err := tt.q.Next()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Next error %v, want %v", err, tt.err)
+			}
+			if next != tt.next {
+				t.Errorf("Next = %v, want %v", next, tt.next)
+			}
+			if next == EmergencyQueryID {
+				t.Error("Next returned the emergency query_id")
+			}
+		})
+	}
+
+	t.Run("limits", func(t *testing.T) {
+		if _, err := NewQueryID(MaxShift+1, 0); !errors.Is(err, ErrShift) {
+			t.Errorf("error %v, want ErrShift", err)
+		}
+		if _, err := NewQueryID(0, MaxBitNumber+1); !errors.Is(err, ErrBitNumber) {
+			t.Errorf("error %v, want ErrBitNumber", err)
+		}
+		if EmergencyQueryID.Value() != 1<<23-2 {
+			t.Errorf("emergency query_id %d", EmergencyQueryID.Value())
+		}
+	})
+}
+
+func TestDataTimeout(t *testing.T) {
+	publicKey := make([]byte, 32)
+	tests := []struct {
+		timeout uint32
+		err     error
+	}{
+		{0, ErrShortTimeout},
+		{60, ErrShortTimeout},
+		{61, nil},
+		{DefaultTimeout, nil},
+		{MaxTimeout, nil},
+		{MaxTimeout + 1, ErrTimeout},
+	}
+	for _, tt := range tests {
+		if _, err := Data(publicKey, DefaultSubwalletID, tt.timeout); !errors.Is(err, tt.err) {
+			t.Errorf("timeout %d: error %v, want %v", tt.timeout, err, tt.err)
+		}
+	}
+}
+
+func TestPackActions(t *testing.T) {
+	walletAddress := address.NewAddress(0, 0, make([]byte, 32))
+	dest := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	out := func(n int) []message.Out {
+		messages := make([]message.Out, n)
+		for i := range messages {
+			messages[i] = message.Out{Mode: message.ModeDefault, Message: (&message.Internal{
+				Dest: dest, Value: tlb.FromNanoTON(big.NewInt(int64(i + 1))),
+			}).MustToCell()}
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name     string
+		messages int
+		value    tlb.Coins
+		chain    []int // actions of every internal_transfer, the next one included
+		nextMode message.SendMode
+	}{
+		{"one", 1, TransferValue(1), []int{1}, 0},
+		{"full", MaxActions, TransferValue(MaxActions), []int{MaxActions}, 0},
+		{"one more", MaxActions + 1, TransferValue(MaxActions + 1), []int{MaxActions, 2}, message.FlagPayFeesSeparately},
+		{"one more, zero value", MaxActions + 1, tlb.MustFromTON("0"), []int{MaxActions, 2}, message.ModeCarryAll},
+		{"three", 2*(MaxActions-1) + 2, TransferValue(2*(MaxActions-1) + 2), []int{MaxActions, MaxActions, 2}, message.FlagPayFeesSeparately},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Transfers(tt.messages); got != len(tt.chain) {
+				t.Errorf("Transfers = %d, want %d", got, len(tt.chain))
+			}
+			internalMessage, err := PackActions(walletAddress, out(tt.messages), tt.value, QueryID{Shift: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sent := 0
+			for i, want := range tt.chain {
+				m, err := message.ParseInternal(internalMessage)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m.Dest.String() != walletAddress.String() || m.Value.String() != tt.value.String() {
+					t.Fatalf("internal_transfer %d to %s with %s", i+1, m.Dest, m.Value)
+				}
+				actions := outActions(t, m.Body)
+				if len(actions) != want {
+					t.Fatalf("internal_transfer %d has %d actions, want %d", i+1, len(actions), want)
+				}
+				for j, a := range actions {
+					if i < len(tt.chain)-1 && j == len(actions)-1 {
+						if a.Mode != tt.nextMode {
+							t.Errorf("the next internal_transfer is sent with mode %d, want %d", a.Mode, tt.nextMode)
+						}
+						internalMessage = a.Message
+						continue
+					}
+					sent++
+					next, err := message.ParseInternal(a.Message)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if next.Value.NanoTON().Int64() != int64(sent) {
+						t.Fatalf("message %d is %s, the messages are out of order", sent, next.Value)
+					}
+				}
+			}
+			if sent != tt.messages {
+				t.Errorf("%d messages sent, want %d", sent, tt.messages)
+			}
+		})
+	}
+
+	if _, err := PackActions(walletAddress, nil, TransferValue(0), QueryID{}); !errors.Is(err, ErrNoMessages) {
+		t.Errorf("no messages: error %v, want ErrNoMessages", err)
+	}
+	if _, err := OutList(out(MaxActions + 1)); !errors.Is(err, ErrTooManyActions) {
+		t.Errorf("255 actions: error %v, want ErrTooManyActions", err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queries.json")
+	walletAddress := address.NewAddress(0, 0, make([]byte, 32))
+
+	for i := uint32(0); i < 3; i++ {
+		// a new Journal every time, as after a restart
+		q, err := OpenJournal(path, walletAddress, DefaultTimeout).Issue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Seqno() != i {
+			t.Fatalf("query_id %v, want seqno %d", q, i)
+		}
+	}
+
+	other := address.NewAddress(0, 0, append(make([]byte, 31), 1))
+	if _, err := OpenJournal(path, other, DefaultTimeout).Issue(ctx); !errors.Is(err, ErrJournalWallet) {
+		t.Errorf("another wallet: error %v, want ErrJournalWallet", err)
+	}
+
+	t.Run("last query_id", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			lastIssued time.Duration // ago
+			err        error
+		}{
+			{"within the timeout", time.Minute, ErrOverload},
+			{"remembered for twice the timeout", 2*DefaultTimeout*time.Second - time.Minute, ErrOverload},
+			{"forgotten", 2*DefaultTimeout*time.Second + time.Minute, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "queries.json")
+				j := OpenJournal(path, walletAddress, DefaultTimeout)
+				last := uint32(MaxSeqno)
+				f := &journalFile{Version: journalVersion, Wallet: j.wallet, Last: &last, LastIssued: time.Now().Add(-tt.lastIssued)}
+				if err := jsonfile.Save(path, f); err != nil {
+					t.Fatal(err)
+				}
+				q, err := j.Issue(ctx)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				if err == nil && q != (QueryID{}) {
+					t.Fatalf("query_id %v, want the first one again", q)
+				}
+			})
+		}
+	})
+
+	t.Run("two journals on one file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "queries.json")
+		a, b := OpenJournal(path, walletAddress, DefaultTimeout), OpenJournal(path, walletAddress, DefaultTimeout)
+		seen := map[QueryID]bool{}
+		results := make(chan QueryID)
+		for _, j := range []*Journal{a, b, a, b} {
+			go func(j *Journal) {
+				for i := 0; i < 10; i++ {
+					q, err := j.Issue(ctx)
+					if err != nil {
+						t.Error(err)
+					}
+					results <- q
+				}
+			}(j)
+		}
+		for i := 0; i < 40; i++ {
+			q := <-results
+			if seen[q] {
+				t.Fatalf("query_id %v issued twice", q)
+			}
+			seen[q] = true
+		}
+	})
+}
+
+// outActions reads the out list of an internal_transfer body in the order of the messages.
+func outActions(t *testing.T, body *cell.Cell) []message.Out {
+	s := body.BeginParse()
+	if op := s.MustLoadUInt(32); op != OpInternalTransfer {
+		t.Fatalf("op %x", op)
+	}
+	s.MustLoadUInt(64) // query_id
+	var actions []message.Out
+	for list := s.MustLoadRef(); list.RefsNum() > 0; {
+		prev := list.MustLoadRef()
+		if prefix := list.MustLoadUInt(32); prefix != actionSendMsg {
+			t.Fatalf("action prefix %x", prefix)
+		}
+		mode := message.SendMode(list.MustLoadUInt(8))
+		actions = append([]message.Out{{Mode: mode, Message: list.MustLoadRef().MustToCell()}}, actions...)
+		list = prev
+	}
+	return actions
+}
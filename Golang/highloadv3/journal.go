@@ -0,0 +1,103 @@
+package highloadv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"main/jsonfile"
+)
+
+const journalVersion = 2
+
+var (
+	ErrJournalWallet  = errors.New("highloadv3: the journal is of another wallet")
+	ErrJournalVersion = errors.New("highloadv3: unsupported journal version")
+	ErrJournalLocked  = jsonfile.ErrLocked // another sender holds the journal longer than the context allows
+)
+
+// Journal is a JSON file with the last query_id issued for one highload
+// wallet V3. Issue takes the Next of the one in the file, and the file is
+// locked while it is changed (see package jsonfile), so the query_ids stay
+// unique across restarts and between senders in other processes which use
+// the same file.
+//
+// After the last query_id Issue starts again from the first, but only once
+// the wallet has forgotten every query_id of the round: it keeps a processed
+// query_id for up to twice its timeout, so Issue waits that long after the
+// last one it issued.
+type Journal struct {
+	path    string
+	wallet  string
+	timeout time.Duration
+	mu      sync.Mutex // between the senders of this process, the lock file is between processes
+}
+
+type journalFile struct {
+	Version    int       `json:"version"`
+	Wallet     string    `json:"wallet"`
+	Last       *uint32   `json:"last,omitempty"` // the Seqno of the last query_id, none before the first Issue
+	LastIssued time.Time `json:"last_issued"`    // when Issue returned it
+}
+
+// OpenJournal returns the journal of walletAddress, whose timeout is in
+// seconds, in the file at path. The file is created by the first Issue.
+func OpenJournal(path string, walletAddress *address.Address, timeout uint32) *Journal {
+	return &Journal{
+		path:    path,
+		wallet:  fmt.Sprintf("%d:%x", walletAddress.Workchain(), walletAddress.Data()),
+		timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// Issue returns the query_id after the last one it issued, or the first
+// query_id for a new journal. It returns ErrOverload if all the query_ids
+// were issued and the wallet may still remember the last of them.
+func (j *Journal) Issue(ctx context.Context) (QueryID, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var (
+		f journalFile
+		q QueryID
+	)
+	err := jsonfile.Update(ctx, j.path, &f, func(exists bool) error {
+		if !exists {
+			f = journalFile{Version: journalVersion, Wallet: j.wallet}
+		}
+		if f.Version == 1 { // had the start of the round, not the last issue: count from now
+			f.Version, f.LastIssued = journalVersion, time.Now().UTC()
+		}
+		if f.Version != journalVersion {
+			return ErrJournalVersion
+		}
+		if f.Wallet != j.wallet {
+			return fmt.Errorf("%w: %s", ErrJournalWallet, f.Wallet)
+		}
+
+		if f.Last != nil {
+			last, err := QueryIDFromSeqno(*f.Last)
+			if err != nil {
+				return fmt.Errorf("highloadv3: journal %s: %w", j.path, err)
+			}
+			q, err = last.Next()
+			if errors.Is(err, ErrOverload) && time.Since(f.LastIssued) > 2*j.timeout {
+				q, err = QueryID{}, nil // a new round
+			}
+			if err != nil {
+				return err
+			}
+		}
+		seqno := q.Seqno()
+		f.Last, f.LastIssued = &seqno, time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return QueryID{}, err
+	}
+	return q, nil
+}
@@ -0,0 +1,87 @@
+package highloadv3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A query_id of highload wallet V3 is 23 bits: a shift (13 bits) selects a cell of
+// the processed queries dictionary and a bit number (10 bits) a bit in it.
+const (
+	bitNumberSize = 10
+	MaxShift      = 1<<13 - 1 // 8191
+	MaxBitNumber  = 1022      // the bit 1023 of every shift is never used
+
+	// MaxSeqno is the last sequence number Next reaches, see QueryID.Seqno.
+	MaxSeqno = MaxShift*(MaxBitNumber+1) + MaxBitNumber - 1
+)
+
+var (
+	ErrShift     = errors.New("highloadv3: shift is 13 bits, at most 8191")
+	ErrBitNumber = errors.New("highloadv3: bit number is at most 1022")
+	ErrOverload  = errors.New("highloadv3: no query_id left, only the emergency one")
+)
+
+// QueryID is a query_id of highload wallet V3, HighloadQueryId of Chapter 6.
+// The wallet accepts every query_id once per timeout, so a sender keeps the
+// last one it used and takes the Next, see Journal.
+type QueryID struct {
+	Shift     uint16 // 0 to MaxShift
+	BitNumber uint16 // 0 to MaxBitNumber
+}
+
+// EmergencyQueryID is the last query_id. Next never returns it: it stays free
+// to withdraw the TON when all the others are taken.
+var EmergencyQueryID = QueryID{Shift: MaxShift, BitNumber: MaxBitNumber}
+
+// NewQueryID checks shift and bitNumber and returns their query_id.
+func NewQueryID(shift, bitNumber uint16) (QueryID, error) {
+	if shift > MaxShift {
+		return QueryID{}, fmt.Errorf("%w: %d", ErrShift, shift)
+	}
+	if bitNumber > MaxBitNumber {
+		return QueryID{}, fmt.Errorf("%w: %d", ErrBitNumber, bitNumber)
+	}
+	return QueryID{Shift: shift, BitNumber: bitNumber}, nil
+}
+
+// ParseQueryID splits the 23-bit query_id stored in a message.
+func ParseQueryID(id uint32) (QueryID, error) {
+	return NewQueryID(uint16(id>>bitNumberSize), uint16(id&(1<<bitNumberSize-1)))
+}
+
+// QueryIDFromSeqno returns the query_id with sequence number n, see QueryID.Seqno.
+func QueryIDFromSeqno(n uint32) (QueryID, error) {
+	return NewQueryID(uint16(n/(MaxBitNumber+1)), uint16(n%(MaxBitNumber+1)))
+}
+
+// Value returns the 23-bit query_id stored in a message.
+func (q QueryID) Value() uint32 {
+	return uint32(q.Shift)<<bitNumberSize + uint32(q.BitNumber)
+}
+
+// Seqno returns the number of q in the order of Next, 0 to MaxSeqno.
+func (q QueryID) Seqno() uint32 {
+	return uint32(q.Shift)*(MaxBitNumber+1) + uint32(q.BitNumber)
+}
+
+// HasNext reports whether Next returns a query_id.
+func (q QueryID) HasNext() bool {
+	return !(q.Shift == MaxShift && q.BitNumber >= MaxBitNumber-1)
+}
+
+// Next returns the query_id after q. The one after the last is
+// EmergencyQueryID, so it returns ErrOverload instead.
+func (q QueryID) Next() (QueryID, error) {
+	if !q.HasNext() {
+		return QueryID{}, ErrOverload
+	}
+	if q.BitNumber == MaxBitNumber {
+		return QueryID{Shift: q.Shift + 1}, nil
+	}
+	return QueryID{Shift: q.Shift, BitNumber: q.BitNumber + 1}, nil
+}
+
+func (q QueryID) String() string {
+	return fmt.Sprintf("%d (shift %d, bit %d)", q.Value(), q.Shift, q.BitNumber)
+}
@@ -0,0 +1,125 @@
+package inspect
+
+import (
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/walletv5"
+)
+
+// actionSendMsg is the prefix of the out action which sends a message, the
+// same in wallet V5 and in highload wallet V3.
+const actionSendMsg = 0x0ec3c86d
+
+// ExtendedAction is an action of a wallet V5 request which is not a message.
+type ExtendedAction struct {
+	Op        uint8            // walletv5.ActionAddExtension, ActionDeleteExtension or ActionSetSignatureAuth
+	Extension *address.Address // add and delete
+	Allowed   bool             // set signature auth
+}
+
+// Describe returns the action in one line.
+func (a ExtendedAction) Describe(formatAddress func(*address.Address) string) string {
+	switch a.Op {
+	case walletv5.ActionAddExtension:
+		return "add extension " + formatAddress(a.Extension)
+	case walletv5.ActionDeleteExtension:
+		return "remove extension " + formatAddress(a.Extension)
+	case walletv5.ActionSetSignatureAuth:
+		if a.Allowed {
+			return "allow requests signed by the key"
+		}
+		return "forbid requests signed by the key"
+	}
+	return fmt.Sprintf("unknown action 0x%02x", a.Op)
+}
+
+// loadOutList reads an out action list of the c5 register:
+// out_list$_ prev:^OutList action:OutAction, so the first message is the deepest.
+func loadOutList(list *cell.Slice) ([]Message, error) {
+	var reversed []Message
+	for list.RefsNum() > 0 {
+		prev, err := list.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := list.LoadUInt(32)
+		if err != nil {
+			return nil, err
+		}
+		if prefix != actionSendMsg {
+			return nil, fmt.Errorf("inspect: out action 0x%08x is not send_msg", prefix)
+		}
+		mode, err := list.LoadUInt(8)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := list.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+		m, err := newMessage(message.SendMode(mode), ref)
+		if err != nil {
+			return nil, fmt.Errorf("inspect: out action %d from the end: %w", len(reversed)+1, err)
+		}
+		reversed = append(reversed, m)
+		list = prev
+	}
+
+	messages := make([]Message, len(reversed))
+	for i, m := range reversed {
+		messages[len(reversed)-1-i] = m
+	}
+	return messages, nil
+}
+
+// loadV5Actions reads the inner request of wallet V5:
+// out_actions:(Maybe ^OutList) has_other_actions:(## 1) other_actions:ActionList
+// The first other action is in s, every next one in a reference of the previous.
+func loadV5Actions(s *cell.Slice) ([]Message, []ExtendedAction, error) {
+	outList, err := s.LoadMaybeRef()
+	if err != nil {
+		return nil, nil, err
+	}
+	var messages []Message
+	if outList != nil {
+		if messages, err = loadOutList(outList); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	hasOther, err := s.LoadBoolBit()
+	if err != nil || !hasOther {
+		return messages, nil, err
+	}
+	var extended []ExtendedAction
+	for {
+		prefix, err := s.LoadUInt(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		a := ExtendedAction{Op: uint8(prefix)}
+		switch prefix {
+		case walletv5.ActionAddExtension, walletv5.ActionDeleteExtension:
+			a.Extension, err = s.LoadAddr()
+		case walletv5.ActionSetSignatureAuth:
+			a.Allowed, err = s.LoadBoolBit()
+		default:
+			return nil, nil, fmt.Errorf("inspect: unknown wallet v5 action 0x%02x", prefix)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("inspect: wallet v5 action %d: %w", len(extended)+1, err)
+		}
+		extended = append(extended, a)
+
+		if s.RefsNum() == 0 {
+			return messages, extended, nil
+		}
+		if s, err = s.LoadRef(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
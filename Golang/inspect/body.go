@@ -0,0 +1,360 @@
+package inspect
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/comment"
+	"main/highloadv3"
+	"main/jetton"
+	"main/multisig"
+	"main/nft"
+	"main/walletv4"
+	"main/walletv5"
+)
+
+// Opcodes of the bodies Body knows.
+const (
+	OpComment          = comment.OpText
+	OpEncryptedComment = comment.OpEncrypted
+	OpNFTTransfer      = nft.OpTransfer
+	OpJettonTransfer   = jetton.OpTransfer
+	OpJettonBurn       = jetton.OpBurn
+	OpExcesses         = jetton.OpExcesses
+
+	OpInternalTransfer = highloadv3.OpInternalTransfer // highload wallet v3 to itself
+	OpSignedInternal   = walletv5.OpSignedInternal     // "sint" to a wallet v5
+	OpExtensionAction  = walletv5.OpExtensionAction    // "extn" to a wallet v5
+	OpNewOrder         = multisig.OpNewOrder
+	OpApproveOrder     = multisig.OpApprove
+	OpPluginInstalled  = walletv4.OpPluginInstalled // "note", wallet v4 to a plugin
+	OpPluginRemoved    = walletv4.OpPluginRemoved   // "dstr"
+)
+
+// Body is the decoded body of an internal message.
+type Body struct {
+	Op      uint32
+	Known   bool   // Op is one of the opcodes above and the body was read without errors
+	Comment string // OpComment
+
+	QueryID     uint64
+	Amount      tlb.Coins        // jetton units for jettons, not TON
+	Destination *address.Address // new owner of the NFT or jetton wallet owner
+	Response    *address.Address // where the excess TON goes
+	ForwardTON  tlb.Coins
+
+	// OpInternalTransfer, OpSignedInternal and OpExtensionAction carry messages
+	// for the wallet to send, the wallet v5 ones also other actions.
+	Messages []Message
+	Extended []ExtendedAction
+
+	WalletID   uint32    // OpSignedInternal
+	Seqno      uint32    // OpSignedInternal
+	ValidUntil time.Time // OpSignedInternal, the expiration_date of OpNewOrder
+
+	OrderSeqno *big.Int          // OpNewOrder, multisig.MaxOrderSeqno for the next one
+	IsSigner   bool              // OpNewOrder: Index is among the signers, else among the proposers
+	Index      uint8             // OpNewOrder and OpApproveOrder
+	Order      []multisig.Action // OpNewOrder
+
+	Raw *cell.Cell // the body as is, for the ones not known
+}
+
+// DecodeBody decodes a text comment, an NFT or jetton transfer, a jetton burn,
+// the requests to highload wallet v3, wallet v5 and wallet v4 plugins, and the
+// messages to a multisig and its orders. Anything else is returned with Known
+// false and its opcode.
+func DecodeBody(body *cell.Cell) Body {
+	b := Body{Raw: body}
+	if body == nil {
+		return b
+	}
+
+	s := body.BeginParse()
+	op32, err := s.LoadUInt(32)
+	if err != nil {
+		return b
+	}
+	op := uint32(op32)
+	b.Op = op
+
+	switch op {
+	case OpComment:
+		b.Comment, err = comment.Decode(body)
+	case OpEncryptedComment:
+		// the text can be read only with the key of the sender or the receiver, see comment.Decrypt
+	case OpNFTTransfer:
+		err = b.loadNFTTransfer(s)
+	case OpJettonTransfer:
+		err = b.loadJettonTransfer(s)
+	case OpJettonBurn:
+		err = b.loadJettonBurn(s)
+	case OpExcesses, OpPluginInstalled, OpPluginRemoved:
+		b.QueryID, err = s.LoadUInt(64)
+	case OpInternalTransfer:
+		err = b.loadInternalTransfer(s)
+	case OpSignedInternal:
+		err = b.loadSignedInternal(s)
+	case OpExtensionAction:
+		if b.QueryID, err = s.LoadUInt(64); err == nil {
+			b.Messages, b.Extended, err = loadV5Actions(s)
+		}
+	case OpNewOrder:
+		err = b.loadNewOrder(s)
+	case OpApproveOrder:
+		err = b.loadApprove(s)
+	default:
+		return b
+	}
+	b.Known = err == nil
+	return b
+}
+
+// transfer#5fcc3d14 query_id:uint64 new_owner:MsgAddress response_destination:MsgAddress
+// custom_payload:(Maybe ^Cell) forward_amount:(VarUInteger 16) forward_payload:(Either Cell ^Cell)
+func (b *Body) loadNFTTransfer(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	if b.Destination, err = s.LoadAddr(); err != nil {
+		return err
+	}
+	if b.Response, err = s.LoadAddr(); err != nil {
+		return err
+	}
+	if _, err = s.LoadMaybeRef(); err != nil {
+		return err
+	}
+	b.ForwardTON, err = loadCoins(s)
+	return err
+}
+
+// transfer#0f8a7ea5 query_id:uint64 amount:(VarUInteger 16) destination:MsgAddress
+// response_destination:MsgAddress custom_payload:(Maybe ^Cell)
+// forward_ton_amount:(VarUInteger 16) forward_payload:(Either Cell ^Cell)
+func (b *Body) loadJettonTransfer(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	if b.Amount, err = loadCoins(s); err != nil {
+		return err
+	}
+	if b.Destination, err = s.LoadAddr(); err != nil {
+		return err
+	}
+	if b.Response, err = s.LoadAddr(); err != nil {
+		return err
+	}
+	if _, err = s.LoadMaybeRef(); err != nil {
+		return err
+	}
+	b.ForwardTON, err = loadCoins(s)
+	return err
+}
+
+// burn#595f07bc query_id:uint64 amount:(VarUInteger 16)
+// response_destination:MsgAddress custom_payload:(Maybe ^Cell)
+func (b *Body) loadJettonBurn(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	if b.Amount, err = loadCoins(s); err != nil {
+		return err
+	}
+	b.Response, err = s.LoadAddr()
+	return err
+}
+
+// internal_transfer#ae42e5a4 query_id:uint64 actions:^OutList
+func (b *Body) loadInternalTransfer(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	outList, err := s.LoadRef()
+	if err != nil {
+		return err
+	}
+	b.Messages, err = loadOutList(outList)
+	return err
+}
+
+// The body of a signed external request of wallet v5 with op "sint":
+// wallet_id:uint32 valid_until:uint32 seqno:uint32 actions signature:bits512
+// The signature is not checked, the wallet does it.
+func (b *Body) loadSignedInternal(s *cell.Slice) error {
+	if s.BitsLeft() < 96+2+512 {
+		return errors.New("inspect: signed request is too short")
+	}
+	fields, err := loadFields(s, "signed request", 32, 32, 32)
+	if err != nil {
+		return err
+	}
+	b.WalletID = uint32(fields[0])
+	b.ValidUntil = time.Unix(int64(fields[1]), 0).UTC()
+	b.Seqno = uint32(fields[2])
+
+	// the actions are followed by the signature, cut it off first
+	bits := s.BitsLeft() - 512
+	data, err := s.LoadSlice(bits)
+	if err != nil {
+		return err
+	}
+	actions := cell.BeginCell().MustStoreSlice(data, bits)
+	for s.RefsNum() > 0 {
+		ref, err := loadRefCell(s)
+		if err != nil {
+			return fmt.Errorf("inspect: signed request actions: %w", err)
+		}
+		actions.MustStoreRef(ref)
+	}
+	b.Messages, b.Extended, err = loadV5Actions(actions.EndCell().BeginParse())
+	return err
+}
+
+// new_order#f718510f query_id:uint64 order_seqno:uint256 signer:Bool index:uint8
+// expiration_date:uint48 order:^Order
+func (b *Body) loadNewOrder(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	if b.OrderSeqno, err = s.LoadBigUInt(256); err != nil {
+		return err
+	}
+	if b.IsSigner, err = s.LoadBoolBit(); err != nil {
+		return err
+	}
+	index, err := s.LoadUInt(8)
+	if err != nil {
+		return err
+	}
+	b.Index = uint8(index)
+	expireAt, err := s.LoadUInt(48)
+	if err != nil {
+		return err
+	}
+	b.ValidUntil = time.Unix(int64(expireAt), 0).UTC()
+	order, err := loadRefCell(s)
+	if err != nil {
+		return err
+	}
+	b.Order, err = multisig.ParseActions(order)
+	return err
+}
+
+// approve#a762230f query_id:uint64 signer_index:uint8
+func (b *Body) loadApprove(s *cell.Slice) error {
+	var err error
+	if b.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	index, err := s.LoadUInt(8)
+	b.Index = uint8(index)
+	return err
+}
+
+func loadRefCell(s *cell.Slice) (*cell.Cell, error) {
+	ref, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+	return ref.ToCell()
+}
+
+func loadCoins(s *cell.Slice) (tlb.Coins, error) {
+	v, err := s.LoadBigCoins()
+	if err != nil {
+		return tlb.Coins{}, err
+	}
+	return tlb.FromNanoTON(v), nil
+}
+
+// Describe returns the body in one line.
+func (b Body) Describe(formatAddress func(*address.Address) string) string {
+	if formatAddress == nil {
+		formatAddress = (*address.Address).String
+	}
+	if !b.Known {
+		if b.Raw != nil && b.Raw.BitsSize() < 32 {
+			return "raw " + hex.EncodeToString(b.Raw.ToBOC())
+		}
+		return fmt.Sprintf("unknown op 0x%08x", b.Op)
+	}
+
+	switch b.Op {
+	case OpComment:
+		return fmt.Sprintf("comment %q", b.Comment)
+	case OpEncryptedComment:
+		return "encrypted comment"
+	case OpNFTTransfer:
+		return fmt.Sprintf("NFT transfer to %s, response to %s, forward %s TON, query_id %d",
+			formatAddress(b.Destination), describeAddress(b.Response, formatAddress), b.ForwardTON.String(), b.QueryID)
+	case OpJettonTransfer:
+		return fmt.Sprintf("jetton transfer of %s units to %s, response to %s, forward %s TON, query_id %d",
+			b.Amount.NanoTON().String(), formatAddress(b.Destination), describeAddress(b.Response, formatAddress), b.ForwardTON.String(), b.QueryID)
+	case OpJettonBurn:
+		return fmt.Sprintf("jetton burn of %s units, response to %s, query_id %d",
+			b.Amount.NanoTON().String(), describeAddress(b.Response, formatAddress), b.QueryID)
+	case OpExcesses:
+		return fmt.Sprintf("excesses, query_id %d", b.QueryID)
+	case OpPluginInstalled:
+		return fmt.Sprintf("plugin installed, query_id %d", b.QueryID)
+	case OpPluginRemoved:
+		return fmt.Sprintf("plugin removed, query_id %d", b.QueryID)
+	case OpInternalTransfer:
+		return fmt.Sprintf("highload v3 internal_transfer of %d messages, query_id %d", len(b.Messages), b.QueryID)
+	case OpSignedInternal:
+		return fmt.Sprintf("wallet v5 signed request of %d messages and %d other actions, wallet ID %d, seqno %d, valid until %s",
+			len(b.Messages), len(b.Extended), b.WalletID, b.Seqno, b.ValidUntil.Format(time.RFC3339))
+	case OpExtensionAction:
+		return fmt.Sprintf("wallet v5 extension request of %d messages and %d other actions, query_id %d",
+			len(b.Messages), len(b.Extended), b.QueryID)
+	case OpNewOrder:
+		seqno := b.OrderSeqno.String()
+		if b.OrderSeqno.Cmp(multisig.MaxOrderSeqno) == 0 {
+			seqno = "next"
+		}
+		by := "signer"
+		if !b.IsSigner {
+			by = "proposer"
+		}
+		return fmt.Sprintf("multisig new order %s by %s %d with %d actions, expires %s, query_id %d",
+			seqno, by, b.Index, len(b.Order), b.ValidUntil.Format(time.RFC3339), b.QueryID)
+	case OpApproveOrder:
+		return fmt.Sprintf("multisig order approval by signer %d, query_id %d", b.Index, b.QueryID)
+	}
+	return fmt.Sprintf("op 0x%08x", b.Op)
+}
+
+func describeAddress(addr *address.Address, formatAddress func(*address.Address) string) string {
+	if addr == nil || addr.IsAddrNone() {
+		return "none"
+	}
+	return formatAddress(addr)
+}
+
+// describeOrderAction returns an action of a multisig order in one line.
+func describeOrderAction(a multisig.Action, formatAddress func(*address.Address) string) string {
+	switch a.Op {
+	case multisig.ActionSendMessage:
+		line := fmt.Sprintf("send %s TON to %s, mode %s", a.Message.Value.String(), formatAddress(a.Message.Dest), a.Mode)
+		if a.Message.Body != nil {
+			line += ", body: " + DecodeBody(a.Message.Body).Describe(formatAddress)
+		}
+		return line
+	case multisig.ActionUpdateParams:
+		return fmt.Sprintf("update params: %d of %d signers", a.Params.Threshold, len(a.Params.Signers))
+	}
+	return fmt.Sprintf("unknown action 0x%08x", a.Op)
+}
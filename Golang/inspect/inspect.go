@@ -0,0 +1,613 @@
+// Package inspect decodes a signed external message to a wallet back into
+// what it asks the wallet to do, so it can be checked before it is sent.
+package inspect
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/highload"
+	"main/highloadv3"
+	"main/message"
+	"main/walletv3"
+	"main/walletv4"
+	"main/walletv5"
+)
+
+// WalletType is the wallet contract the body was made for.
+type WalletType string
+
+const (
+	WalletV3   WalletType = "wallet v3"
+	WalletV4   WalletType = "wallet v4"
+	WalletV5   WalletType = "wallet v5"
+	HighloadV2 WalletType = "highload wallet v2"
+	HighloadV3 WalletType = "highload wallet v3"
+)
+
+var (
+	ErrNotBOC        = errors.New("inspect: not a BOC in base64 or hex, and no such file")
+	ErrUnknownWallet = errors.New("inspect: body does not look like a message to a wallet v3, v4, v5 or highload wallet v2, v3")
+)
+
+// Decoded is a signed external message to a wallet.
+type Decoded struct {
+	Wallet    *address.Address
+	StateInit *cell.Cell // not nil if the message also deploys the wallet
+	Type      WalletType
+
+	Signature []byte
+	Payload   *cell.Cell // the signed part of the body, its hash is what the key signs
+
+	SubwalletID uint32    // the wallet_id of wallet v5
+	ValidUntil  time.Time // wallets v3, v4 and v5
+	Seqno       uint32    // wallets v3, v4 and v5
+	Op          uint32    // the op byte of wallet v4, the op of wallet v5 ("sign")
+	QueryID     uint64    // highload wallets only, for v2 the expiration time is in its high 32 bits
+	CreatedAt   time.Time // highload wallet v3 only
+	Timeout     uint32    // highload wallet v3 only, in seconds
+
+	Messages []Message
+	Plugin   *Plugin          // wallet v4 with op 1, 2 or 3
+	Extended []ExtendedAction // wallet v5
+}
+
+// Plugin is the plugin action of a wallet v4 message.
+type Plugin struct {
+	Op        uint8            // walletv4.OpDeployAndInstallPlugin, OpInstallPlugin or OpRemovePlugin
+	Address   *address.Address // of the plugin, for a deploy the address of StateInit
+	Amount    tlb.Coins        // the balance of a deployed plugin, else the TON sent with "note" or "dstr"
+	QueryID   uint64           // install and remove
+	StateInit *cell.Cell       // deploy
+	Body      *cell.Cell       // deploy
+}
+
+// Message is one internal message the wallet is asked to send.
+type Message struct {
+	Mode     message.SendMode
+	Internal *message.Internal
+	Body     Body
+}
+
+// ParseBOC reads a BOC from base64, hex, or the file named input. The file
+// itself can hold the BOC raw or as base64 or hex text.
+func ParseBOC(input string) (*cell.Cell, error) {
+	input = strings.TrimSpace(input)
+
+	if data, err := os.ReadFile(input); err == nil {
+		if c, err := cell.FromBOC(data); err == nil {
+			return c, nil
+		}
+		input = strings.TrimSpace(string(data))
+	}
+
+	if data, err := hex.DecodeString(input); err == nil {
+		if c, err := cell.FromBOC(data); err == nil {
+			return c, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		if data, err := enc.DecodeString(input); err == nil {
+			if c, err := cell.FromBOC(data); err == nil {
+				return c, nil
+			}
+		}
+	}
+	return nil, ErrNotBOC
+}
+
+// Decode decodes an external message made by walletv3, walletv4, walletv5,
+// highload or highloadv3.
+func Decode(externalMessage *cell.Cell) (*Decoded, error) {
+	ext, err := message.ParseExternal(externalMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Decoded{Wallet: ext.Dest, StateInit: ext.StateInit}
+	if d.Type, err = walletType(d.StateInit, ext.Body); err != nil {
+		return nil, err
+	}
+	if d.Signature, d.Payload, err = splitSignature(d.Type, ext.Body); err != nil {
+		return nil, err
+	}
+
+	switch d.Type {
+	case WalletV3:
+		err = d.decodeV3(d.Payload.BeginParse())
+	case WalletV4:
+		err = d.decodeV4(d.Payload.BeginParse())
+	case WalletV5:
+		err = d.decodeV5(d.Payload.BeginParse())
+	case HighloadV2:
+		err = d.decodeHighload(d.Payload.BeginParse())
+	case HighloadV3:
+		err = d.decodeHighloadV3(d.Payload.BeginParse())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// splitSignature returns the signature and the signed part of body. Wallet v5
+// has the signature at the end, highload wallet v3 the signed part in a
+// reference, the others the signature first.
+func splitSignature(walletType WalletType, body *cell.Cell) (signature []byte, payload *cell.Cell, err error) {
+	s := body.BeginParse()
+	switch walletType {
+	case WalletV5:
+		if body.BitsSize() < 512 {
+			return nil, nil, errors.New("inspect: signature: body is too short")
+		}
+		bits := body.BitsSize() - 512
+		data, err := s.LoadSlice(bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		b := cell.BeginCell().MustStoreSlice(data, bits)
+		for s.RefsNum() > 0 {
+			ref, err := loadRefCell(s)
+			if err != nil {
+				return nil, nil, fmt.Errorf("inspect: signed part: %w", err)
+			}
+			b.MustStoreRef(ref)
+		}
+		signature, err = s.LoadSlice(512)
+		return signature, b.EndCell(), err
+	case HighloadV3:
+		if signature, err = s.LoadSlice(512); err != nil {
+			return nil, nil, fmt.Errorf("inspect: signature: %w", err)
+		}
+		ref, err := s.LoadRef()
+		if err != nil {
+			return nil, nil, err
+		}
+		payload, err = ref.ToCell()
+		return signature, payload, err
+	}
+
+	if signature, err = s.LoadSlice(512); err != nil {
+		return nil, nil, fmt.Errorf("inspect: signature: %w", err)
+	}
+	payload, err = s.ToCell()
+	return signature, payload, err
+}
+
+// walletType takes the type from the code when the message deploys the wallet
+// and guesses it from the layout of the body otherwise.
+func walletType(stateInit, body *cell.Cell) (WalletType, error) {
+	if stateInit != nil {
+		code, err := stateInitCode(stateInit)
+		if err != nil {
+			return "", err
+		}
+		if code != nil {
+			hash := code.Hash()
+			switch {
+			case bytes.Equal(hash, walletv3.Code().Hash()):
+				return WalletV3, nil
+			case bytes.Equal(hash, walletv4.Code().Hash()):
+				return WalletV4, nil
+			case bytes.Equal(hash, walletv5.Code().Hash()):
+				return WalletV5, nil
+			case bytes.Equal(hash, highload.Code().Hash()):
+				return HighloadV2, nil
+			case bytes.Equal(hash, highloadv3.Code().Hash()):
+				return HighloadV3, nil
+			}
+		}
+	}
+
+	// highload wallet v3: only the signature, the signed part is a reference
+	if body.BitsSize() == 512 && body.RefsNum() == 1 {
+		return HighloadV3, nil
+	}
+	// wallet v5: op "sign", wallet_id, valid_until, seqno, two bits at least and the signature
+	if body.BitsSize() >= 32*4+2+512 {
+		if op, err := body.BeginParse().LoadUInt(32); err == nil && op == walletv5.OpSignedExternal {
+			return WalletV5, nil
+		}
+	}
+
+	_, payload, err := splitSignature("", body)
+	if err != nil {
+		return "", ErrUnknownWallet
+	}
+	// wallet v3: subwallet_id, valid_until, seqno and a mode byte per message reference
+	refs := uint(payload.RefsNum())
+	if payload.BitsSize() == 96+8*refs && refs <= walletv3.MaxMessages {
+		return WalletV3, nil
+	}
+	// wallet v4: the same with op 0 after the seqno, or a plugin action
+	if fields, err := loadFields(payload.BeginParse(), "wallet v4", 32, 32, 32, 8); err == nil {
+		op := fields[3]
+		if op == walletv4.OpSend && payload.BitsSize() == 104+8*refs && refs <= walletv4.MaxMessages {
+			return WalletV4, nil
+		}
+		if op != walletv4.OpSend && (&Decoded{}).decodeV4(payload.BeginParse()) == nil {
+			return WalletV4, nil
+		}
+	}
+	// highload wallet v2: subwallet_id, query_id and the Maybe bit of the dictionary
+	if payload.BitsSize() == 97 && refs <= 1 {
+		return HighloadV2, nil
+	}
+	return "", ErrUnknownWallet
+}
+
+// stateInitCode returns the code of a StateInit, nil if it has none.
+// split_depth:(Maybe (## 5)) special:(Maybe TickTock) code:(Maybe ^Cell) ...
+func stateInitCode(stateInit *cell.Cell) (*cell.Cell, error) {
+	s := stateInit.BeginParse()
+	for _, size := range []uint{5, 2} {
+		has, err := s.LoadBoolBit()
+		if err != nil {
+			return nil, fmt.Errorf("inspect: state init: %w", err)
+		}
+		if has {
+			if _, err = s.LoadUInt(size); err != nil {
+				return nil, fmt.Errorf("inspect: state init: %w", err)
+			}
+		}
+	}
+	code, err := s.LoadMaybeRef()
+	if err != nil {
+		return nil, fmt.Errorf("inspect: state init code: %w", err)
+	}
+	if code == nil {
+		return nil, nil
+	}
+	return code.ToCell()
+}
+
+// loadFields reads unsigned fields of the given sizes in bits, in order.
+func loadFields(s *cell.Slice, name string, sizes ...uint) ([]uint64, error) {
+	fields := make([]uint64, len(sizes))
+	for i, size := range sizes {
+		v, err := s.LoadUInt(size)
+		if err != nil {
+			return nil, fmt.Errorf("inspect: %s: field %d: %w", name, i+1, err)
+		}
+		fields[i] = v
+	}
+	return fields, nil
+}
+
+// subwallet_id:uint32 valid_until:uint32 seqno:uint32, then the messages.
+func (d *Decoded) decodeV3(s *cell.Slice) error {
+	fields, err := loadFields(s, "wallet v3", 32, 32, 32)
+	if err != nil {
+		return err
+	}
+	d.SubwalletID = uint32(fields[0])
+	d.ValidUntil = time.Unix(int64(fields[1]), 0).UTC()
+	d.Seqno = uint32(fields[2])
+	return d.loadMessages(s)
+}
+
+// loadMessages reads a mode byte and a message reference for every message, the layout of wallets v3 and v4.
+func (d *Decoded) loadMessages(s *cell.Slice) error {
+	for s.RefsNum() > 0 {
+		mode, err := s.LoadUInt(8)
+		if err != nil {
+			return fmt.Errorf("inspect: mode of message %d: %w", len(d.Messages)+1, err)
+		}
+		ref, err := s.LoadRef()
+		if err != nil {
+			return err
+		}
+		if err = d.addMessage(message.SendMode(mode), ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeV4 reads the fields of wallet v3, the op byte and then the messages or the plugin action.
+func (d *Decoded) decodeV4(s *cell.Slice) error {
+	fields, err := loadFields(s, "wallet v4", 32, 32, 32, 8)
+	if err != nil {
+		return err
+	}
+	d.SubwalletID = uint32(fields[0])
+	d.ValidUntil = time.Unix(int64(fields[1]), 0).UTC()
+	d.Seqno = uint32(fields[2])
+	d.Op = uint32(fields[3])
+
+	switch d.Op {
+	case walletv4.OpSend:
+		return d.loadMessages(s)
+	case walletv4.OpDeployAndInstallPlugin:
+		err = d.loadDeployPlugin(s)
+	case walletv4.OpInstallPlugin, walletv4.OpRemovePlugin:
+		err = d.loadPlugin(s)
+	default:
+		return fmt.Errorf("inspect: unknown wallet v4 op %d", d.Op)
+	}
+	if err != nil {
+		return fmt.Errorf("inspect: plugin action: %w", err)
+	}
+	if s.BitsLeft() > 0 || s.RefsNum() > 0 {
+		return errors.New("inspect: wallet v4: data after the plugin action")
+	}
+	return nil
+}
+
+// workchain:int8 balance:Coins state_init:^Cell body:^Cell
+func (d *Decoded) loadDeployPlugin(s *cell.Slice) error {
+	workchain, err := s.LoadInt(8)
+	if err != nil {
+		return err
+	}
+	p := &Plugin{Op: uint8(d.Op)}
+	if p.Amount, err = loadCoins(s); err != nil {
+		return err
+	}
+	if p.StateInit, err = loadRefCell(s); err != nil {
+		return err
+	}
+	if p.Body, err = loadRefCell(s); err != nil {
+		return err
+	}
+	p.Address = address.NewAddress(0, byte(workchain), p.StateInit.Hash())
+	d.Plugin = p
+	return nil
+}
+
+// wc:int8 addr_hash:uint256 amount:Coins query_id:uint64
+func (d *Decoded) loadPlugin(s *cell.Slice) error {
+	workchain, err := s.LoadInt(8)
+	if err != nil {
+		return err
+	}
+	hash, err := s.LoadSlice(256)
+	if err != nil {
+		return err
+	}
+	p := &Plugin{Op: uint8(d.Op), Address: address.NewAddress(0, byte(workchain), hash)}
+	if p.Amount, err = loadCoins(s); err != nil {
+		return err
+	}
+	if p.QueryID, err = s.LoadUInt(64); err != nil {
+		return err
+	}
+	d.Plugin = p
+	return nil
+}
+
+// decodeV5 reads op, wallet_id, valid_until, seqno and the actions.
+func (d *Decoded) decodeV5(s *cell.Slice) error {
+	fields, err := loadFields(s, "wallet v5", 32, 32, 32, 32)
+	if err != nil {
+		return err
+	}
+	d.Op = uint32(fields[0])
+	d.SubwalletID = uint32(fields[1])
+	d.ValidUntil = time.Unix(int64(fields[2]), 0).UTC()
+	d.Seqno = uint32(fields[3])
+
+	if d.Messages, d.Extended, err = loadV5Actions(s); err != nil {
+		return fmt.Errorf("inspect: wallet v5 actions: %w", err)
+	}
+	return nil
+}
+
+// decodeHighloadV3 reads subwallet_id:uint32 message:^Cell mode:uint8 query_id:uint23 created_at:uint64 timeout:uint22.
+// The message usually goes to the wallet itself with internal_transfer, its body has the messages.
+func (d *Decoded) decodeHighloadV3(s *cell.Slice) error {
+	if s.RefsNum() != 1 {
+		return ErrUnknownWallet
+	}
+	ref, err := s.LoadRef()
+	if err != nil {
+		return fmt.Errorf("inspect: highload wallet v3 message: %w", err)
+	}
+	fields, err := loadFields(s, "highload wallet v3", 32, 8, 23, 64, 22)
+	if err != nil {
+		return err
+	}
+	d.SubwalletID = uint32(fields[0])
+	d.QueryID = fields[2]
+	d.CreatedAt = time.Unix(int64(fields[3]), 0).UTC()
+	d.Timeout = uint32(fields[4])
+	return d.addMessage(message.SendMode(fields[1]), ref)
+}
+
+// subwallet_id:uint32 query_id:uint64 messages:(HashmapE 16 (uint8, ^Cell))
+func (d *Decoded) decodeHighload(s *cell.Slice) error {
+	fields, err := loadFields(s, "highload wallet v2", 32, 64)
+	if err != nil {
+		return err
+	}
+	d.SubwalletID = uint32(fields[0])
+	d.QueryID = fields[1]
+
+	dictionary, err := s.LoadDict(16)
+	if err != nil {
+		return fmt.Errorf("inspect: messages: %w", err)
+	}
+
+	// the dictionary is not ordered, the key is the index of the message
+	all := dictionary.All()
+	keys := make(map[*cell.Cell]uint64, len(all))
+	for _, kv := range all {
+		if keys[kv.Key], err = kv.Key.BeginParse().LoadUInt(16); err != nil {
+			return fmt.Errorf("inspect: message index: %w", err)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return keys[all[i].Key] < keys[all[j].Key] })
+
+	for _, kv := range all {
+		v := kv.Value.BeginParse()
+		mode, err := v.LoadUInt(8)
+		if err != nil {
+			return fmt.Errorf("inspect: mode of message %d: %w", len(d.Messages)+1, err)
+		}
+		ref, err := v.LoadRef()
+		if err != nil {
+			return err
+		}
+		if err = d.addMessage(message.SendMode(mode), ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoded) addMessage(mode message.SendMode, ref *cell.Slice) error {
+	m, err := newMessage(mode, ref)
+	if err != nil {
+		return fmt.Errorf("inspect: message %d: %w", len(d.Messages)+1, err)
+	}
+	d.Messages = append(d.Messages, m)
+	return nil
+}
+
+func newMessage(mode message.SendMode, ref *cell.Slice) (Message, error) {
+	c, err := ref.ToCell()
+	if err != nil {
+		return Message{}, err
+	}
+	internal, err := message.ParseInternal(c)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Mode:     mode,
+		Internal: internal,
+		Body:     DecodeBody(internal.Body),
+	}, nil
+}
+
+// Verify reports whether the message is signed by publicKey.
+func (d *Decoded) Verify(publicKey ed25519.PublicKey) bool {
+	return len(publicKey) == ed25519.PublicKeySize &&
+		ed25519.Verify(publicKey, d.Payload.Hash(), d.Signature)
+}
+
+// ExpiresAt returns when the wallet stops accepting the message.
+func (d *Decoded) ExpiresAt() time.Time {
+	switch d.Type {
+	case HighloadV2:
+		return time.Unix(int64(d.QueryID>>32), 0).UTC()
+	case HighloadV3:
+		return d.CreatedAt.Add(time.Duration(d.Timeout) * time.Second)
+	}
+	return d.ValidUntil
+}
+
+// Describe returns the message in a form for a person to review. formatAddress
+// prints addresses, for example config.Config.FormatAddress, or String if nil.
+func (d *Decoded) Describe(formatAddress func(*address.Address) string) string {
+	if formatAddress == nil {
+		formatAddress = (*address.Address).String
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Wallet:       %s (%s)\n", formatAddress(d.Wallet), d.Type)
+	if d.StateInit != nil {
+		fmt.Fprintf(&sb, "Deploys:      yes\n")
+	}
+	fmt.Fprintf(&sb, "Signature:    %s\n", hex.EncodeToString(d.Signature))
+	if d.Type == WalletV5 {
+		fmt.Fprintf(&sb, "Wallet ID:    %d\n", d.SubwalletID)
+	} else {
+		fmt.Fprintf(&sb, "Subwallet ID: %d\n", d.SubwalletID)
+	}
+	switch d.Type {
+	case WalletV3, WalletV4, WalletV5:
+		fmt.Fprintf(&sb, "Seqno:        %d\n", d.Seqno)
+		fmt.Fprintf(&sb, "Valid until:  %s\n", d.ValidUntil.Format(time.RFC3339))
+	case HighloadV2:
+		fmt.Fprintf(&sb, "Query ID:     %d\n", d.QueryID)
+		fmt.Fprintf(&sb, "Valid until:  %s\n", d.ExpiresAt().Format(time.RFC3339))
+	case HighloadV3:
+		fmt.Fprintf(&sb, "Query ID:     %d\n", d.QueryID)
+		fmt.Fprintf(&sb, "Created at:   %s\n", d.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(&sb, "Valid until:  %s\n", d.ExpiresAt().Format(time.RFC3339))
+	}
+	if d.Plugin != nil {
+		fmt.Fprintf(&sb, "Plugin:       %s\n", d.Plugin.Describe(formatAddress))
+	}
+	for _, a := range d.Extended {
+		fmt.Fprintf(&sb, "Action:       %s\n", a.Describe(formatAddress))
+	}
+
+	warnings := describeMessages(&sb, d.Messages, "", "", formatAddress)
+	for _, w := range warnings {
+		fmt.Fprintf(&sb, "Warning:      %s\n", w)
+	}
+	return sb.String()
+}
+
+// describeMessages prints messages and the messages their bodies carry, each
+// level indented deeper, and returns the warnings of message.CheckBatch.
+func describeMessages(sb *strings.Builder, messages []Message, number, indent string, formatAddress func(*address.Address) string) []string {
+	modes := make([]message.SendMode, len(messages))
+	for i, m := range messages {
+		modes[i] = m.Mode
+	}
+	warnings, err := message.CheckBatch(modes...)
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if number != "" {
+		for i, w := range warnings {
+			warnings[i] = fmt.Sprintf("in message #%s: %s", number, w)
+		}
+	}
+
+	for i, m := range messages {
+		n := fmt.Sprint(i + 1)
+		if number != "" {
+			n = number + "." + n
+		}
+		fmt.Fprintf(sb, "%sMessage #%s:\n", indent, n)
+		fmt.Fprintf(sb, "%s  to:      %s\n", indent, formatAddress(m.Internal.Dest))
+		fmt.Fprintf(sb, "%s  amount:  %s TON\n", indent, m.Internal.Value.String())
+		fmt.Fprintf(sb, "%s  bounce:  %t\n", indent, m.Internal.Bounce)
+		fmt.Fprintf(sb, "%s  mode:    %s\n", indent, m.Mode)
+		if m.Internal.StateInit != nil {
+			fmt.Fprintf(sb, "%s  deploys: %s\n", indent, hex.EncodeToString(m.Internal.StateInit.Hash()))
+		}
+		if m.Internal.Body == nil {
+			continue
+		}
+		fmt.Fprintf(sb, "%s  body:    %s\n", indent, m.Body.Describe(formatAddress))
+		for _, a := range m.Body.Extended {
+			fmt.Fprintf(sb, "%s  action:  %s\n", indent, a.Describe(formatAddress))
+		}
+		for j, a := range m.Body.Order {
+			fmt.Fprintf(sb, "%s  order action #%d: %s\n", indent, j+1, describeOrderAction(a, formatAddress))
+		}
+		nested := describeMessages(sb, m.Body.Messages, n, indent+"    ", formatAddress)
+		warnings = append(warnings, nested...)
+	}
+	return warnings
+}
+
+// Describe returns the plugin action in one line.
+func (p *Plugin) Describe(formatAddress func(*address.Address) string) string {
+	switch p.Op {
+	case walletv4.OpDeployAndInstallPlugin:
+		return fmt.Sprintf("deploy and install %s with %s TON", formatAddress(p.Address), p.Amount.String())
+	case walletv4.OpInstallPlugin:
+		return fmt.Sprintf("install %s, send it %s TON, query_id %d", formatAddress(p.Address), p.Amount.String(), p.QueryID)
+	case walletv4.OpRemovePlugin:
+		return fmt.Sprintf("remove %s, send it %s TON, query_id %d", formatAddress(p.Address), p.Amount.String(), p.QueryID)
+	}
+	return fmt.Sprintf("unknown op %d", p.Op)
+}
@@ -0,0 +1,282 @@
+package inspect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/comment"
+	"main/contract"
+	"main/highload"
+	"main/highloadv3"
+	"main/message"
+	"main/multisig"
+	"main/signer"
+	"main/walletv3"
+	"main/walletv4"
+	"main/walletv5"
+)
+
+func TestDecodeWallets(t *testing.T) {
+	ctx := context.Background()
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySigner := signer.NewKeySigner(privateKey)
+	publicKey := keySigner.PublicKey()
+	dest := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	validUntil := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	out := func(n int, text string) []message.Out {
+		messages := make([]message.Out, n)
+		for i := range messages {
+			body, err := comment.Text(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages[i] = message.Out{Mode: message.ModeDefault, Message: (&message.Internal{
+				Dest: dest, Value: tlb.FromNanoTON(big.NewInt(int64(i + 1))), Bounce: true, Body: body,
+			}).MustToCell()}
+		}
+		return messages
+	}
+	to := func(body *cell.Cell) []message.Out {
+		return []message.Out{{Mode: message.ModeDefault, Message: (&message.Internal{
+			Dest: dest, Value: tlb.MustFromTON("0.1"), Bounce: true, Body: body, BodyRef: true,
+		}).MustToCell()}}
+	}
+
+	v5ID, err := walletv5.DefaultWalletID(false).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v5Request := walletv5.Request{WalletID: v5ID, ValidUntil: validUntil, Seqno: 7, Actions: walletv5.Actions{
+		Messages: out(3, "v5"),
+		Extended: []walletv5.ExtendedAction{walletv5.AddExtension{Extension: dest}, walletv5.RemoveExtension{Extension: dest}},
+	}}
+	v5StateInit, err := walletv5.StateInit(publicKey, walletv5.DefaultWalletID(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v5Internal, err := v5Request.SignInternal(ctx, keySigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extension, err := walletv5.ExtensionBody(5, walletv5.Actions{Messages: out(2, "extn")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	action, err := multisig.SendMessage(message.ModeDefault, (&message.Internal{Dest: dest, Value: tlb.MustFromTON("5")}).MustToCell())
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := multisig.PackOrder([]*cell.Cell{action, action})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newOrder := (&multisig.NewOrder{QueryID: 1, OrderSeqno: multisig.MaxOrderSeqno, IsSigner: true, Index: 2, ExpireAt: validUntil, Order: order}).Body()
+
+	hlv3Address, err := highloadv3.Address(publicKey, highloadv3.DefaultSubwalletID, highloadv3.DefaultTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := address.NewAddress(0, 0, make([]byte, 32))
+
+	tests := []struct {
+		name     string
+		external func() (*cell.Cell, error)
+		want     WalletType
+		messages int
+		check    func(t *testing.T, d *Decoded)
+	}{
+		{"wallet v3", func() (*cell.Cell, error) {
+			tr := walletv3.Transfer{SubwalletID: 1, ValidUntil: validUntil, Seqno: 3, Messages: out(2, "v3")}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV3, 2, nil},
+		{"wallet v3 deploy", func() (*cell.Cell, error) {
+			tr := walletv3.Transfer{SubwalletID: 1, ValidUntil: validUntil, Messages: out(1, "v3")}
+			return tr.External(ctx, keySigner, dest, walletv3.StateInit(publicKey, 1))
+		}, WalletV3, 1, nil},
+		{"wallet v4 send", func() (*cell.Cell, error) {
+			tr := walletv4.Transfer{SubwalletID: 1, ValidUntil: validUntil, Seqno: 3, Messages: out(4, "v4")}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV4, 4, func(t *testing.T, d *Decoded) {
+			if d.Op != walletv4.OpSend || d.Seqno != 3 {
+				t.Errorf("op %d, seqno %d", d.Op, d.Seqno)
+			}
+		}},
+		{"wallet v4 install plugin", func() (*cell.Cell, error) {
+			tr := walletv4.Transfer{SubwalletID: 1, ValidUntil: validUntil, Seqno: 4,
+				Plugin: &walletv4.InstallPlugin{Plugin: plugin, Amount: tlb.MustFromTON("0.05"), QueryID: 9}}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV4, 0, func(t *testing.T, d *Decoded) {
+			if d.Plugin == nil || d.Plugin.Op != walletv4.OpInstallPlugin || d.Plugin.QueryID != 9 || d.Plugin.Address.String() != plugin.String() {
+				t.Errorf("plugin %+v", d.Plugin)
+			}
+		}},
+		{"wallet v4 deploy plugin", func() (*cell.Cell, error) {
+			tr := walletv4.Transfer{SubwalletID: 1, ValidUntil: validUntil, Seqno: 5,
+				Plugin: &walletv4.DeployPlugin{Balance: tlb.MustFromTON("0.1"), StateInit: walletv3.StateInit(publicKey, 2)}}
+			return tr.External(ctx, keySigner, dest, walletv4.StateInit(publicKey, 1))
+		}, WalletV4, 0, func(t *testing.T, d *Decoded) {
+			if d.Plugin == nil || d.Plugin.Op != walletv4.OpDeployAndInstallPlugin || d.Plugin.Address.String() != walletv3.Address(publicKey, 2).String() {
+				t.Errorf("plugin %+v", d.Plugin)
+			}
+		}},
+		{"wallet v5", func() (*cell.Cell, error) {
+			return v5Request.External(ctx, keySigner, dest, nil)
+		}, WalletV5, 3, func(t *testing.T, d *Decoded) {
+			if d.Op != walletv5.OpSignedExternal || d.SubwalletID != v5ID || d.Seqno != 7 || len(d.Extended) != 2 ||
+				d.Extended[1].Op != walletv5.ActionDeleteExtension {
+				t.Errorf("op %x, wallet ID %d, seqno %d, actions %+v", d.Op, d.SubwalletID, d.Seqno, d.Extended)
+			}
+			if d.Messages[0].Internal.Value.NanoTON().Int64() != 1 {
+				t.Error("the out list is not in the order of the messages")
+			}
+		}},
+		{"wallet v5 deploy", func() (*cell.Cell, error) {
+			return (&walletv5.Request{WalletID: v5ID, ValidUntil: validUntil}).External(ctx, keySigner, dest, v5StateInit)
+		}, WalletV5, 0, nil},
+		{"highload v2", func() (*cell.Cell, error) {
+			q := highload.Query{SubwalletID: 1, QueryID: highload.NewQueryID(time.Minute), Messages: out(5, "v2")}
+			return q.External(ctx, keySigner, dest)
+		}, HighloadV2, 5, nil},
+		{"highload v3", func() (*cell.Cell, error) {
+			b := highloadv3.Batch{SubwalletID: highloadv3.DefaultSubwalletID, Timeout: highloadv3.DefaultTimeout,
+				QueryID: highloadv3.QueryID{Shift: 1, BitNumber: 2}, Value: tlb.MustFromTON("1"), Mode: message.FlagPayFeesSeparately,
+				Messages: out(300, "v3")}
+			return b.External(ctx, keySigner, hlv3Address, nil)
+		}, HighloadV3, 1, func(t *testing.T, d *Decoded) {
+			if d.QueryID != 1<<10+2 || d.Timeout != highloadv3.DefaultTimeout {
+				t.Errorf("query_id %d, timeout %d", d.QueryID, d.Timeout)
+			}
+			first := d.Messages[0].Body
+			if first.Op != OpInternalTransfer || len(first.Messages) != highloadv3.MaxActions {
+				t.Fatalf("internal_transfer op %x with %d messages", first.Op, len(first.Messages))
+			}
+			next := first.Messages[highloadv3.MaxActions-1].Body
+			if next.Op != OpInternalTransfer || len(next.Messages) != 300-(highloadv3.MaxActions-1) {
+				t.Errorf("next internal_transfer op %x with %d messages", next.Op, len(next.Messages))
+			}
+		}},
+		{"multisig new order", func() (*cell.Cell, error) {
+			tr := walletv3.Transfer{SubwalletID: 1, ValidUntil: validUntil, Messages: to(newOrder)}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV3, 1, func(t *testing.T, d *Decoded) {
+			b := d.Messages[0].Body
+			if b.Op != OpNewOrder || !b.Known || len(b.Order) != 2 || b.Index != 2 || !b.IsSigner {
+				t.Errorf("new order %+v", b)
+			}
+		}},
+		{"multisig approve", func() (*cell.Cell, error) {
+			tr := walletv3.Transfer{SubwalletID: 1, ValidUntil: validUntil, Messages: to(multisig.ApproveBody(4, 8))}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV3, 1, func(t *testing.T, d *Decoded) {
+			if b := d.Messages[0].Body; b.Op != OpApproveOrder || b.Index != 4 || b.QueryID != 8 {
+				t.Errorf("approve %+v", b)
+			}
+		}},
+		{"wallet v5 sint and extn relayed", func() (*cell.Cell, error) {
+			tr := walletv3.Transfer{SubwalletID: 1, ValidUntil: validUntil, Messages: append(to(v5Internal), to(extension)...)}
+			return tr.External(ctx, keySigner, dest, nil)
+		}, WalletV3, 2, func(t *testing.T, d *Decoded) {
+			sint, extn := d.Messages[0].Body, d.Messages[1].Body
+			if sint.Op != OpSignedInternal || !sint.Known || len(sint.Messages) != 3 || len(sint.Extended) != 2 || sint.Seqno != 7 {
+				t.Errorf("sint %+v", sint)
+			}
+			if extn.Op != OpExtensionAction || !extn.Known || len(extn.Messages) != 2 || extn.QueryID != 5 {
+				t.Errorf("extn %+v", extn)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			external, err := tt.external()
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := Decode(external)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Type != tt.want {
+				t.Fatalf("type %s, want %s", d.Type, tt.want)
+			}
+			if len(d.Messages) != tt.messages {
+				t.Fatalf("%d messages, want %d", len(d.Messages), tt.messages)
+			}
+			if !d.Verify(publicKey) {
+				t.Error("signature does not verify")
+			}
+			if tt.check != nil {
+				tt.check(t, d)
+			}
+			if text := d.Describe(nil); !strings.Contains(text, string(tt.want)) {
+				t.Errorf("Describe:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	walletAddress := address.NewAddress(0, 0, make([]byte, 32))
+	data := cell.BeginCell().EndCell()
+	signature := make([]byte, 64)
+	body := func(bits uint, refs int) *cell.Cell {
+		b := cell.BeginCell().MustStoreSlice(signature, 512).MustStoreUInt(0, bits)
+		for i := 0; i < refs; i++ {
+			b.MustStoreRef(cell.BeginCell().EndCell())
+		}
+		return b.EndCell()
+	}
+
+	// The code in the state init sets the type, whatever the body is
+	for _, code := range []*cell.Cell{walletv3.Code(), walletv4.Code(), walletv5.Code(), highload.Code(), highloadv3.Code()} {
+		stateInit := contract.StateInit(code, data)
+		for _, b := range []*cell.Cell{body(0, 0), body(40, 0), body(40, 1), cell.BeginCell().MustStoreUInt(1, 8).EndCell()} {
+			if _, err := Decode(message.External(walletAddress, stateInit, b)); err == nil {
+				t.Errorf("a body of %d bits and %d refs was decoded", b.BitsSize(), b.RefsNum())
+			}
+		}
+	}
+
+	t.Run("state init with split_depth and special", func(t *testing.T) {
+		stateInit := cell.BeginCell().
+			MustStoreBoolBit(true).MustStoreUInt(5, 5).
+			MustStoreBoolBit(true).MustStoreUInt(0b10, 2).
+			MustStoreMaybeRef(walletv3.Code()).
+			MustStoreMaybeRef(data).
+			MustStoreBoolBit(false).
+			EndCell()
+		d, err := Decode(message.External(walletAddress, stateInit, body(96, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Type != WalletV3 {
+			t.Errorf("type %s, want %s", d.Type, WalletV3)
+		}
+	})
+
+	t.Run("bodies", func(t *testing.T) {
+		for _, op := range []uint64{OpSignedInternal, OpNewOrder, OpJettonTransfer, OpInternalTransfer, OpApproveOrder} {
+			for _, bits := range []uint{0, 16} {
+				b := cell.BeginCell().MustStoreUInt(op, 32).MustStoreUInt(0, bits).EndCell()
+				if got := DecodeBody(b); got.Known || got.Op != uint32(op) {
+					t.Errorf("op %x with %d bits: known %v, op %x", op, bits, got.Known, got.Op)
+				}
+			}
+		}
+		if got := DecodeBody(cell.BeginCell().MustStoreUInt(1, 8).EndCell()); got.Known {
+			t.Errorf("8 bits decoded: %+v", got)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package jetton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/walletv3"
+)
+
+// WalletAddress runs the get_wallet_address get method of the jetton master
+// and returns the jetton wallet of owner. The jetton wallet may not be deployed
+// yet: it is deployed by the first transfer to it.
+func WalletAddress(ctx context.Context, api walletv3.TonAPI, master, owner *address.Address) (*address.Address, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerSlice := cell.BeginCell().MustStoreAddr(owner).EndCell().BeginParse() // the owner is passed as a slice
+	result, err := api.RunGetMethod(ctx, block, master, "get_wallet_address", ownerSlice)
+	if err != nil {
+		return nil, fmt.Errorf("jetton: run get_wallet_address: %w", err)
+	}
+	s, err := result.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("jetton: read wallet address: %w", err)
+	}
+	return s.LoadAddr()
+}
+
+// WalletData is the result of the get_wallet_data get method of a jetton wallet.
+type WalletData struct {
+	Balance    *big.Int // in the smallest units, see FormatAmount
+	Owner      *address.Address
+	Master     *address.Address
+	WalletCode *cell.Cell
+}
+
+// GetWalletData runs the get_wallet_data get method of a jetton wallet.
+func GetWalletData(ctx context.Context, api walletv3.TonAPI, jettonWallet *address.Address) (*WalletData, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, jettonWallet, "get_wallet_data")
+	if err != nil {
+		return nil, fmt.Errorf("jetton: run get_wallet_data: %w", err)
+	}
+
+	data := &WalletData{}
+	if data.Balance, err = result.Int(0); err != nil {
+		return nil, fmt.Errorf("jetton: read balance: %w", err)
+	}
+	for i, addr := range []**address.Address{&data.Owner, &data.Master} {
+		s, err := result.Slice(uint(i + 1))
+		if err != nil {
+			return nil, fmt.Errorf("jetton: read wallet data: %w", err)
+		}
+		if *addr, err = s.LoadAddr(); err != nil {
+			return nil, fmt.Errorf("jetton: read wallet data: %w", err)
+		}
+	}
+	if data.WalletCode, err = result.Cell(3); err != nil {
+		return nil, fmt.Errorf("jetton: read wallet code: %w", err)
+	}
+	return data, nil
+}
+
+// Balance returns the jetton balance of owner, zero if its jetton wallet is not deployed.
+func Balance(ctx context.Context, api walletv3.TonAPI, master, owner *address.Address) (*big.Int, error) {
+	jettonWallet, err := WalletAddress(ctx, api, master, owner)
+	if err != nil {
+		return nil, err
+	}
+	data, err := GetWalletData(ctx, api, jettonWallet)
+	if errors.Is(err, ton.ContractExecError{Code: ton.ErrCodeContractNotInitialized}) {
+		return big.NewInt(0), nil // no jetton was ever sent to owner
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.Balance, nil
+}
@@ -0,0 +1,255 @@
+// Package jetton builds and reads the messages of jettons (TEP-74), the fungible
+// tokens of TON, the same way Chapter 4 does it for an NFT transfer.
+//
+// Jettons are not held by the wallet itself: every owner has a jetton wallet
+// contract for every jetton, and a transfer is a message from the owner's wallet
+// to its jetton wallet, which passes the jettons to the jetton wallet of the receiver.
+package jetton
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Opcodes of TEP-74.
+const (
+	OpTransfer             = 0x0f8a7ea5 // owner -> its jetton wallet
+	OpInternalTransfer     = 0x178d4519 // jetton wallet -> jetton wallet
+	OpTransferNotification = 0x7362d09c // receiver's jetton wallet -> receiver
+	OpExcesses             = 0xd53276db // the TON left after a transfer -> response destination
+	OpBurn                 = 0x595f07bc // owner -> its jetton wallet
+	OpBurnNotification     = 0x7bdd97de // jetton wallet -> master
+)
+
+var (
+	ErrWrongOp    = errors.New("jetton: body has another opcode")
+	ErrBadAmount  = errors.New("jetton: amount is not a non-negative decimal number")
+	ErrTooMuch    = errors.New("jetton: amount does not fit in VarUInteger 16")
+	ErrNoReceiver = errors.New("jetton: transfer has no destination")
+)
+
+// MaxUnits is the largest amount a transfer or a burn carries, 2^120-1: the
+// amount is a VarUInteger 16, at most 15 bytes.
+var MaxUnits = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 120), big.NewInt(1))
+
+// Transfer is the body the owner sends to its jetton wallet to send jettons.
+// The message needs enough TON for the fees of both jetton wallets plus ForwardTONAmount,
+// 0.05 TON is usually enough.
+type Transfer struct {
+	QueryID     uint64
+	Amount      *big.Int         // in the smallest units of the jetton, see ParseAmount
+	Destination *address.Address // the owner who receives the jettons, not their jetton wallet
+
+	// ResponseDestination gets the TON which is not spent on fees (op excesses), usually the sender wallet.
+	ResponseDestination *address.Address
+	CustomPayload       *cell.Cell // nil for none
+
+	// ForwardTONAmount is sent to Destination with a transfer_notification which
+	// holds ForwardPayload. With zero nothing is sent to Destination.
+	ForwardTONAmount tlb.Coins
+	ForwardPayload   *cell.Cell // nil for none, comment.Text for a comment
+}
+
+// ToCell serializes the transfer:
+//
+//	transfer#0f8a7ea5 query_id:uint64 amount:(VarUInteger 16) destination:MsgAddress
+//	response_destination:MsgAddress custom_payload:(Maybe ^Cell)
+//	forward_ton_amount:(VarUInteger 16) forward_payload:(Either Cell ^Cell)
+func (t *Transfer) ToCell() (*cell.Cell, error) {
+	if t.Destination == nil {
+		return nil, ErrNoReceiver
+	}
+	if t.Amount == nil || t.Amount.Sign() < 0 {
+		return nil, ErrBadAmount
+	}
+	if t.Amount.Cmp(MaxUnits) > 0 {
+		return nil, ErrTooMuch
+	}
+
+	b := cell.BeginCell().
+		MustStoreUInt(OpTransfer, 32). // Opcode for jetton transfer
+		MustStoreUInt(t.QueryID, 64)   // query_id
+	if err := b.StoreBigCoins(t.Amount); err != nil { // amount, VarUInteger 16 is stored like coins
+		return nil, fmt.Errorf("jetton: amount: %w", err)
+	}
+	b.MustStoreAddr(t.Destination)                    // destination
+	b.MustStoreAddr(orNone(t.ResponseDestination))    // response_destination for excesses
+	b.MustStoreMaybeRef(t.CustomPayload)              // custom_payload
+	b.MustStoreBigCoins(t.ForwardTONAmount.NanoTON()) // forward_ton_amount
+	storeForwardPayload(b, t.ForwardPayload)
+	return b.EndCell(), nil
+}
+
+// Burn is the body the owner sends to its jetton wallet to destroy jettons.
+type Burn struct {
+	QueryID             uint64
+	Amount              *big.Int
+	ResponseDestination *address.Address
+	CustomPayload       *cell.Cell
+}
+
+// ToCell serializes the burn:
+//
+//	burn#595f07bc query_id:uint64 amount:(VarUInteger 16)
+//	response_destination:MsgAddress custom_payload:(Maybe ^Cell)
+func (b *Burn) ToCell() (*cell.Cell, error) {
+	if b.Amount == nil || b.Amount.Sign() < 0 {
+		return nil, ErrBadAmount
+	}
+	if b.Amount.Cmp(MaxUnits) > 0 {
+		return nil, ErrTooMuch
+	}
+
+	c := cell.BeginCell().
+		MustStoreUInt(OpBurn, 32). // Opcode for jetton burn
+		MustStoreUInt(b.QueryID, 64)
+	if err := c.StoreBigCoins(b.Amount); err != nil {
+		return nil, fmt.Errorf("jetton: amount: %w", err)
+	}
+	c.MustStoreAddr(orNone(b.ResponseDestination)) // response_destination for excesses
+	c.MustStoreMaybeRef(b.CustomPayload)           // custom_payload
+	return c.EndCell(), nil
+}
+
+// TransferNotification is what the receiver gets from its jetton wallet when
+// the sender sets a forward_ton_amount.
+type TransferNotification struct {
+	QueryID        uint64
+	Amount         *big.Int
+	Sender         *address.Address // the owner who sent the jettons, not their jetton wallet
+	ForwardPayload *cell.Cell       // nil if there is none
+}
+
+// ParseTransferNotification decodes:
+//
+//	transfer_notification#7362d09c query_id:uint64 amount:(VarUInteger 16)
+//	sender:MsgAddress forward_payload:(Either Cell ^Cell)
+//
+// Only the jetton wallet of the receiver can send it, so check the source of
+// the message: anyone can send a message with this body.
+func ParseTransferNotification(body *cell.Cell) (*TransferNotification, error) {
+	s, err := loadOp(body, OpTransferNotification)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &TransferNotification{}
+	if n.QueryID, err = s.LoadUInt(64); err != nil {
+		return nil, err
+	}
+	if n.Amount, err = s.LoadBigCoins(); err != nil {
+		return nil, fmt.Errorf("jetton: amount: %w", err)
+	}
+	if n.Sender, err = s.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("jetton: sender: %w", err)
+	}
+	if n.ForwardPayload, err = loadForwardPayload(s); err != nil {
+		return nil, fmt.Errorf("jetton: forward_payload: %w", err)
+	}
+	return n, nil
+}
+
+// ParseExcesses decodes excesses#d53276db query_id:uint64 and returns the query_id.
+func ParseExcesses(body *cell.Cell) (uint64, error) {
+	s, err := loadOp(body, OpExcesses)
+	if err != nil {
+		return 0, err
+	}
+	return s.LoadUInt(64)
+}
+
+func loadOp(body *cell.Cell, op uint64) (*cell.Slice, error) {
+	if body == nil {
+		return nil, ErrWrongOp
+	}
+	s := body.BeginParse()
+	got, err := s.LoadUInt(32)
+	if err != nil || got != op {
+		return nil, ErrWrongOp
+	}
+	return s, nil
+}
+
+// storeForwardPayload stores the payload in the cell when it fits and as a reference otherwise.
+func storeForwardPayload(b *cell.Builder, payload *cell.Cell) {
+	switch {
+	case payload == nil:
+		b.MustStoreBoolBit(false) // empty forward_payload in this cell
+	case b.BitsLeft() >= 1+payload.BitsSize() && b.RefsLeft() >= payload.RefsNum():
+		b.MustStoreBoolBit(false) // forward_payload is stored in this cell
+		b.MustStoreBuilder(payload.ToBuilder())
+	default:
+		b.MustStoreBoolBit(true) // we store forward_payload as a reference
+		b.MustStoreRef(payload)
+	}
+}
+
+func loadForwardPayload(s *cell.Slice) (*cell.Cell, error) {
+	if s.BitsLeft() == 0 {
+		return nil, nil // some jetton wallets leave out the Either bit of an empty payload
+	}
+	isRef, err := s.LoadBoolBit()
+	if err != nil {
+		return nil, err
+	}
+	if isRef {
+		ref, err := s.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+		return ref.ToCell()
+	}
+	if s.BitsLeft() == 0 && s.RefsNum() == 0 {
+		return nil, nil
+	}
+	return s.ToCell()
+}
+
+func orNone(addr *address.Address) *address.Address {
+	if addr == nil {
+		return address.NewAddressNone()
+	}
+	return addr
+}
+
+// ParseAmount converts a decimal amount like "12.5" to the smallest units of a
+// jetton with the given decimals (from its metadata, 6 for USDT, 9 by default).
+// An amount with more digits after the point than decimals is an error, not
+// rounded, and so is one above MaxUnits.
+func ParseAmount(amount string, decimals int) (*big.Int, error) {
+	whole, fraction, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	if whole == "" && fraction == "" || len(fraction) > decimals {
+		return nil, fmt.Errorf("%w: %q with %d decimals", ErrBadAmount, amount, decimals)
+	}
+
+	units, ok := new(big.Int).SetString(whole+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+	if !ok || units.Sign() < 0 || strings.ContainsAny(whole+fraction, "+-") {
+		return nil, fmt.Errorf("%w: %q", ErrBadAmount, amount)
+	}
+	if units.Cmp(MaxUnits) > 0 {
+		return nil, fmt.Errorf("%w: %q", ErrTooMuch, amount)
+	}
+	return units, nil
+}
+
+// FormatAmount is the opposite of ParseAmount.
+func FormatAmount(units *big.Int, decimals int) string {
+	s := units.String()
+	if decimals == 0 {
+		return s
+	}
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	whole, fraction := s[:len(s)-decimals], strings.TrimRight(s[len(s)-decimals:], "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
@@ -0,0 +1,205 @@
+package jetton
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func TestParseAmount(t *testing.T) {
+	max := MaxUnits.String()
+	tests := []struct {
+		amount   string
+		decimals int
+		units    string
+		err      error
+	}{
+		{"12.5", 6, "12500000", nil},
+		{"12.5", 9, "12500000000", nil},
+		{"12", 0, "12", nil},
+		{"0.000001", 6, "1", nil},
+		{".5", 1, "5", nil},
+		{"7.", 2, "700", nil},
+		{" 1.25 ", 2, "125", nil},
+		{"0", 9, "0", nil},
+		{max, 0, max, nil},
+
+		{"0.0000001", 6, "", ErrBadAmount}, // not rounded
+		{"1.5", 0, "", ErrBadAmount},
+		{"", 6, "", ErrBadAmount},
+		{".", 6, "", ErrBadAmount},
+		{"-1", 6, "", ErrBadAmount},
+		{"+1", 6, "", ErrBadAmount},
+		{"1.-5", 6, "", ErrBadAmount},
+		{"1.2.3", 6, "", ErrBadAmount},
+		{"1e3", 6, "", ErrBadAmount},
+		{"12,5", 6, "", ErrBadAmount},
+		{"1", -1, "", ErrBadAmount},
+		{new(big.Int).Add(MaxUnits, big.NewInt(1)).String(), 0, "", ErrTooMuch},
+		{"1" + strings.Repeat("0", 28) + ".5", 9, "", ErrTooMuch},
+	}
+	for _, tt := range tests {
+		units, err := ParseAmount(tt.amount, tt.decimals)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseAmount(%q, %d) error %v, want %v", tt.amount, tt.decimals, err, tt.err)
+			continue
+		}
+		if err == nil && units.String() != tt.units {
+			t.Errorf("ParseAmount(%q, %d) = %s, want %s", tt.amount, tt.decimals, units, tt.units)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		units    int64
+		decimals int
+		amount   string
+	}{
+		{12500000, 6, "12.5"},
+		{1, 6, "0.000001"},
+		{1000000, 6, "1"},
+		{0, 9, "0"},
+		{123, 0, "123"},
+		{100, 2, "1"},
+		{105, 2, "1.05"},
+		{5, 1, "0.5"},
+	}
+	for _, tt := range tests {
+		amount := FormatAmount(big.NewInt(tt.units), tt.decimals)
+		if amount != tt.amount {
+			t.Errorf("FormatAmount(%d, %d) = %q, want %q", tt.units, tt.decimals, amount, tt.amount)
+		}
+		if back, err := ParseAmount(amount, tt.decimals); err != nil || back.Int64() != tt.units {
+			t.Errorf("ParseAmount(%q, %d) = %v, %v, want %d", amount, tt.decimals, back, err, tt.units)
+		}
+	}
+}
+
+var (
+	testOwner    = address.NewAddress(0, 0, append(make([]byte, 31), 1))
+	testResponse = address.NewAddress(0, 0, append(make([]byte, 31), 2))
+)
+
+func TestTransferLayout(t *testing.T) {
+	small := cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("hi").EndCell()
+	large := cell.BeginCell().MustStoreSlice(make([]byte, 100), 800).EndCell()
+	tests := []struct {
+		name     string
+		response *address.Address
+		custom   *cell.Cell
+		payload  *cell.Cell
+		inRef    bool // the payload is in a reference, not in the cell
+	}{
+		{"no payload", nil, nil, nil, false},
+		{"comment in the cell", testResponse, nil, small, false},
+		{"large payload in a reference", testResponse, cell.BeginCell().EndCell(), large, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer := Transfer{
+				QueryID:             42,
+				Amount:              big.NewInt(12500000),
+				Destination:         testOwner,
+				ResponseDestination: tt.response,
+				CustomPayload:       tt.custom,
+				ForwardTONAmount:    tlb.MustFromTON("0.01"),
+				ForwardPayload:      tt.payload,
+			}
+			body, err := transfer.ToCell()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := body.BeginParse()
+			if op := s.MustLoadUInt(32); op != OpTransfer {
+				t.Fatalf("op %x", op)
+			}
+			if q := s.MustLoadUInt(64); q != 42 {
+				t.Errorf("query_id %d", q)
+			}
+			if amount := s.MustLoadBigCoins(); amount.Int64() != 12500000 {
+				t.Errorf("amount %s", amount)
+			}
+			if dest := s.MustLoadAddr(); dest.String() != testOwner.String() {
+				t.Errorf("destination %s", dest)
+			}
+			response := s.MustLoadAddr()
+			if tt.response == nil && !response.IsAddrNone() || tt.response != nil && response.String() != tt.response.String() {
+				t.Errorf("response_destination %s", response)
+			}
+			if custom := s.MustLoadMaybeRef(); (custom != nil) != (tt.custom != nil) {
+				t.Errorf("custom_payload %v", custom)
+			}
+			if forward := s.MustLoadBigCoins(); forward.Cmp(tlb.MustFromTON("0.01").NanoTON()) != 0 {
+				t.Errorf("forward_ton_amount %s", forward)
+			}
+			if isRef := s.MustLoadBoolBit(); isRef != tt.inRef {
+				t.Fatalf("forward_payload in a reference: %v, want %v", isRef, tt.inRef)
+			}
+			var payload *cell.Cell
+			if tt.inRef {
+				payload = s.MustLoadRef().MustToCell()
+			} else if s.BitsLeft() > 0 || s.RefsNum() > 0 {
+				payload = s.MustToCell()
+			}
+			if (payload == nil) != (tt.payload == nil) || payload != nil && string(payload.Hash()) != string(tt.payload.Hash()) {
+				t.Errorf("forward_payload %v, want %v", payload, tt.payload)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name     string
+		transfer Transfer
+		err      error
+	}{
+		{"no destination", Transfer{Amount: big.NewInt(1)}, ErrNoReceiver},
+		{"no amount", Transfer{Destination: testOwner}, ErrBadAmount},
+		{"negative", Transfer{Destination: testOwner, Amount: big.NewInt(-1)}, ErrBadAmount},
+		{"above VarUInteger 16", Transfer{Destination: testOwner, Amount: new(big.Int).Lsh(big.NewInt(1), 120)}, ErrTooMuch},
+	}
+	for _, tt := range errorTests {
+		if _, err := tt.transfer.ToCell(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestBurnLayout(t *testing.T) {
+	burn := Burn{QueryID: 7, Amount: MaxUnits, ResponseDestination: testResponse}
+	body, err := burn.ToCell()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := body.BeginParse()
+	if op := s.MustLoadUInt(32); op != OpBurn {
+		t.Fatalf("op %x", op)
+	}
+	if q := s.MustLoadUInt(64); q != 7 {
+		t.Errorf("query_id %d", q)
+	}
+	if amount := s.MustLoadBigCoins(); amount.Cmp(MaxUnits) != 0 {
+		t.Errorf("amount %s", amount)
+	}
+	if response := s.MustLoadAddr(); response.String() != testResponse.String() {
+		t.Errorf("response_destination %s", response)
+	}
+	if custom := s.MustLoadMaybeRef(); custom != nil || s.BitsLeft() != 0 || s.RefsNum() != 0 {
+		t.Errorf("custom_payload %v, %d bits and %d refs left", custom, s.BitsLeft(), s.RefsNum())
+	}
+
+	burn.Amount = new(big.Int).Add(MaxUnits, big.NewInt(1))
+	if _, err = burn.ToCell(); !errors.Is(err, ErrTooMuch) {
+		t.Errorf("above VarUInteger 16: error %v, want ErrTooMuch", err)
+	}
+	burn.Amount = nil
+	if _, err = burn.ToCell(); !errors.Is(err, ErrBadAmount) {
+		t.Errorf("no amount: error %v, want ErrBadAmount", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package jsonfile keeps a value in a JSON file which several processes
+// change: Lock holds a lock file next to it while one of them reads and
+// writes it, and Save writes it to a temporary file first, so a crash in the
+// middle never leaves half a file. The journals of query_ids use it.
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StaleLock is the age after which the lock file is left by a crashed
+// process. The lock is only held while the file is read and written.
+const StaleLock = 30 * time.Second
+
+var ErrLocked = errors.New("jsonfile: the file is locked by another process")
+
+// Load reads the JSON file at path into v. It returns false without an error
+// if there is no file yet.
+func Load(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("jsonfile: read %s: %w", path, err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("jsonfile: parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// Save writes v to a temporary file next to path and renames it to path.
+func Save(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("jsonfile: save %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("jsonfile: save %s: %w", path, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("jsonfile: save %s: %w", path, err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("jsonfile: save %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lock creates the lock file path.lock, waiting while another process has
+// it, until ctx is done. A lock file older than StaleLock is removed.
+func Lock(ctx context.Context, path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			lockFile.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("jsonfile: lock %s: %w", path, err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > StaleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %s", ErrLocked, lockPath)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Update locks the file at path, loads it into v, calls change and saves v.
+// v keeps what Load left in it when there is no file, change sees exists false.
+func Update(ctx context.Context, path string, v any, change func(exists bool) error) error {
+	unlock, err := Lock(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := Load(path, v)
+	if err != nil {
+		return err
+	}
+	if err = change(exists); err != nil {
+		return err
+	}
+	return Save(path, v)
+}
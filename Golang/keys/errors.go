@@ -0,0 +1,29 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrWordsCount is returned when a mnemonic does not have exactly MnemonicWords words.
+	ErrWordsCount = errors.New("keys: mnemonic must contain exactly 24 words")
+	// ErrInvalidMnemonic is returned when the words are valid, but the phrase fails
+	// the TON seed version check. This is what a swapped or mistyped word usually looks like,
+	// for a mnemonic with a password it also means that the password is wrong.
+	ErrInvalidMnemonic = errors.New("keys: mnemonic failed the TON seed version check")
+	// ErrPasswordRequired is returned when a mnemonic generated with a password is used without it.
+	ErrPasswordRequired = errors.New("keys: mnemonic is protected by a password, but no password was given")
+	// ErrPasswordNotNeeded is returned when a password is given for a mnemonic generated without one.
+	ErrPasswordNotNeeded = errors.New("keys: password was given, but the mnemonic is not protected by a password")
+)
+
+// UnknownWordError reports a word which is not in the wordlist.
+type UnknownWordError struct {
+	Index int // position of the word in the mnemonic, starting from 0
+	Word  string
+}
+
+func (e *UnknownWordError) Error() string {
+	return fmt.Sprintf("keys: word #%d %q is not in the mnemonic wordlist", e.Index+1, e.Word)
+}
@@ -0,0 +1,40 @@
+package keys
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// NewMnemonic generates a new random mnemonic which does not need a password.
+func NewMnemonic() ([]string, error) {
+	return NewMnemonicWithPassword("")
+}
+
+// NewMnemonicWithPassword generates a new random mnemonic. If password is not
+// empty, the mnemonic can be used only together with it.
+//
+// Random phrases are generated until one passes the checks, the same way
+// the official TON libraries do it. With a password it takes more attempts,
+// but it is still fast because the first check is a single pbkdf2 iteration.
+func NewMnemonicWithPassword(password string) ([]string, error) {
+	max := big.NewInt(int64(len(wordlist)))
+	mnemonic := make([]string, MnemonicWords)
+
+	for {
+		for i := range mnemonic {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			mnemonic[i] = wordlist[n.Int64()]
+		}
+
+		if password != "" && !IsPasswordNeeded(mnemonic) {
+			continue
+		}
+		if !isBasicSeed(entropy(mnemonic, password)) {
+			continue
+		}
+		return mnemonic, nil
+	}
+}
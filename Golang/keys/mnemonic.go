@@ -0,0 +1,124 @@
+// Package keys turns TON mnemonic phrases into ed25519 key pairs.
+//
+// It does the same hmac + pbkdf2 steps the tutorial chapters show, but checks
+// the phrase first, so a typo gives an error instead of a different wallet.
+// Mnemonics protected by a password are supported as well.
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// MnemonicWords is the number of words in a TON mnemonic.
+const MnemonicWords = 24
+
+const (
+	iterations   = 100000
+	defaultSalt  = "TON default seed"      // salt for the private key itself
+	basicSalt    = "TON seed version"      // salt for the check which every mnemonic must pass
+	passwordSalt = "TON fast seed version" // salt for the check of a mnemonic which needs a password
+)
+
+// KeyPair is an ed25519 key pair derived from a mnemonic.
+type KeyPair struct {
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// ParseMnemonic splits a phrase like "word1 word2 word3" into words.
+// Extra spaces, new lines and upper case letters are ignored.
+func ParseMnemonic(phrase string) []string {
+	return strings.Fields(strings.ToLower(phrase))
+}
+
+// Validate checks the word count, that every word is in the wordlist and that
+// the phrase passes the TON seed version check. It is the same as
+// ValidateWithPassword with an empty password.
+func Validate(mnemonic []string) error {
+	return ValidateWithPassword(mnemonic, "")
+}
+
+// ValidateWithPassword checks a mnemonic together with its password.
+// A mnemonic created with a password can not be used without it and vice versa,
+// ErrPasswordRequired and ErrPasswordNotNeeded are returned in these cases.
+func ValidateWithPassword(mnemonic []string, password string) error {
+	if len(mnemonic) != MnemonicWords {
+		return ErrWordsCount
+	}
+	for i, w := range mnemonic {
+		if !IsWord(w) {
+			return &UnknownWordError{Index: i, Word: w}
+		}
+	}
+
+	needed := IsPasswordNeeded(mnemonic)
+	if password == "" && needed {
+		return ErrPasswordRequired
+	}
+	if password != "" && !needed {
+		return ErrPasswordNotNeeded
+	}
+
+	if !isBasicSeed(entropy(mnemonic, password)) {
+		return ErrInvalidMnemonic
+	}
+	return nil
+}
+
+// IsPasswordNeeded reports whether the mnemonic was generated with a password.
+// The words alone are enough to tell it, the password itself is not needed.
+func IsPasswordNeeded(mnemonic []string) bool {
+	e := entropy(mnemonic, "")
+	return isPasswordSeed(e) && !isBasicSeed(e)
+}
+
+// FromMnemonic validates the mnemonic and derives the key pair from it.
+func FromMnemonic(mnemonic []string) (*KeyPair, error) {
+	return FromMnemonicWithPassword(mnemonic, "")
+}
+
+// FromMnemonicWithPassword validates the mnemonic with its password and derives
+// the key pair from them.
+func FromMnemonicWithPassword(mnemonic []string, password string) (*KeyPair, error) {
+	if err := ValidateWithPassword(mnemonic, password); err != nil {
+		return nil, err
+	}
+	return fromEntropy(entropy(mnemonic, password)), nil
+}
+
+// entropy is the HMAC-SHA512 of the password with the mnemonic as a key,
+// all the other values are derived from it.
+func entropy(mnemonic []string, password string) []byte {
+	mac := hmac.New(sha512.New, []byte(strings.Join(mnemonic, " ")))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// isBasicSeed is the check made by every TON wallet: the first byte of
+// pbkdf2(entropy, "TON seed version") must be zero.
+func isBasicSeed(entropy []byte) bool {
+	p := pbkdf2.Key(entropy, []byte(basicSalt), iterations/256, 1, sha512.New)
+	return p[0] == 0
+}
+
+// isPasswordSeed marks mnemonics generated with a password: the first byte of
+// pbkdf2(entropy, "TON fast seed version") with a single iteration must be one.
+func isPasswordSeed(entropy []byte) bool {
+	p := pbkdf2.Key(entropy, []byte(passwordSalt), 1, 1, sha512.New)
+	return p[0] == 1
+}
+
+func fromEntropy(entropy []byte) *KeyPair {
+	seed := pbkdf2.Key(entropy, []byte(defaultSalt), iterations, ed25519.SeedSize, sha512.New)
+	privateKey := ed25519.NewKeyFromSeed(seed)
+
+	return &KeyPair{
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public().(ed25519.PublicKey),
+	}
+}
@@ -0,0 +1,127 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// The keys without a password were derived by tonutils-go, an independent
+// implementation. tonutils-go marks password mnemonics differently from
+// tonweb-mnemonic, so the key with a password follows tonweb-mnemonic step by step.
+var vectors = []struct {
+	name      string
+	mnemonic  string
+	password  string
+	publicKey string
+}{
+	{
+		name:      "no password",
+		mnemonic:  "exit thought tenant price saddle lawn vibrant square casino unit wage agree wedding quiz vessel kiss eyebrow shrug swing inject survey clean soap rotate",
+		publicKey: "de69542b87e3e052a72fa901a6c424fb6ef4456c684d36e86e292f1f44386e5e",
+	},
+	{
+		name:      "no password 2",
+		mnemonic:  "exercise discover vehicle pizza apart road bundle reveal upon twelve stereo stool donkey festival promote license dentist bench used close depth train task sadness",
+		publicKey: "194a99a88051b9aa2006a15cc48917af29ab4de5c17de590c9c8724ba9bb5e39",
+	},
+	{
+		name:      "password",
+		mnemonic:  "dentist pole raccoon december head sister imitate wine never fee among media like device twenty tumble shock employ any chat visa donkey truth nasty",
+		password:  "correct horse",
+		publicKey: "519d4a3a598cc8f48d1193ad50ad1c17803caf765bb3efd6c0414b0388c5d14a",
+	},
+}
+
+func TestFromMnemonicVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			mnemonic := ParseMnemonic(v.mnemonic)
+			if got, want := IsPasswordNeeded(mnemonic), v.password != ""; got != want {
+				t.Fatalf("IsPasswordNeeded = %v, want %v", got, want)
+			}
+			keyPair, err := FromMnemonicWithPassword(mnemonic, v.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(keyPair.PublicKey); got != v.publicKey {
+				t.Fatalf("public key %s, want %s", got, v.publicKey)
+			}
+			if !bytes.Equal(keyPair.PrivateKey.Public().(ed25519.PublicKey), keyPair.PublicKey) {
+				t.Fatal("the private key does not match the public key")
+			}
+		})
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	basic := ParseMnemonic(vectors[0].mnemonic)
+	withPassword := ParseMnemonic(vectors[2].mnemonic)
+	swapped := append([]string{}, basic...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	unknown := append([]string{}, basic...)
+	unknown[5] = "tonkeeper"
+
+	tests := []struct {
+		name     string
+		mnemonic []string
+		password string
+		err      error
+	}{
+		{"valid", basic, "", nil},
+		{"valid with password", withPassword, vectors[2].password, nil},
+		{"23 words", basic[:23], "", ErrWordsCount},
+		{"25 words", append(append([]string{}, basic...), "abandon"), "", ErrWordsCount},
+		{"swapped words", swapped, "", ErrInvalidMnemonic},
+		{"password missing", withPassword, "", ErrPasswordRequired},
+		{"password not needed", basic, "correct horse", ErrPasswordNotNeeded},
+		{"wrong password", withPassword, "wrong horse", ErrInvalidMnemonic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateWithPassword(tt.mnemonic, tt.password); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("unknown word", func(t *testing.T) {
+		var wordErr *UnknownWordError
+		if err := Validate(unknown); !errors.As(err, &wordErr) || wordErr.Index != 5 || wordErr.Word != "tonkeeper" {
+			t.Fatalf("error %v, want an UnknownWordError for word 6", err)
+		}
+	})
+}
+
+func TestParseMnemonic(t *testing.T) {
+	phrase := "  EXIT thought\n\ttenant  " + strings.Repeat("x ", 2)
+	got := ParseMnemonic(phrase)
+	want := []string{"exit", "thought", "tenant", "x", "x"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ParseMnemonic = %q, want %q", got, want)
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Validate(mnemonic); err != nil {
+		t.Fatal(err)
+	}
+
+	mnemonic, err = NewMnemonicWithPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPasswordNeeded(mnemonic) {
+		t.Fatal("a mnemonic generated with a password does not need one")
+	}
+	if err = ValidateWithPassword(mnemonic, "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,28 @@
+package keys
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// TON mnemonics use the same 2048 English words as BIP-39, but the checksum
+// rules are different, so only the list itself is shared.
+//
+//go:embed wordlist.txt
+var wordlistText string
+
+var (
+	wordlist = strings.Fields(wordlistText)
+	wordset  = func() map[string]bool {
+		set := make(map[string]bool, len(wordlist))
+		for _, w := range wordlist {
+			set[w] = true
+		}
+		return set
+	}()
+)
+
+// IsWord reports whether word belongs to the TON mnemonic wordlist.
+func IsWord(word string) bool {
+	return wordset[word]
+}
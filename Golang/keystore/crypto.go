@@ -0,0 +1,111 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+
+	"main/keys"
+)
+
+// scrypt parameters for new keys, it takes about a quarter of a second to unlock a key.
+const (
+	scryptN = 1 << 17
+	scryptR = 8
+	scryptP = 1
+)
+
+type kdfParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+func (k kdfParams) deriveKey(passphrase string) ([]byte, error) {
+	if k.Name != "scrypt" {
+		return nil, fmt.Errorf("keystore: unsupported kdf %q", k.Name)
+	}
+	// The parameters come from the file, an exported key from someone else
+	// could ask for gigabytes of memory: nothing above what newEntry writes
+	if k.N > scryptN || k.R > scryptR || k.P > scryptP {
+		return nil, fmt.Errorf("%w: n=%d r=%d p=%d", ErrKDFParams, k.N, k.R, k.P)
+	}
+	return scrypt.Key([]byte(passphrase), k.Salt, k.N, k.R, k.P, chacha20poly1305.KeySize)
+}
+
+// newEntry validates the mnemonic and encrypts it with passphrase.
+func newEntry(s secret, passphrase string) (*Entry, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	keyPair, err := keys.FromMnemonicWithPassword(s.Mnemonic, s.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 32)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		PublicKey: hex.EncodeToString(keyPair.PublicKey),
+		CreatedAt: time.Now().UTC(),
+		KDF:       kdfParams{Name: "scrypt", Salt: salt, N: scryptN, R: scryptR, P: scryptP},
+		Nonce:     make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err = rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+
+	key, err := e.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// public key is authenticated too, so it can not be swapped in the file without notice
+	e.Ciphertext = aead.Seal(nil, e.Nonce, plaintext, []byte(e.PublicKey))
+	return e, nil
+}
+
+func (e *Entry) decrypt(passphrase string) (secret, error) {
+	key, err := e.KDF.deriveKey(passphrase)
+	if err != nil {
+		return secret{}, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return secret{}, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return secret{}, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, []byte(e.PublicKey))
+	if err != nil {
+		return secret{}, ErrWrongPassphrase
+	}
+
+	var s secret
+	if err = json.Unmarshal(plaintext, &s); err != nil {
+		return secret{}, fmt.Errorf("keystore: decode key: %w", err)
+	}
+	return s, nil
+}
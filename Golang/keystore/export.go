@@ -0,0 +1,51 @@
+package keystore
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Export returns one key as a standalone JSON document. The key stays encrypted
+// with its passphrase, so the result can be copied to another machine as is.
+func (ks *Keystore) Export(name string) ([]byte, error) {
+	e, ok := ks.file.Keys[name]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	exported := *e
+	exported.Name = name
+	return json.MarshalIndent(exported, "", "  ")
+}
+
+// Import adds a key made by Export. If name is empty, the name stored in the
+// export is used. The passphrase is needed to make sure the key is not damaged
+// and really belongs to the public key written next to it.
+func (ks *Keystore) Import(data []byte, name, passphrase string) error {
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("keystore: parse exported key: %w", err)
+	}
+
+	if name == "" {
+		name = e.Name
+	}
+	if name == "" {
+		return ErrEmptyName
+	}
+	if _, ok := ks.file.Keys[name]; ok {
+		return ErrKeyExists
+	}
+
+	s, err := e.decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err = e.keyPair(s); err != nil {
+		return err
+	}
+
+	e.Name = ""
+	ks.file.Keys[name] = &e
+	return ks.save()
+}
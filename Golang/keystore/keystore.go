@@ -0,0 +1,252 @@
+// Package keystore keeps mnemonics in an encrypted file instead of the source code.
+//
+// The file is JSON with any number of named keys. Every key is encrypted with its
+// own passphrase: the encryption key is derived with scrypt and the mnemonic is
+// sealed with XChaCha20-Poly1305. Public keys stay readable, so a key can be
+// found without unlocking it.
+package keystore
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"main/keys"
+)
+
+// PassphraseEnv is the environment variable the examples read the keystore passphrase from.
+const PassphraseEnv = "TON_KEYSTORE_PASSPHRASE"
+
+const fileVersion = 1
+
+var (
+	ErrKeyNotFound      = errors.New("keystore: key not found")
+	ErrKeyExists        = errors.New("keystore: key with this name already exists")
+	ErrWrongPassphrase  = errors.New("keystore: wrong passphrase or damaged key")
+	ErrEmptyName        = errors.New("keystore: key name is empty")
+	ErrEmptyPassphrase  = errors.New("keystore: passphrase is empty")
+	ErrUnsupportedFile  = errors.New("keystore: unsupported file version")
+	ErrPublicKeyChanged = errors.New("keystore: decrypted mnemonic does not match the stored public key")
+	ErrKDFParams        = errors.New("keystore: scrypt parameters are above the ones this keystore writes")
+)
+
+// Keystore is a keystore file loaded in memory. Changes are written to disk right away.
+type Keystore struct {
+	path string
+	file file
+}
+
+type file struct {
+	Version int               `json:"version"`
+	Keys    map[string]*Entry `json:"keys"`
+}
+
+// Entry is one encrypted key. It is also the format of an exported key.
+type Entry struct {
+	Name       string    `json:"name,omitempty"` // set only in exported keys
+	PublicKey  string    `json:"public_key"`     // hex
+	CreatedAt  time.Time `json:"created_at"`
+	KDF        kdfParams `json:"kdf"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// secret is what is actually encrypted.
+type secret struct {
+	Mnemonic []string `json:"mnemonic"`
+	Password string   `json:"password,omitempty"` // password of the mnemonic, not the keystore passphrase
+}
+
+// Open loads the keystore from path. If the file does not exist, an empty
+// keystore is returned and the file is created on the first change.
+func Open(path string) (*Keystore, error) {
+	ks := &Keystore{
+		path: path,
+		file: file{Version: fileVersion, Keys: map[string]*Entry{}},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keystore: read %s: %w", path, err)
+	}
+
+	if err = json.Unmarshal(data, &ks.file); err != nil {
+		return nil, fmt.Errorf("keystore: parse %s: %w", path, err)
+	}
+	if ks.file.Version != fileVersion {
+		return nil, ErrUnsupportedFile
+	}
+	if ks.file.Keys == nil {
+		ks.file.Keys = map[string]*Entry{}
+	}
+	return ks, nil
+}
+
+// LoadKey opens the keystore and unlocks one key, it is what the examples use
+// to get their signing key.
+func LoadKey(path, name, passphrase string) (*keys.KeyPair, error) {
+	ks, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Unlock(name, passphrase)
+}
+
+// Names returns the names of all keys, sorted.
+func (ks *Keystore) Names() []string {
+	names := make([]string, 0, len(ks.file.Keys))
+	for name := range ks.file.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PublicKey returns the public key of a key without unlocking it.
+func (ks *Keystore) PublicKey(name string) (ed25519.PublicKey, error) {
+	e, ok := ks.file.Keys[name]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return e.publicKey()
+}
+
+// Add encrypts the mnemonic with passphrase and stores it under name.
+// mnemonicPassword is the password of the mnemonic itself, empty for most mnemonics.
+func (ks *Keystore) Add(name string, mnemonic []string, mnemonicPassword, passphrase string) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if _, ok := ks.file.Keys[name]; ok {
+		return ErrKeyExists
+	}
+
+	e, err := newEntry(secret{Mnemonic: mnemonic, Password: mnemonicPassword}, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks.file.Keys[name] = e
+	return ks.save()
+}
+
+// Unlock decrypts a key and derives the key pair from it.
+func (ks *Keystore) Unlock(name, passphrase string) (*keys.KeyPair, error) {
+	e, ok := ks.file.Keys[name]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	s, err := e.decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return e.keyPair(s)
+}
+
+// Mnemonic decrypts a key and returns its mnemonic and the mnemonic password,
+// for example to write them down as a backup.
+func (ks *Keystore) Mnemonic(name, passphrase string) ([]string, string, error) {
+	e, ok := ks.file.Keys[name]
+	if !ok {
+		return nil, "", ErrKeyNotFound
+	}
+
+	s, err := e.decrypt(passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.Mnemonic, s.Password, nil
+}
+
+// ChangePassphrase encrypts a key again with a new passphrase.
+func (ks *Keystore) ChangePassphrase(name, oldPassphrase, newPassphrase string) error {
+	e, ok := ks.file.Keys[name]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	s, err := e.decrypt(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	updated, err := newEntry(s, newPassphrase)
+	if err != nil {
+		return err
+	}
+	updated.CreatedAt = e.CreatedAt
+
+	ks.file.Keys[name] = updated
+	return ks.save()
+}
+
+// Remove deletes a key from the keystore.
+func (ks *Keystore) Remove(name string) error {
+	if _, ok := ks.file.Keys[name]; !ok {
+		return ErrKeyNotFound
+	}
+	delete(ks.file.Keys, name)
+	return ks.save()
+}
+
+// save writes the keystore to a temporary file and renames it, so a crash
+// in the middle never leaves a half written keystore.
+func (ks *Keystore) save() error {
+	data, err := json.MarshalIndent(ks.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), ".keystore-*")
+	if err != nil {
+		return fmt.Errorf("keystore: save: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("keystore: save: %w", err)
+	}
+	if err = tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("keystore: save: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("keystore: save: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), ks.path); err != nil {
+		return fmt.Errorf("keystore: save: %w", err)
+	}
+	return nil
+}
+
+func (e *Entry) publicKey() (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(e.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("keystore: invalid public key %q", e.PublicKey)
+	}
+	return key, nil
+}
+
+// keyPair derives the keys from a decrypted secret and checks them against the stored public key.
+func (e *Entry) keyPair(s secret) (*keys.KeyPair, error) {
+	keyPair, err := keys.FromMnemonicWithPassword(s.Mnemonic, s.Password)
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(keyPair.PublicKey) != e.PublicKey {
+		return nil, ErrPublicKeyChanged
+	}
+	return keyPair, nil
+}
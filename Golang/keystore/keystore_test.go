@@ -0,0 +1,158 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"main/keys"
+)
+
+func newMnemonic(t *testing.T) []string {
+	t.Helper()
+	mnemonic, err := keys.NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mnemonic
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	ks, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic := newMnemonic(t)
+	want, err := keys.FromMnemonic(mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ks.Add("main", mnemonic, "", "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load reads the file again
+	keyPair, err := LoadKey(path, "main", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(keyPair.PublicKey, want.PublicKey) {
+		t.Fatal("loaded key differs from the added one")
+	}
+	publicKey, err := ks.PublicKey("main")
+	if err != nil || !bytes.Equal(publicKey, want.PublicKey) {
+		t.Fatalf("PublicKey = %x, %v", publicKey, err)
+	}
+
+	if err = ks.ChangePassphrase("main", "first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadKey(path, "main", "first"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("old passphrase: error %v, want ErrWrongPassphrase", err)
+	}
+	got, password, err := ks.Mnemonic("main", "second")
+	if err != nil || password != "" || len(got) != len(mnemonic) || got[0] != mnemonic[0] {
+		t.Fatalf("Mnemonic = %v, %q, %v", got, password, err)
+	}
+
+	exported, err := ks.Export("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(filepath.Join(t.TempDir(), "other.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = other.Import(exported, "", "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("import with a wrong passphrase: error %v, want ErrWrongPassphrase", err)
+	}
+	if err = other.Import(exported, "", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.Import(exported, "", "second"); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("second import: error %v, want ErrKeyExists", err)
+	}
+	imported, err := other.Unlock("main", "second")
+	if err != nil || !bytes.Equal(imported.PublicKey, want.PublicKey) {
+		t.Fatalf("imported key: %v", err)
+	}
+}
+
+func TestKeystoreErrors(t *testing.T) {
+	ks, err := Open(filepath.Join(t.TempDir(), "keystore.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic := newMnemonic(t)
+	if err = ks.Add("main", mnemonic, "", "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		err  error
+	}{
+		{"empty name", func() error { return ks.Add("", mnemonic, "", "passphrase") }, ErrEmptyName},
+		{"duplicate name", func() error { return ks.Add("main", mnemonic, "", "passphrase") }, ErrKeyExists},
+		{"empty passphrase", func() error { return ks.Add("other", mnemonic, "", "") }, ErrEmptyPassphrase},
+		{"invalid mnemonic", func() error { return ks.Add("other", mnemonic[:12], "", "passphrase") }, keys.ErrWordsCount},
+		{"wrong passphrase", func() error { _, err := ks.Unlock("main", "wrong"); return err }, ErrWrongPassphrase},
+		{"unknown key", func() error { _, err := ks.Unlock("nope", "passphrase"); return err }, ErrKeyNotFound},
+		{"change with wrong passphrase", func() error { return ks.ChangePassphrase("main", "wrong", "new") }, ErrWrongPassphrase},
+		{"remove unknown key", func() error { return ks.Remove("nope") }, ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestImportRejectsExpensiveScrypt(t *testing.T) {
+	ks, err := Open(filepath.Join(t.TempDir(), "keystore.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ks.Add("main", newMnemonic(t), "", "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ks.Export("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		n, r, p int
+	}{
+		{"n", scryptN << 4, scryptR, scryptP},
+		{"r", scryptN, scryptR * 64, scryptP},
+		{"p", scryptN, scryptR, 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Entry
+			if err := json.Unmarshal(exported, &e); err != nil {
+				t.Fatal(err)
+			}
+			e.KDF.N, e.KDF.R, e.KDF.P = tt.n, tt.r, tt.p
+			crafted, err := json.Marshal(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := Open(filepath.Join(t.TempDir(), "other.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = other.Import(crafted, "", "passphrase"); !errors.Is(err, ErrKDFParams) {
+				t.Fatalf("error %v, want ErrKDFParams", err)
+			}
+		})
+	}
+}
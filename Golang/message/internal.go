@@ -0,0 +1,268 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Internal is an internal message (int_msg_info$0) with named fields instead of
+// the packed bits of the chapters, where 0x18 is the six bits 0 1 1 0 00:
+// int_msg_info$0, ihr_disabled, bounce, bounced and src = addr_none.
+//
+// The zero value of every field is what a wallet sends: IHR disabled, no bounce,
+// no source (the validators put the wallet address there), no fees and no times.
+type Internal struct {
+	IHREnabled bool // ihr_disabled is stored as the opposite
+	Bounce     bool
+	Bounced    bool
+	Source     *address.Address // nil is addr_none
+	Dest       *address.Address
+	Value      tlb.Coins
+
+	// ExtraCurrencies maps a 32-bit currency ID to its amount (VarUInteger 32), nil if there are none.
+	ExtraCurrencies *cell.Dictionary
+
+	IHRFee    tlb.Coins
+	FwdFee    tlb.Coins
+	CreatedLT uint64
+	CreatedAt uint32
+
+	StateInit *cell.Cell // nil if the message does not deploy a contract
+	Body      *cell.Cell // nil for an empty body
+
+	// By default StateInit and Body are stored in the message cell when they fit
+	// and as references when they do not. These force a reference, like the chapters do.
+	StateInitRef bool
+	BodyRef      bool
+}
+
+// ToCell serializes the message.
+func (m *Internal) ToCell() (*cell.Cell, error) {
+	if m.Dest == nil {
+		return nil, errors.New("message: internal message has no destination")
+	}
+
+	source := m.Source
+	if source == nil {
+		source = address.NewAddressNone()
+	}
+
+	b := cell.BeginCell().
+		MustStoreUInt(0, 1).             // int_msg_info$0
+		MustStoreBoolBit(!m.IHREnabled). // ihr_disabled
+		MustStoreBoolBit(m.Bounce).
+		MustStoreBoolBit(m.Bounced).
+		MustStoreAddr(source).
+		MustStoreAddr(m.Dest).
+		MustStoreBigCoins(m.Value.NanoTON())
+
+	if err := b.StoreDict(m.ExtraCurrencies); err != nil { // the other part of CurrencyCollection
+		return nil, fmt.Errorf("message: extra currencies: %w", err)
+	}
+
+	b.MustStoreBigCoins(m.IHRFee.NanoTON()).
+		MustStoreBigCoins(m.FwdFee.NanoTON()).
+		MustStoreUInt(m.CreatedLT, 64).
+		MustStoreUInt(uint64(m.CreatedAt), 32)
+
+	bodyBits, bodyRefs := uint(0), uint(0)
+	if m.Body != nil {
+		bodyBits, bodyRefs = m.Body.BitsSize(), m.Body.RefsNum()
+	}
+
+	if m.StateInit == nil {
+		b.MustStoreBoolBit(false) // No State Init
+	} else {
+		b.MustStoreBoolBit(true) // We have State Init
+
+		// inline only if the body still has room for at least its Either bit after it
+		inline := !m.StateInitRef &&
+			b.BitsLeft() >= 1+m.StateInit.BitsSize()+1 &&
+			b.RefsLeft() >= m.StateInit.RefsNum()
+		if inline {
+			b.MustStoreBoolBit(false) // State Init is stored in this cell
+			b.MustStoreBuilder(m.StateInit.ToBuilder())
+		} else {
+			b.MustStoreBoolBit(true) // We store State Init as a reference
+			b.MustStoreRef(m.StateInit)
+		}
+	}
+
+	inline := !m.BodyRef && b.BitsLeft() >= 1+bodyBits && b.RefsLeft() >= bodyRefs
+	switch {
+	case m.Body == nil:
+		b.MustStoreBoolBit(false) // empty body in this cell
+	case inline:
+		b.MustStoreBoolBit(false) // Message Body is stored in this cell
+		b.MustStoreBuilder(m.Body.ToBuilder())
+	default:
+		if b.RefsLeft() == 0 {
+			return nil, errors.New("message: no room for the body reference")
+		}
+		b.MustStoreBoolBit(true) // We store Message Body as a reference
+		b.MustStoreRef(m.Body)
+	}
+
+	return b.EndCell(), nil
+}
+
+// MustToCell is ToCell which panics, for messages built from constants.
+func (m *Internal) MustToCell() *cell.Cell {
+	c, err := m.ToCell()
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ParseInternal decodes an internal message. ToCell of the result gives the same cell.
+func ParseInternal(c *cell.Cell) (*Internal, error) {
+	s := c.BeginParse()
+	m := &Internal{}
+
+	tag, err := s.LoadUInt(1)
+	if err != nil {
+		return nil, err
+	}
+	if tag != 0 {
+		return nil, ErrNotInternal
+	}
+
+	ihrDisabled, err := s.LoadBoolBit()
+	if err != nil {
+		return nil, err
+	}
+	m.IHREnabled = !ihrDisabled
+	if m.Bounce, err = s.LoadBoolBit(); err != nil {
+		return nil, err
+	}
+	if m.Bounced, err = s.LoadBoolBit(); err != nil {
+		return nil, err
+	}
+
+	if m.Source, err = s.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("message: source: %w", err)
+	}
+	if m.Source.IsAddrNone() {
+		m.Source = nil
+	}
+	if m.Dest, err = s.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("message: destination: %w", err)
+	}
+
+	if m.Value, err = loadCoins(s); err != nil {
+		return nil, fmt.Errorf("message: value: %w", err)
+	}
+	extra, err := s.LoadDict(32)
+	if err != nil {
+		return nil, fmt.Errorf("message: extra currencies: %w", err)
+	}
+	if len(extra.All()) > 0 {
+		m.ExtraCurrencies = extra
+	}
+
+	if m.IHRFee, err = loadCoins(s); err != nil {
+		return nil, fmt.Errorf("message: ihr_fee: %w", err)
+	}
+	if m.FwdFee, err = loadCoins(s); err != nil {
+		return nil, fmt.Errorf("message: fwd_fee: %w", err)
+	}
+	if m.CreatedLT, err = s.LoadUInt(64); err != nil {
+		return nil, err
+	}
+	createdAt, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, err
+	}
+	m.CreatedAt = uint32(createdAt)
+
+	hasStateInit, err := s.LoadBoolBit()
+	if err != nil {
+		return nil, err
+	}
+	if hasStateInit {
+		if m.StateInitRef, err = s.LoadBoolBit(); err != nil {
+			return nil, err
+		}
+		if m.StateInitRef {
+			m.StateInit, err = loadRefCell(s)
+		} else {
+			m.StateInit, err = loadStateInit(s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("message: state init: %w", err)
+		}
+	}
+
+	if m.BodyRef, err = s.LoadBoolBit(); err != nil {
+		return nil, err
+	}
+	if m.BodyRef {
+		if m.Body, err = loadRefCell(s); err != nil {
+			return nil, fmt.Errorf("message: body: %w", err)
+		}
+	} else if s.BitsLeft() > 0 || s.RefsNum() > 0 {
+		if m.Body, err = s.ToCell(); err != nil {
+			return nil, fmt.Errorf("message: body: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// loadStateInit reads a StateInit stored inline; its length is known only from its fields.
+func loadStateInit(s *cell.Slice) (*cell.Cell, error) {
+	b := cell.BeginCell()
+
+	// split_depth:(Maybe (## 5)) special:(Maybe TickTock)
+	for _, size := range []uint{5, 2} {
+		has, err := s.LoadBoolBit()
+		if err != nil {
+			return nil, err
+		}
+		b.MustStoreBoolBit(has)
+		if has {
+			v, err := s.LoadUInt(size)
+			if err != nil {
+				return nil, err
+			}
+			b.MustStoreUInt(v, size)
+		}
+	}
+
+	// code:(Maybe ^Cell) data:(Maybe ^Cell) library:(HashmapE 256 SimpleLib)
+	for i := 0; i < 3; i++ {
+		has, err := s.LoadBoolBit()
+		if err != nil {
+			return nil, err
+		}
+		b.MustStoreBoolBit(has)
+		if has {
+			ref, err := loadRefCell(s)
+			if err != nil {
+				return nil, err
+			}
+			b.MustStoreRef(ref)
+		}
+	}
+	return b.EndCell(), nil
+}
+
+func loadRefCell(s *cell.Slice) (*cell.Cell, error) {
+	ref, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+	return ref.ToCell()
+}
+
+func loadCoins(s *cell.Slice) (tlb.Coins, error) {
+	v, err := s.LoadBigCoins()
+	if err != nil {
+		return tlb.Coins{}, err
+	}
+	return tlb.FromNanoTON(v), nil
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func TestInternalRoundTrip(t *testing.T) {
+	dest := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	source := address.NewAddress(0, 0, make([]byte, 32))
+	smallBody := cell.BeginCell().MustStoreUInt(0, 32).MustStoreStringSnake("Hello, TON!").EndCell()
+	bigBody := cell.BeginCell().MustStoreSlice(make([]byte, 120), 960).EndCell() // does not fit next to the header
+	code := cell.BeginCell().MustStoreUInt(1, 8).EndCell()
+	data := cell.BeginCell().MustStoreUInt(2, 8).EndCell()
+	stateInit := cell.BeginCell().
+		MustStoreBoolBit(false). // No split_depth
+		MustStoreBoolBit(false). // No special
+		MustStoreBoolBit(true).MustStoreRef(code).
+		MustStoreBoolBit(true).MustStoreRef(data).
+		MustStoreBoolBit(false). // No library
+		EndCell()
+
+	tests := []struct {
+		name             string
+		msg              Internal
+		wantBodyRef      bool
+		wantStateInitRef bool
+	}{
+		{"empty body", Internal{Dest: dest, Value: tlb.MustFromTON("0.2")}, false, false},
+		{"small body inline", Internal{Dest: dest, Value: tlb.MustFromTON("0.2"), Bounce: true, Body: smallBody}, false, false},
+		{"small body forced to a ref", Internal{Dest: dest, Value: tlb.MustFromTON("0.2"), Bounce: true, Body: smallBody, BodyRef: true}, true, false},
+		{"big body goes to a ref", Internal{Dest: dest, Value: tlb.MustFromTON("1"), Body: bigBody}, true, false},
+		{"state init inline", Internal{Dest: dest, Value: tlb.MustFromTON("0.05"), StateInit: stateInit, Body: smallBody}, false, false},
+		{"state init forced to a ref", Internal{Dest: dest, Value: tlb.MustFromTON("0.05"), StateInit: stateInit, StateInitRef: true, Body: smallBody, BodyRef: true}, true, true},
+		{"state init inline, big body", Internal{Dest: dest, Value: tlb.MustFromTON("0.05"), StateInit: stateInit, Body: bigBody}, true, false},
+		{"all header fields", Internal{
+			IHREnabled: true, Bounce: true, Bounced: true, Source: source, Dest: dest,
+			Value: tlb.MustFromTON("3"), IHRFee: tlb.MustFromTON("0.001"), FwdFee: tlb.MustFromTON("0.002"),
+			CreatedLT: 123456, CreatedAt: 1700000000, Body: smallBody,
+		}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.msg.ToCell()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseInternal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.BodyRef != tt.wantBodyRef {
+				t.Errorf("BodyRef = %v, want %v", parsed.BodyRef, tt.wantBodyRef)
+			}
+			if parsed.StateInitRef != tt.wantStateInitRef {
+				t.Errorf("StateInitRef = %v, want %v", parsed.StateInitRef, tt.wantStateInitRef)
+			}
+			if !sameCell(parsed.Body, tt.msg.Body) {
+				t.Error("body differs")
+			}
+			if !sameCell(parsed.StateInit, tt.msg.StateInit) {
+				t.Error("state init differs")
+			}
+			if parsed.Bounce != tt.msg.Bounce || parsed.Bounced != tt.msg.Bounced || parsed.IHREnabled != tt.msg.IHREnabled ||
+				parsed.CreatedLT != tt.msg.CreatedLT || parsed.CreatedAt != tt.msg.CreatedAt {
+				t.Errorf("flags or times differ: %+v", parsed)
+			}
+			if parsed.Value.NanoTON().Cmp(tt.msg.Value.NanoTON()) != 0 || parsed.FwdFee.NanoTON().Cmp(tt.msg.FwdFee.NanoTON()) != 0 {
+				t.Errorf("value %s, fwd fee %s", parsed.Value.String(), parsed.FwdFee.String())
+			}
+			if parsed.Dest.String() != dest.String() || (tt.msg.Source == nil) != (parsed.Source == nil) {
+				t.Errorf("addresses differ: %v -> %v", parsed.Source, parsed.Dest)
+			}
+
+			// ToCell of the result gives the same cell
+			again, err := parsed.ToCell()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again.Hash(), c.Hash()) {
+				t.Fatal("ToCell(ParseInternal(c)) differs from c")
+			}
+		})
+	}
+}
+
+func TestInternalErrors(t *testing.T) {
+	if _, err := (&Internal{Value: tlb.MustFromTON("1")}).ToCell(); err == nil {
+		t.Fatal("a message without a destination was serialized")
+	}
+	external := cell.BeginCell().MustStoreUInt(2, 2).EndCell() // ext_in_msg_info$10
+	if _, err := ParseInternal(external); !errors.Is(err, ErrNotInternal) {
+		t.Fatalf("error %v, want ErrNotInternal", err)
+	}
+}
+
+func sameCell(a, b *cell.Cell) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return bytes.Equal(a.Hash(), b.Hash())
+}
@@ -0,0 +1,132 @@
+// Package message builds and sends the messages used by the wallet examples.
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var (
+	ErrNotInternal = errors.New("message: not an internal message")
+	ErrNotExternal = errors.New("message: not an incoming external message")
+)
+
+// Out is an internal message together with the send mode the wallet should use for it.
+type Out struct {
+	Mode    SendMode
+	Message *cell.Cell
+}
+
+// External wraps a signed body into an incoming external message for dst.
+// stateInit is nil unless the message also deploys the wallet.
+func External(dst *address.Address, stateInit, body *cell.Cell) *cell.Cell {
+	b := cell.BeginCell().
+		MustStoreUInt(0b10, 2). // ext_in_msg_info$10
+		MustStoreUInt(0, 2).    // src -> addr_none
+		MustStoreAddr(dst).     // Destination address
+		MustStoreCoins(0)       // Import Fee
+
+	if stateInit != nil {
+		b.MustStoreBoolBit(true) // We have State Init
+		b.MustStoreBoolBit(true) // We store State Init as a reference
+		b.MustStoreRef(stateInit)
+	} else {
+		b.MustStoreBoolBit(false) // No State Init
+	}
+
+	return b.
+		MustStoreBoolBit(true). // We store Message Body as a reference
+		MustStoreRef(body).
+		EndCell()
+}
+
+// ExternalIn is a decoded incoming external message.
+type ExternalIn struct {
+	Dest      *address.Address
+	StateInit *cell.Cell // nil if the message does not deploy the wallet
+	Body      *cell.Cell
+}
+
+// ParseExternal decodes a message built by External, or by any other sender
+// which stores State Init and body inline or as references.
+func ParseExternal(c *cell.Cell) (*ExternalIn, error) {
+	s := c.BeginParse()
+
+	tag, err := s.LoadUInt(2)
+	if err != nil {
+		return nil, err
+	}
+	if tag != 0b10 {
+		return nil, ErrNotExternal
+	}
+	if _, err = s.LoadAddr(); err != nil { // src, addr_none or addr_extern
+		return nil, fmt.Errorf("message: source: %w", err)
+	}
+
+	m := &ExternalIn{}
+	if m.Dest, err = s.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("message: destination: %w", err)
+	}
+	if _, err = s.LoadBigCoins(); err != nil { // import_fee
+		return nil, err
+	}
+
+	hasStateInit, err := s.LoadBoolBit()
+	if err != nil {
+		return nil, err
+	}
+	if hasStateInit {
+		isRef, err := s.LoadBoolBit()
+		if err != nil {
+			return nil, err
+		}
+		if isRef {
+			m.StateInit, err = loadRefCell(s)
+		} else {
+			m.StateInit, err = loadStateInit(s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("message: state init: %w", err)
+		}
+	}
+
+	bodyIsRef, err := s.LoadBoolBit()
+	if err != nil {
+		return nil, err
+	}
+	if bodyIsRef {
+		m.Body, err = loadRefCell(s)
+	} else {
+		m.Body, err = s.ToCell()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("message: body: %w", err)
+	}
+	return m, nil
+}
+
+// Send sends an external message to the liteserver, like the examples do at the end.
+func Send(ctx context.Context, client ton.LiteClient, externalMessage *cell.Cell) error {
+	var resp tl.Serializable
+	err := client.QueryLiteserver(ctx, ton.SendMessage{Body: externalMessage.ToBOCWithFlags(false)}, &resp)
+	if err != nil {
+		return err
+	}
+
+	switch t := resp.(type) {
+	case ton.SendMessageStatus:
+		if t.Status != 1 {
+			return fmt.Errorf("message was not accepted, status: %d", t.Status)
+		}
+		return nil
+	case ton.LSError:
+		return t
+	}
+	return fmt.Errorf("unexpected response from liteserver: %T", resp)
+}
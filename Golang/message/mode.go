@@ -0,0 +1,101 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SendMode is the mode byte a wallet passes to SENDRAWMSG with every internal
+// message: one base mode plus any of the flags.
+type SendMode uint8
+
+// Base modes.
+const (
+	ModeOrdinary     SendMode = 0   // send the value given in the message
+	ModeCarryInbound SendMode = 64  // also add the value left from the inbound message
+	ModeCarryAll     SendMode = 128 // send the whole balance of the contract instead of the value
+)
+
+// Flags.
+const (
+	FlagPayFeesSeparately  SendMode = 1  // pay the fees from the balance, not from the value
+	FlagIgnoreErrors       SendMode = 2  // skip the message if it cannot be sent
+	FlagBounceOnActionFail SendMode = 16 // bounce the transaction if the action fails
+	FlagDestroyIfZero      SendMode = 32 // destroy the contract if its balance becomes zero
+)
+
+// ModeDefault is mode 3, which the chapters and the wallet apps use.
+const ModeDefault = ModeOrdinary | FlagPayFeesSeparately | FlagIgnoreErrors
+
+var (
+	ErrModeBits     = errors.New("message: send mode has unknown bits 4 or 8")
+	ErrModeConflict = errors.New("message: send mode 64 and 128 cannot be combined")
+)
+
+// Validate returns an error for a mode the network does not accept.
+func (m SendMode) Validate() error {
+	if m&(4|8) != 0 {
+		return fmt.Errorf("%w: %d", ErrModeBits, m)
+	}
+	if m&ModeCarryInbound != 0 && m&ModeCarryAll != 0 {
+		return fmt.Errorf("%w: %d", ErrModeConflict, m)
+	}
+	return nil
+}
+
+// String returns the mode with its flags spelled out, for example "3 (pay fees separately, ignore errors)".
+func (m SendMode) String() string {
+	var flags []string
+	switch {
+	case m&ModeCarryAll != 0:
+		flags = append(flags, "carry all balance")
+	case m&ModeCarryInbound != 0:
+		flags = append(flags, "carry remaining inbound value")
+	}
+	if m&FlagPayFeesSeparately != 0 {
+		flags = append(flags, "pay fees separately")
+	}
+	if m&FlagIgnoreErrors != 0 {
+		flags = append(flags, "ignore errors")
+	}
+	if m&FlagBounceOnActionFail != 0 {
+		flags = append(flags, "bounce on action fail")
+	}
+	if m&FlagDestroyIfZero != 0 {
+		flags = append(flags, "destroy if zero")
+	}
+	if len(flags) == 0 {
+		return fmt.Sprint(uint8(m))
+	}
+	return fmt.Sprintf("%d (%s)", uint8(m), strings.Join(flags, ", "))
+}
+
+// CheckBatch validates the modes of the messages a wallet sends at once, in the
+// order the wallet sends them. The modes are valid, but the batch probably does
+// not do what was meant, if warnings are returned.
+func CheckBatch(modes ...SendMode) (warnings []string, err error) {
+	carryAll := 0
+	for i, m := range modes {
+		if err = m.Validate(); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+
+		if m&ModeCarryAll != 0 {
+			carryAll++
+			if i != len(modes)-1 {
+				warnings = append(warnings, fmt.Sprintf("message %d carries all the balance, the messages after it have nothing left to send", i+1))
+			}
+		}
+		if m&ModeCarryInbound != 0 {
+			warnings = append(warnings, fmt.Sprintf("message %d carries the inbound value, but an external message to a wallet brings none", i+1))
+		}
+		if m&FlagDestroyIfZero != 0 {
+			warnings = append(warnings, fmt.Sprintf("message %d destroys the wallet if its balance becomes zero", i+1))
+		}
+	}
+	if carryAll > 1 {
+		warnings = append(warnings, fmt.Sprintf("%d messages carry all the balance, only the first one gets it", carryAll))
+	}
+	return warnings, nil
+}
@@ -0,0 +1,90 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSendModeValidate(t *testing.T) {
+	tests := []struct {
+		mode SendMode
+		err  error
+	}{
+		{ModeDefault, nil},
+		{ModeOrdinary, nil},
+		{ModeCarryInbound | FlagIgnoreErrors, nil},
+		{ModeCarryAll | FlagDestroyIfZero, nil},
+		{FlagBounceOnActionFail | FlagPayFeesSeparately, nil},
+		{4, ErrModeBits},
+		{8, ErrModeBits},
+		{ModeDefault | 8, ErrModeBits},
+		{ModeCarryInbound | ModeCarryAll, ErrModeConflict},
+		{ModeCarryInbound | ModeCarryAll | FlagIgnoreErrors, ErrModeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			if err := tt.mode.Validate(); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		modes    []SendMode
+		err      error
+		warnings []string // a part of every warning, in order
+	}{
+		{"default", []SendMode{ModeDefault, ModeDefault}, nil, nil},
+		{"empty", nil, nil, nil},
+		{"carry all last", []SendMode{ModeDefault, ModeCarryAll}, nil, nil},
+		{"carry all not last", []SendMode{ModeCarryAll, ModeDefault}, nil, []string{"message 1 carries all the balance"}},
+		{"two carry all", []SendMode{ModeCarryAll, ModeCarryAll}, nil, []string{"message 1 carries all", "2 messages carry all"}},
+		{"carry inbound", []SendMode{ModeCarryInbound}, nil, []string{"inbound value"}},
+		{"destroy", []SendMode{ModeCarryAll | FlagDestroyIfZero}, nil, []string{"destroys the wallet"}},
+		{"invalid bits", []SendMode{ModeDefault, 4}, ErrModeBits, nil},
+		{"64 and 128", []SendMode{ModeCarryInbound | ModeCarryAll}, ErrModeConflict, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := CheckBatch(tt.modes...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("warnings %q, want %q", warnings, tt.warnings)
+			}
+			for i, w := range warnings {
+				if !strings.Contains(w, tt.warnings[i]) {
+					t.Errorf("warning %q, want one with %q", w, tt.warnings[i])
+				}
+			}
+		})
+	}
+
+	t.Run("error names the message", func(t *testing.T) {
+		if _, err := CheckBatch(ModeDefault, ModeDefault, 8); err == nil || !strings.HasPrefix(err.Error(), "message 3:") {
+			t.Fatalf("error %v, want one about message 3", err)
+		}
+	})
+}
+
+func TestSendModeString(t *testing.T) {
+	tests := []struct {
+		mode SendMode
+		want string
+	}{
+		{ModeOrdinary, "0"},
+		{ModeDefault, "3 (pay fees separately, ignore errors)"},
+		{ModeCarryAll | FlagDestroyIfZero, "160 (carry all balance, destroy if zero)"},
+		{ModeCarryInbound | FlagBounceOnActionFail, "80 (carry remaining inbound value, bounce on action fail)"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
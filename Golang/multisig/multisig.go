@@ -0,0 +1,305 @@
+// Package multisig deploys and operates the multisig wallet v2
+// (ton-blockchain/multisig-contract-v2).
+//
+// The multisig is not a wallet with a key: its signers and proposers are
+// wallet addresses. A signer or a proposer creates an order by sending
+// NewOrder to the multisig, which deploys an order contract with the actions
+// of the order; the signers approve the order by sending ApproveBody to it
+// from their wallets, and once Threshold signers have approved, the order
+// makes the multisig run the actions. So every step is an ordinary transfer
+// from a wallet of this repo (see package detect), signed by its own Signer.
+//
+// The code of the multisig is not in this repository, the functions which
+// need it take it as a cell, like nft.Collection does with the collection code.
+package multisig
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+)
+
+// Ops of the multisig and of its orders (contracts/op-codes.func).
+const (
+	OpNewOrder        = 0xf718510f // to the multisig, from a signer or a proposer
+	OpExecute         = 0x75097f5d // from an approved order to the multisig
+	OpExecuteInternal = 0xa32c59bf // from the multisig to itself, for long orders
+	OpInit            = 0x9c73fba2 // from the multisig to a new order
+	OpApprove         = 0xa762230f // to an order, from a signer
+	OpApproveAccepted = 0x82609bf6 // the order answers the signer
+	OpApproveRejected = 0xafaf283e
+)
+
+// Actions an order may carry.
+const (
+	ActionSendMessage  = 0xf1381e5b
+	ActionUpdateParams = 0x1d0cfbd3
+)
+
+// MaxSigners is the most signers and proposers a multisig has, their indexes are uint8.
+const MaxSigners = 255
+
+// MaxActions is the most actions an order has, their keys are uint8.
+const MaxActions = 255
+
+// MaxOrderSeqno as the seqno of NewOrder takes the next seqno of the
+// multisig, whatever it is when the message comes.
+var MaxOrderSeqno = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+var (
+	ErrNoSigners    = errors.New("multisig: no signers")
+	ErrTooMany      = errors.New("multisig: more than 255 signers or proposers")
+	ErrThreshold    = errors.New("multisig: threshold must be from 1 to the number of signers")
+	ErrNoActions    = errors.New("multisig: an order needs from 1 to 255 actions")
+	ErrUnknownIndex = errors.New("multisig: the wallet is neither a signer nor a proposer")
+)
+
+// Params are the signers of a multisig, the same in Data and in ActionUpdateParams.
+type Params struct {
+	Threshold uint8              // approvals an order needs
+	Signers   []*address.Address // wallets which create and approve orders, the index is the position
+	Proposers []*address.Address // wallets which only create orders
+}
+
+// Validate checks the limits the multisig checks.
+func (p *Params) Validate() error {
+	if len(p.Signers) == 0 {
+		return ErrNoSigners
+	}
+	if len(p.Signers) > MaxSigners || len(p.Proposers) > MaxSigners {
+		return ErrTooMany
+	}
+	if p.Threshold == 0 || int(p.Threshold) > len(p.Signers) {
+		return ErrThreshold
+	}
+	return nil
+}
+
+// Index returns the index of wallet among the signers or, with isSigner
+// false, among the proposers. A signer who creates an order approves it at once.
+func (p *Params) Index(wallet *address.Address) (index uint8, isSigner bool, err error) {
+	for i, signer := range p.Signers {
+		if sameAddress(signer, wallet) {
+			return uint8(i), true, nil
+		}
+	}
+	for i, proposer := range p.Proposers {
+		if sameAddress(proposer, wallet) {
+			return uint8(i), false, nil
+		}
+	}
+	return 0, false, fmt.Errorf("%w: %s", ErrUnknownIndex, wallet.String())
+}
+
+// Data returns the initial data cell of a multisig:
+// next_order_seqno:uint256 threshold:uint8 signers:^(Hashmap 8 MsgAddressInt)
+// signers_num:uint8 proposers:(HashmapE 8 MsgAddressInt) allow_arbitrary_seqno:Bool
+//
+// With allowArbitrarySeqno the proposers choose the seqno of their orders,
+// else the orders take the seqnos one after another.
+func Data(p *Params, allowArbitrarySeqno bool) (*cell.Cell, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	signers, proposers, err := p.dicts()
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreBigUInt(big.NewInt(0), 256). // next_order_seqno
+		MustStoreUInt(uint64(p.Threshold), 8).
+		MustStoreRef(signers.MustToCell()). // never empty
+		MustStoreUInt(uint64(len(p.Signers)), 8).
+		MustStoreDict(proposers).
+		MustStoreBoolBit(allowArbitrarySeqno).
+		EndCell(), nil
+}
+
+// ParseData reads the data cell of a multisig, the layout of Data, so a
+// multisig found by its code (see package detect) needs no get method.
+func ParseData(data *cell.Cell) (s *State, allowArbitrarySeqno bool, err error) {
+	ds := data.BeginParse()
+	s = &State{}
+	if s.NextOrderSeqno, err = ds.LoadBigUInt(256); err != nil {
+		return nil, false, fmt.Errorf("multisig: next_order_seqno: %w", err)
+	}
+	threshold, err := ds.LoadUInt(8)
+	if err != nil {
+		return nil, false, fmt.Errorf("multisig: threshold: %w", err)
+	}
+	s.Threshold = uint8(threshold)
+	signers, err := ds.LoadRef()
+	if err != nil {
+		return nil, false, fmt.Errorf("multisig: signers: %w", err)
+	}
+	signersCell, err := signers.ToCell()
+	if err != nil {
+		return nil, false, fmt.Errorf("multisig: signers: %w", err)
+	}
+	if s.Signers, err = parseAddresses(signersCell); err != nil {
+		return nil, false, fmt.Errorf("multisig: signers: %w", err)
+	}
+	signersNum, err := ds.LoadUInt(8)
+	if err != nil {
+		return nil, false, fmt.Errorf("multisig: signers_num: %w", err)
+	}
+	if int(signersNum) != len(s.Signers) {
+		return nil, false, fmt.Errorf("multisig: signers_num is %d, the dictionary has %d", signersNum, len(s.Signers))
+	}
+	proposers, err := ds.LoadMaybeRef()
+	if err != nil {
+		return nil, false, fmt.Errorf("multisig: proposers: %w", err)
+	}
+	if proposers != nil { // HashmapE: no reference when there are no proposers
+		proposersCell, err := proposers.ToCell()
+		if err == nil {
+			s.Proposers, err = parseAddresses(proposersCell)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("multisig: proposers: %w", err)
+		}
+	}
+	if allowArbitrarySeqno, err = ds.LoadBoolBit(); err != nil {
+		return nil, false, fmt.Errorf("multisig: allow_arbitrary_seqno: %w", err)
+	}
+	return s, allowArbitrarySeqno, nil
+}
+
+// StateInit returns the state init which deploys the multisig with code.
+func StateInit(code *cell.Cell, p *Params, allowArbitrarySeqno bool) (*cell.Cell, error) {
+	data, err := Data(p, allowArbitrarySeqno)
+	if err != nil {
+		return nil, err
+	}
+	return contract.StateInit(code, data), nil
+}
+
+// Address returns the address of the multisig in workchain 0.
+func Address(code *cell.Cell, p *Params, allowArbitrarySeqno bool) (*address.Address, error) {
+	stateInit, err := StateInit(code, p, allowArbitrarySeqno)
+	if err != nil {
+		return nil, err
+	}
+	return address.NewAddress(0, 0, stateInit.Hash()), nil
+}
+
+// DeployBody is the body of the message which deploys the multisig: op 0 and query_id 0.
+func DeployBody() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(0, 32).MustStoreUInt(0, 64).EndCell()
+}
+
+// SendMessage returns the action which makes the multisig send an internal
+// message with mode, for example a message.Internal from the multisig.
+func SendMessage(mode message.SendMode, internalMessage *cell.Cell) (*cell.Cell, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreUInt(ActionSendMessage, 32).
+		MustStoreUInt(uint64(mode), 8).
+		MustStoreRef(internalMessage).
+		EndCell(), nil
+}
+
+// UpdateParams returns the action which replaces the threshold, the signers
+// and the proposers of the multisig. The orders created before it can no
+// longer be executed.
+func UpdateParams(p *Params) (*cell.Cell, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	signers, proposers, err := p.dicts()
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreUInt(ActionUpdateParams, 32).
+		MustStoreUInt(uint64(p.Threshold), 8).
+		MustStoreRef(signers.MustToCell()).
+		MustStoreDict(proposers).
+		EndCell(), nil
+}
+
+// PackOrder returns the order with actions, a Hashmap 8 ^Cell run in the order of the keys.
+func PackOrder(actions []*cell.Cell) (*cell.Cell, error) {
+	if len(actions) == 0 || len(actions) > MaxActions {
+		return nil, ErrNoActions
+	}
+	order := cell.NewDict(8)
+	for i, action := range actions {
+		value := cell.BeginCell().MustStoreRef(action).EndCell()
+		if err := order.SetIntKey(big.NewInt(int64(i)), value); err != nil {
+			return nil, err
+		}
+	}
+	return order.ToCell()
+}
+
+// NewOrder is the body a signer or a proposer sends to the multisig to create an order.
+type NewOrder struct {
+	QueryID    uint64
+	OrderSeqno *big.Int // State.NextOrderSeqno, or MaxOrderSeqno for the next one
+	IsSigner   bool     // Index is among the signers, else among the proposers
+	Index      uint8
+	ExpireAt   time.Time // after it the order cannot be approved or executed
+	Order      *cell.Cell
+}
+
+// Body returns the message body:
+// op:uint32 query_id:uint64 order_seqno:uint256 signer:Bool index:uint8 expiration_date:uint48 order:^Order
+func (n *NewOrder) Body() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(OpNewOrder, 32).
+		MustStoreUInt(n.QueryID, 64).
+		MustStoreBigUInt(n.OrderSeqno, 256).
+		MustStoreBoolBit(n.IsSigner).
+		MustStoreUInt(uint64(n.Index), 8).
+		MustStoreUInt(uint64(n.ExpireAt.Unix()), 48).
+		MustStoreRef(n.Order).
+		EndCell()
+}
+
+// ApproveBody returns the body a signer sends to an order to approve it. The
+// order checks that the message comes from the wallet with index among its signers.
+func ApproveBody(index uint8, queryID uint64) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(OpApprove, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreUInt(uint64(index), 8).
+		EndCell()
+}
+
+func (p *Params) dicts() (signers, proposers *cell.Dictionary, err error) {
+	signers, err = addressDict(p.Signers)
+	if err != nil {
+		return nil, nil, err
+	}
+	proposers, err = addressDict(p.Proposers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signers, proposers, nil
+}
+
+// addressDict returns a Hashmap 8 MsgAddressInt with the addresses by their index.
+func addressDict(addresses []*address.Address) (*cell.Dictionary, error) {
+	dict := cell.NewDict(8)
+	for i, addr := range addresses {
+		if err := dict.SetIntKey(big.NewInt(int64(i)), cell.BeginCell().MustStoreAddr(addr).EndCell()); err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+// sameAddress compares workchain and account ID, the same wallet may come with other flags.
+func sameAddress(a, b *address.Address) bool {
+	return a.Workchain() == b.Workchain() && string(a.Data()) == string(b.Data())
+}
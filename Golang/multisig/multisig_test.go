@@ -0,0 +1,189 @@
+package multisig
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+)
+
+func wallets(n int, from byte) []*address.Address {
+	addresses := make([]*address.Address, n)
+	for i := range addresses {
+		addresses[i] = address.NewAddress(0, 0, append(make([]byte, 30), from, byte(i)))
+	}
+	return addresses
+}
+
+func TestData(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Params
+		arb  bool
+	}{
+		{"2-of-3", Params{Threshold: 2, Signers: wallets(3, 1)}, false},
+		{"with proposers", Params{Threshold: 1, Signers: wallets(1, 1), Proposers: wallets(2, 2)}, true},
+		{"255 signers", Params{Threshold: 255, Signers: wallets(255, 1), Proposers: wallets(255, 2)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Data(&tt.p, tt.arb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, arb, err := ParseData(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.NextOrderSeqno.Sign() != 0 || s.Threshold != tt.p.Threshold || arb != tt.arb {
+				t.Errorf("seqno %s, threshold %d, arbitrary seqno %v", s.NextOrderSeqno, s.Threshold, arb)
+			}
+			checkAddresses(t, "signer", s.Signers, tt.p.Signers)
+			checkAddresses(t, "proposer", s.Proposers, tt.p.Proposers)
+		})
+	}
+
+	if _, _, err := ParseData(cell.BeginCell().MustStoreUInt(0, 200).EndCell()); err == nil {
+		t.Error("short data was parsed")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Params
+		err  error
+	}{
+		{"1-of-1", Params{Threshold: 1, Signers: wallets(1, 1)}, nil},
+		{"255-of-255", Params{Threshold: 255, Signers: wallets(255, 1), Proposers: wallets(255, 2)}, nil},
+		{"no signers", Params{Threshold: 1}, ErrNoSigners},
+		{"256 signers", Params{Threshold: 1, Signers: wallets(256, 1)}, ErrTooMany},
+		{"256 proposers", Params{Threshold: 1, Signers: wallets(1, 1), Proposers: wallets(256, 2)}, ErrTooMany},
+		{"threshold 0", Params{Signers: wallets(3, 1)}, ErrThreshold},
+		{"threshold above the signers", Params{Threshold: 4, Signers: wallets(3, 1)}, ErrThreshold},
+	}
+	for _, tt := range tests {
+		if err := tt.p.Validate(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		if _, err := Data(&tt.p, false); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Data error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func transfer(t *testing.T, mode message.SendMode, nano int64) *cell.Cell {
+	internalMessage := (&message.Internal{Dest: wallets(1, 9)[0], Value: tlb.FromNanoTON(big.NewInt(nano))}).MustToCell()
+	action, err := SendMessage(mode, internalMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return action
+}
+
+func TestPackOrder(t *testing.T) {
+	// More actions than fit in one level of the dictionary, so the keys are spread over its forks
+	var actions []*cell.Cell
+	for i := 0; i < 20; i++ {
+		actions = append(actions, transfer(t, message.ModeDefault, int64(i+1)))
+	}
+	update, err := UpdateParams(&Params{Threshold: 2, Signers: wallets(3, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions = append(actions, update)
+
+	order, err := PackOrder(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseActions(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(actions) {
+		t.Fatalf("%d actions, want %d", len(parsed), len(actions))
+	}
+	for i, a := range parsed[:20] {
+		if a.Op != ActionSendMessage || a.Message.Value.NanoTON().Int64() != int64(i+1) {
+			t.Fatalf("action %d is %x with %s, the actions are out of order", i, a.Op, a.Message.Value)
+		}
+	}
+	if last := parsed[20]; last.Op != ActionUpdateParams || last.Params.Threshold != 2 || len(last.Params.Signers) != 3 {
+		t.Errorf("last action %+v", last)
+	}
+
+	for _, n := range []int{0, MaxActions + 1} {
+		if _, err := PackOrder(make([]*cell.Cell, n)); !errors.Is(err, ErrNoActions) {
+			t.Errorf("%d actions: error %v, want ErrNoActions", n, err)
+		}
+	}
+}
+
+func TestTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		modes     []message.SendMode
+		total     int64
+		unbounded bool
+	}{
+		{"default", []message.SendMode{message.ModeDefault, message.ModeDefault}, 3, false},
+		{"carry inbound", []message.SendMode{message.ModeDefault, message.ModeCarryInbound}, 3, true},
+		{"carry all", []message.SendMode{message.ModeCarryAll}, 1, true},
+	}
+	for _, tt := range tests {
+		var actions []*cell.Cell
+		for i, mode := range tt.modes {
+			actions = append(actions, transfer(t, mode, int64(i+1)))
+		}
+		order, err := PackOrder(actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseActions(order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total, unbounded := Total(parsed)
+		if total.NanoTON().Int64() != tt.total || unbounded != tt.unbounded {
+			t.Errorf("%s: Total = %s, %v, want %d nanoTON, %v", tt.name, total.NanoTON(), unbounded, tt.total, tt.unbounded)
+		}
+	}
+}
+
+func TestCheckTreasury(t *testing.T) {
+	tests := []struct {
+		threshold uint8
+		signers   int
+		err       error
+	}{
+		{2, 3, nil},
+		{3, 5, nil},
+		{1, 3, ErrWeakTreasury},
+		{2, 2, ErrWeakTreasury},
+		{1, 1, ErrWeakTreasury},
+	}
+	for _, tt := range tests {
+		s := &State{Params: Params{Threshold: tt.threshold, Signers: wallets(tt.signers, 1)}}
+		if err := s.CheckTreasury(); !errors.Is(err, tt.err) {
+			t.Errorf("%d-of-%d: error %v, want %v", tt.threshold, tt.signers, err, tt.err)
+		}
+	}
+}
+
+func checkAddresses(t *testing.T, role string, got, want []*address.Address) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d %ss, want %d", len(got), role, len(want))
+	}
+	for i := range got {
+		if got[i].String() != want[i].String() {
+			t.Errorf("%s %d is %s, want %s", role, i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package multisig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/walletv3"
+)
+
+var (
+	ErrNotInited    = errors.New("multisig: the order is not deployed yet")
+	ErrWeakTreasury = errors.New("multisig: the amount is above the limit and the multisig is not 2-of-3")
+)
+
+// A transfer above the limit of the config needs at least TreasuryThreshold
+// approvals among at least TreasurySigners signers (see CheckTreasury).
+const (
+	TreasuryThreshold = 2
+	TreasurySigners   = 3
+)
+
+// State is what get_multisig_data returns.
+type State struct {
+	NextOrderSeqno *big.Int
+	Params
+}
+
+// GetState runs the "get_multisig_data" get method of a multisig.
+func GetState(ctx context.Context, api walletv3.TonAPI, multisigAddress *address.Address) (*State, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.RunGetMethod(ctx, block, multisigAddress, "get_multisig_data")
+	if err != nil {
+		return nil, fmt.Errorf("multisig: run get_multisig_data: %w", err)
+	}
+
+	nextOrderSeqno, err := result.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: next_order_seqno: %w", err)
+	}
+	threshold, err := result.Int(1)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: threshold: %w", err)
+	}
+	signersCell, err := result.Cell(2)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: signers: %w", err)
+	}
+	d := &State{NextOrderSeqno: nextOrderSeqno}
+	d.Threshold = uint8(threshold.Uint64())
+	if d.Signers, err = parseAddresses(signersCell); err != nil {
+		return nil, fmt.Errorf("multisig: signers: %w", err)
+	}
+	if isNil, _ := result.IsNil(3); !isNil { // null when there are no proposers
+		proposersCell, err := result.Cell(3)
+		if err != nil {
+			return nil, fmt.Errorf("multisig: proposers: %w", err)
+		}
+		if d.Proposers, err = parseAddresses(proposersCell); err != nil {
+			return nil, fmt.Errorf("multisig: proposers: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// CheckTreasury returns ErrWeakTreasury unless the multisig needs at least
+// TreasuryThreshold of at least TreasurySigners approvals. The commands call
+// it for every transfer above fees.max_amount, which a single key may not send.
+func (d *State) CheckTreasury() error {
+	if d.Threshold < TreasuryThreshold || len(d.Signers) < TreasurySigners {
+		return fmt.Errorf("%w: %d-of-%d", ErrWeakTreasury, d.Threshold, len(d.Signers))
+	}
+	return nil
+}
+
+// GetOrderAddress runs the "get_order_address" get method of a multisig: the
+// address of the order with seqno, deployed or not.
+func GetOrderAddress(ctx context.Context, api walletv3.TonAPI, multisigAddress *address.Address, orderSeqno *big.Int) (*address.Address, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.RunGetMethod(ctx, block, multisigAddress, "get_order_address", orderSeqno)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: run get_order_address: %w", err)
+	}
+	s, err := result.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: order address: %w", err)
+	}
+	return s.LoadAddr()
+}
+
+// Order is what get_order_data returns for a deployed order.
+type Order struct {
+	Multisig      *address.Address
+	Seqno         *big.Int
+	Threshold     uint8
+	Executed      bool // sent to the multisig for execution, the actions may still fail there
+	Signers       []*address.Address
+	ApprovalsMask *big.Int // bit i is set when signer i approved
+	Approvals     uint8
+	ExpireAt      time.Time
+	Actions       *cell.Cell // the order, see ParseActions
+}
+
+// Approved returns whether the signer with index approved the order.
+func (o *Order) Approved(index uint8) bool {
+	return o.ApprovalsMask.Bit(int(index)) == 1
+}
+
+// Expired returns whether the order can no longer be approved or executed.
+func (o *Order) Expired() bool {
+	return !o.Executed && time.Now().After(o.ExpireAt)
+}
+
+// GetOrder runs the "get_order_data" get method of an order. It returns
+// ErrNotInited while the order contract exists but the multisig has not
+// initialized it yet.
+func GetOrder(ctx context.Context, api walletv3.TonAPI, orderAddress *address.Address) (*Order, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.RunGetMethod(ctx, block, orderAddress, "get_order_data")
+	if err != nil {
+		return nil, fmt.Errorf("multisig: run get_order_data: %w", err)
+	}
+	if isNil, _ := result.IsNil(2); isNil { // only the multisig and the seqno are set before init
+		return nil, ErrNotInited
+	}
+
+	s, err := result.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: multisig address: %w", err)
+	}
+	o := &Order{}
+	if o.Multisig, err = s.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("multisig: multisig address: %w", err)
+	}
+	// seqno, threshold, sent_for_execution?, approvals_mask, approvals_num and expiration_date
+	numbers := map[uint]*big.Int{}
+	for _, i := range []uint{1, 2, 3, 5, 6, 7} {
+		if numbers[i], err = result.Int(i); err != nil {
+			return nil, fmt.Errorf("multisig: read get_order_data: %w", err)
+		}
+	}
+	signersCell, err := result.Cell(4)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: signers: %w", err)
+	}
+	if o.Signers, err = parseAddresses(signersCell); err != nil {
+		return nil, fmt.Errorf("multisig: signers: %w", err)
+	}
+	if o.Actions, err = result.Cell(8); err != nil {
+		return nil, fmt.Errorf("multisig: order: %w", err)
+	}
+
+	o.Seqno = numbers[1]
+	o.Threshold = uint8(numbers[2].Uint64())
+	o.Executed = numbers[3].Sign() != 0 // -1 is true
+	o.ApprovalsMask = numbers[5]
+	o.Approvals = uint8(numbers[6].Uint64())
+	o.ExpireAt = time.Unix(numbers[7].Int64(), 0)
+	return o, nil
+}
+
+// Action is a decoded action of an order.
+type Action struct {
+	Op      uint32
+	Mode    message.SendMode  // ActionSendMessage
+	Message *message.Internal // ActionSendMessage
+	Params  *Params           // ActionUpdateParams, without the proposers
+	Raw     *cell.Cell
+}
+
+// ParseActions decodes the actions of an order in the order they run.
+func ParseActions(order *cell.Cell) ([]Action, error) {
+	dict, err := order.BeginParse().ToDict(8)
+	if err != nil {
+		return nil, fmt.Errorf("multisig: order: %w", err)
+	}
+	// The multisig runs the actions by their keys, 0, 1, 2 and so on
+	var actions []Action
+	for i := int64(0); i < MaxActions; i++ {
+		value := dict.GetByIntKey(big.NewInt(i))
+		if value == nil {
+			break
+		}
+		ref, err := value.BeginParse().LoadRef()
+		if err != nil {
+			return nil, fmt.Errorf("multisig: action %d: %w", i, err)
+		}
+		raw, err := ref.ToCell()
+		if err != nil {
+			return nil, fmt.Errorf("multisig: action %d: %w", i, err)
+		}
+		action, err := parseAction(raw)
+		if err != nil {
+			return nil, fmt.Errorf("multisig: action %d: %w", i, err)
+		}
+		actions = append(actions, *action)
+	}
+	if len(actions) != len(dict.All()) {
+		return nil, errors.New("multisig: order: the keys are not 0, 1, 2 and so on")
+	}
+	return actions, nil
+}
+
+func parseAction(raw *cell.Cell) (*Action, error) {
+	s := raw.BeginParse()
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, err
+	}
+	a := &Action{Op: uint32(op), Raw: raw}
+	switch op {
+	case ActionSendMessage:
+		mode, err := s.LoadUInt(8)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := s.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+		internalCell, err := ref.ToCell()
+		if err != nil {
+			return nil, err
+		}
+		a.Mode = message.SendMode(mode)
+		if a.Message, err = message.ParseInternal(internalCell); err != nil {
+			return nil, err
+		}
+	case ActionUpdateParams:
+		threshold, err := s.LoadUInt(8)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := s.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+		signersCell, err := ref.ToCell()
+		if err != nil {
+			return nil, err
+		}
+		a.Params = &Params{Threshold: uint8(threshold)}
+		if a.Params.Signers, err = parseAddresses(signersCell); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Total returns the TON the send_message actions carry. Messages with mode
+// 64 or 128 send more than their value, Total cannot know how much.
+func Total(actions []Action) (total tlb.Coins, unbounded bool) {
+	sum := new(big.Int)
+	for _, a := range actions {
+		if a.Message == nil {
+			continue
+		}
+		sum.Add(sum, a.Message.Value.NanoTON())
+		if a.Mode&(message.ModeCarryInbound|message.ModeCarryAll) != 0 {
+			unbounded = true
+		}
+	}
+	return tlb.FromNanoTON(sum), unbounded
+}
+
+// parseAddresses reads a Hashmap 8 MsgAddressInt into a slice by index.
+func parseAddresses(root *cell.Cell) ([]*address.Address, error) {
+	dict, err := root.BeginParse().ToDict(8)
+	if err != nil {
+		return nil, err
+	}
+	kvs := dict.All()
+	addresses := make([]*address.Address, len(kvs))
+	for _, kv := range kvs {
+		index, err := kv.Key.BeginParse().LoadUInt(8)
+		if err != nil {
+			return nil, err
+		}
+		if index >= uint64(len(addresses)) {
+			return nil, fmt.Errorf("index %d of %d addresses", index, len(addresses))
+		}
+		if addresses[index], err = kv.Value.BeginParse().LoadAddr(); err != nil {
+			return nil, err
+		}
+	}
+	return addresses, nil
+}
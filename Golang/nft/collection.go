@@ -0,0 +1,261 @@
+package nft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/walletv3"
+)
+
+// Opcodes of the standard collection contract (nft-collection.fc), sent by its owner.
+const (
+	OpMint        = 1 // deploy one item
+	OpBatchMint   = 2 // deploy many items
+	OpChangeOwner = 3
+)
+
+// MaxBatchMint is how many items one batch mint deploys: the collection
+// refuses the 250th, its actions would not fit into one transaction.
+const MaxBatchMint = 249
+
+var (
+	ErrNoCollectionOwner = errors.New("nft: collection has no owner")
+	ErrBatchSize         = errors.New("nft: a batch mint deploys 1 to 249 items")
+	ErrBatchOrder        = errors.New("nft: batch mint indexes must grow one by one")
+)
+
+// Royalty is the share of every sale on marketplaces which goes to Address:
+// Factor/Base, for example 5/100 for 5%.
+type Royalty struct {
+	Factor  uint16
+	Base    uint16
+	Address *address.Address
+}
+
+// Collection is the initial data of the standard NFT collection contract.
+type Collection struct {
+	Owner         *address.Address // mints items, gets nothing else
+	NextItemIndex uint64           // 0 for a new collection
+
+	// Content is the metadata of the collection itself, usually OffChain("https://.../collection.json").
+	Content *cell.Cell
+	// CommonContent is what the collection puts before the content of every
+	// item, so an item keeps only "12.json" of "https://.../items/12.json".
+	CommonContent string
+
+	ItemCode *cell.Cell // the code of the items the collection deploys
+	Royalty  Royalty
+}
+
+// Data returns the data cell of the collection:
+//
+//	owner_address next_item_index:uint64 ^[^collection_content ^common_content] nft_item_code:^Cell
+//	royalty_params:^[factor:uint16 base:uint16 royalty_address]
+func (c *Collection) Data() (*cell.Cell, error) {
+	if c.Owner == nil {
+		return nil, ErrNoCollectionOwner
+	}
+	if c.Content == nil || c.ItemCode == nil {
+		return nil, errors.New("nft: collection needs content and item code")
+	}
+	if c.Royalty.Base == 0 || c.Royalty.Factor > c.Royalty.Base {
+		return nil, fmt.Errorf("nft: royalty %d/%d is not a share", c.Royalty.Factor, c.Royalty.Base)
+	}
+
+	royaltyAddress := c.Royalty.Address
+	if royaltyAddress == nil {
+		royaltyAddress = c.Owner
+	}
+
+	commonContent := cell.BeginCell()
+	if err := commonContent.StoreBinarySnake([]byte(c.CommonContent)); err != nil { // no layout byte, it is only a prefix
+		return nil, fmt.Errorf("nft: common content: %w", err)
+	}
+
+	content := cell.BeginCell().
+		MustStoreRef(c.Content).
+		MustStoreRef(commonContent.EndCell()).
+		EndCell()
+
+	royalty := cell.BeginCell().
+		MustStoreUInt(uint64(c.Royalty.Factor), 16).
+		MustStoreUInt(uint64(c.Royalty.Base), 16).
+		MustStoreAddr(royaltyAddress).
+		EndCell()
+
+	return cell.BeginCell().
+		MustStoreAddr(c.Owner).
+		MustStoreUInt(c.NextItemIndex, 64).
+		MustStoreRef(content).
+		MustStoreRef(c.ItemCode).
+		MustStoreRef(royalty).
+		EndCell(), nil
+}
+
+// StateInit returns the state init which deploys the collection with code,
+// the compiled nft-collection.fc. It is sent like in Chapter 4 "Contract deploy via wallet".
+func (c *Collection) StateInit(code *cell.Cell) (*cell.Cell, error) {
+	data, err := c.Data()
+	if err != nil {
+		return nil, err
+	}
+	return contract.StateInit(code, data), nil
+}
+
+// Address returns the address of the collection in workchain 0.
+func (c *Collection) Address(code *cell.Cell) (*address.Address, error) {
+	stateInit, err := c.StateInit(code)
+	if err != nil {
+		return nil, err
+	}
+	return address.NewAddress(0, 0, stateInit.Hash()), nil
+}
+
+// Mint is one item to deploy.
+type Mint struct {
+	Index   uint64
+	Owner   *address.Address
+	Content string    // the item part of the content, after CommonContent, like "12.json"
+	Amount  tlb.Coins // TON the collection passes to the item for its storage, 0.05 is enough
+}
+
+// itemContent is what the standard item gets from the collection: owner_address ^individual_content.
+func (m *Mint) itemContent() (*cell.Cell, error) {
+	if m.Owner == nil {
+		return nil, fmt.Errorf("nft: item %d has no owner", m.Index)
+	}
+	content := cell.BeginCell()
+	if err := content.StoreBinarySnake([]byte(m.Content)); err != nil {
+		return nil, fmt.Errorf("nft: item %d content: %w", m.Index, err)
+	}
+	return cell.BeginCell().
+		MustStoreAddr(m.Owner).
+		MustStoreRef(content.EndCell()).
+		EndCell(), nil
+}
+
+// MintBody returns the body the collection owner sends to deploy one item.
+// The collection accepts an index up to its next_item_index, see GetCollectionData.
+// The message must carry m.Amount plus about 0.02 TON for the fees.
+func MintBody(queryID uint64, m Mint) (*cell.Cell, error) {
+	content, err := m.itemContent()
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreUInt(OpMint, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreUInt(m.Index, 64).
+		MustStoreBigCoins(m.Amount.NanoTON()).
+		MustStoreRef(content).
+		EndCell(), nil
+}
+
+// BatchMintBody returns the body which deploys many items at once. The
+// indexes must start at most at next_item_index and grow one by one. The
+// message must carry the sum of the amounts plus about 0.02 TON per item.
+func BatchMintBody(queryID uint64, items []Mint) (*cell.Cell, error) {
+	if len(items) == 0 || len(items) > MaxBatchMint {
+		return nil, ErrBatchSize
+	}
+
+	// deploy_list: HashmapE 64 with the index as the key and amount:Coins ^content as the value
+	deployList := cell.NewDict(64)
+	for i, m := range items {
+		if i > 0 && m.Index != items[i-1].Index+1 {
+			return nil, fmt.Errorf("%w: %d after %d", ErrBatchOrder, m.Index, items[i-1].Index)
+		}
+		content, err := m.itemContent()
+		if err != nil {
+			return nil, err
+		}
+		value := cell.BeginCell().
+			MustStoreBigCoins(m.Amount.NanoTON()).
+			MustStoreRef(content).
+			EndCell()
+		if err = deployList.SetIntKey(new(big.Int).SetUint64(m.Index), value); err != nil {
+			return nil, fmt.Errorf("nft: item %d: %w", m.Index, err)
+		}
+	}
+
+	deployListCell, err := deployList.ToCell()
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreUInt(OpBatchMint, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreRef(deployListCell). // the collection reads the dictionary from a reference
+		EndCell(), nil
+}
+
+// ChangeOwnerBody returns the body which gives the collection to newOwner.
+func ChangeOwnerBody(queryID uint64, newOwner *address.Address) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(OpChangeOwner, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreAddr(newOwner).
+		EndCell()
+}
+
+// CollectionData is the result of the get_collection_data get method.
+type CollectionData struct {
+	NextItemIndex *big.Int
+	Content       *cell.Cell // the collection content, ParseContent reads it
+	Owner         *address.Address
+}
+
+// GetCollectionData runs the get_collection_data get method of a collection.
+func GetCollectionData(ctx context.Context, api walletv3.TonAPI, collection *address.Address) (*CollectionData, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, collection, "get_collection_data")
+	if err != nil {
+		return nil, fmt.Errorf("nft: run get_collection_data: %w", err)
+	}
+
+	data := &CollectionData{}
+	if data.NextItemIndex, err = result.Int(0); err != nil {
+		return nil, fmt.Errorf("nft: read next_item_index: %w", err)
+	}
+	if data.Content, err = result.Cell(1); err != nil {
+		return nil, fmt.Errorf("nft: read collection content: %w", err)
+	}
+	owner, err := result.Slice(2)
+	if err != nil {
+		return nil, fmt.Errorf("nft: read collection owner: %w", err)
+	}
+	if data.Owner, err = owner.LoadAddr(); err != nil {
+		return nil, fmt.Errorf("nft: read collection owner: %w", err)
+	}
+	return data, nil
+}
+
+// ItemAddress runs the get_nft_address_by_index get method of a collection. The
+// address is known before the item is minted, for example to show it to its owner.
+func ItemAddress(ctx context.Context, api walletv3.TonAPI, collection *address.Address, index uint64) (*address.Address, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, collection, "get_nft_address_by_index", new(big.Int).SetUint64(index))
+	if err != nil {
+		return nil, fmt.Errorf("nft: run get_nft_address_by_index: %w", err)
+	}
+	s, err := result.Slice(0)
+	if err != nil {
+		return nil, fmt.Errorf("nft: read item address: %w", err)
+	}
+	return s.LoadAddr()
+}
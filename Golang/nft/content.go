@@ -0,0 +1,233 @@
+package nft
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Content layouts of TEP-64, the first byte of the content cell.
+const (
+	ContentOnChain  = 0x00 // a dictionary sha256(key) -> value
+	ContentOffChain = 0x01 // a URI of a JSON file
+)
+
+// KnownKeys are the keys of on-chain content from TEP-64 and the NFT marketplaces.
+// The dictionary keeps only their hashes, these are the names which are recognized.
+var KnownKeys = []string{
+	"uri", "name", "description", "image", "image_data", "symbol", "decimals",
+	"amount_style", "render_type", "content_url", "attributes", "lottie", "social_links", "marketplace",
+}
+
+var ErrUnknownContent = errors.New("nft: content is neither on-chain (0x00) nor off-chain (0x01)")
+
+// Content is parsed TEP-64 content.
+type Content struct {
+	// URI is the off-chain JSON, or the "uri" key of on-chain content (semi-chain content).
+	URI string
+	// OnChain holds the on-chain keys, by name for KnownKeys and as the hex hash otherwise.
+	OnChain map[string]string
+}
+
+// ParseContent parses a content cell. For an item of a collection, pass FullContent.
+func ParseContent(c *cell.Cell) (*Content, error) {
+	s := c.BeginParse()
+	if s.BitsLeft() < 8 {
+		return nil, ErrUnknownContent
+	}
+
+	switch s.MustLoadUInt(8) {
+	case ContentOffChain:
+		uri, err := s.LoadBinarySnake()
+		if err != nil {
+			return nil, fmt.Errorf("nft: off-chain content: %w", err)
+		}
+		return &Content{URI: string(uri)}, nil
+	case ContentOnChain:
+		return parseOnChain(s)
+	default:
+		return nil, ErrUnknownContent
+	}
+}
+
+// onchain#00 data:(HashmapE 256 ^ContentData) = FullContent;
+func parseOnChain(s *cell.Slice) (*Content, error) {
+	dictionary, err := s.LoadDict(256)
+	if err != nil {
+		return nil, fmt.Errorf("nft: on-chain content: %w", err)
+	}
+
+	names := map[string]string{}
+	for _, key := range KnownKeys {
+		hash := sha256.Sum256([]byte(key))
+		names[hex.EncodeToString(hash[:])] = key
+	}
+
+	content := &Content{OnChain: map[string]string{}}
+	for _, kv := range dictionary.All() {
+		keyHash := hex.EncodeToString(kv.Key.BeginParse().MustLoadSlice(256))
+		name, ok := names[keyHash]
+		if !ok {
+			name = keyHash
+		}
+
+		ref, err := kv.Value.BeginParse().LoadRef()
+		if err != nil {
+			return nil, fmt.Errorf("nft: on-chain %s: %w", name, err)
+		}
+		value, err := loadContentData(ref)
+		if err != nil {
+			return nil, fmt.Errorf("nft: on-chain %s: %w", name, err)
+		}
+		content.OnChain[name] = string(value)
+	}
+	content.URI = content.OnChain["uri"]
+	return content, nil
+}
+
+// snake#00 data:(SnakeData ~n) = ContentData;
+// chunks#01 data:ChunkedData = ContentData; chunked_data#_ data:(HashmapE 32 ^(SnakeData ~0)) = ChunkedData;
+func loadContentData(s *cell.Slice) ([]byte, error) {
+	layout, err := s.LoadUInt(8)
+	if err != nil {
+		return nil, err
+	}
+	switch layout {
+	case 0x00:
+		return s.LoadBinarySnake()
+	case 0x01:
+		chunks, err := s.LoadDict(32)
+		if err != nil {
+			return nil, err
+		}
+		all := chunks.All()
+		sort.Slice(all, func(i, j int) bool {
+			return all[i].Key.BeginParse().MustLoadUInt(32) < all[j].Key.BeginParse().MustLoadUInt(32)
+		})
+		var data []byte
+		for _, kv := range all {
+			ref, err := kv.Value.BeginParse().LoadRef()
+			if err != nil {
+				return nil, err
+			}
+			chunk, err := ref.LoadBinarySnake()
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, chunk...)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown content data layout 0x%02x", layout)
+	}
+}
+
+// OffChain returns off-chain content which points to uri.
+func OffChain(uri string) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(ContentOffChain, 8).
+		MustStoreBinarySnake([]byte(uri)).
+		EndCell()
+}
+
+// OnChain returns on-chain content with the given keys, see KnownKeys.
+func OnChain(fields map[string]string) (*cell.Cell, error) {
+	dictionary := cell.NewDict(256)
+	for key, value := range fields {
+		hash := sha256.Sum256([]byte(key))
+
+		data := cell.BeginCell().MustStoreUInt(0x00, 8) // snake layout
+		if err := data.StoreBinarySnake([]byte(value)); err != nil {
+			return nil, fmt.Errorf("nft: on-chain %s: %w", key, err)
+		}
+		err := dictionary.SetIntKey(new(big.Int).SetBytes(hash[:]), cell.BeginCell().MustStoreRef(data.EndCell()).EndCell())
+		if err != nil {
+			return nil, fmt.Errorf("nft: on-chain %s: %w", key, err)
+		}
+	}
+	return cell.BeginCell().
+		MustStoreUInt(ContentOnChain, 8).
+		MustStoreDict(dictionary).
+		EndCell(), nil
+}
+
+// Metadata is the part of the metadata JSON which wallets and marketplaces show.
+type Metadata struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Image       string      `json:"image,omitempty"`
+	Attributes  []Attribute `json:"attributes,omitempty"`
+}
+
+// Attribute is a trait of an NFT item.
+type Attribute struct {
+	TraitType string `json:"trait_type"`
+	Value     any    `json:"value"`
+}
+
+// IPFSGateway serves the ipfs:// URIs for Metadata.
+var IPFSGateway = "https://ipfs.io/ipfs/"
+
+// Metadata returns the metadata of the content: the off-chain JSON, if there
+// is a URI, with the on-chain keys over it. client is http.DefaultClient if nil.
+func (c *Content) Metadata(ctx context.Context, client *http.Client) (*Metadata, error) {
+	m := &Metadata{}
+	if c.URI != "" {
+		if err := fetchJSON(ctx, client, c.URI, m); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, field := range map[string]*string{"name": &m.Name, "description": &m.Description, "image": &m.Image} {
+		if v, ok := c.OnChain[key]; ok {
+			*field = v
+		}
+	}
+	if v, ok := c.OnChain["attributes"]; ok {
+		if err := json.Unmarshal([]byte(v), &m.Attributes); err != nil {
+			return nil, fmt.Errorf("nft: on-chain attributes: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, uri string, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if strings.HasPrefix(uri, "ipfs://") {
+		uri = IPFSGateway + strings.TrimPrefix(uri, "ipfs://")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return fmt.Errorf("nft: metadata: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("nft: metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nft: metadata %s: %s", uri, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // metadata is small, an image URL is not what we read
+	if err != nil {
+		return fmt.Errorf("nft: metadata: %w", err)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("nft: metadata %s: %w", uri, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package nft
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/walletv3"
+)
+
+// ItemData is the result of the get_nft_data get method of an NFT item.
+type ItemData struct {
+	Initialized bool
+	Index       *big.Int
+	Collection  *address.Address // addr_none for an item without a collection
+	Owner       *address.Address
+	Content     *cell.Cell // the individual content, see FullContent
+}
+
+// GetItemData runs the get_nft_data get method of an NFT item.
+func GetItemData(ctx context.Context, api walletv3.TonAPI, item *address.Address) (*ItemData, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, item, "get_nft_data")
+	if err != nil {
+		return nil, fmt.Errorf("nft: run get_nft_data: %w", err)
+	}
+
+	data := &ItemData{}
+	initialized, err := result.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("nft: read init: %w", err)
+	}
+	data.Initialized = initialized.Sign() != 0
+	if data.Index, err = result.Int(1); err != nil {
+		return nil, fmt.Errorf("nft: read index: %w", err)
+	}
+	for i, addr := range []**address.Address{&data.Collection, &data.Owner} {
+		s, err := result.Slice(uint(i + 2))
+		if err != nil {
+			return nil, fmt.Errorf("nft: read item data: %w", err)
+		}
+		if *addr, err = s.LoadAddr(); err != nil {
+			return nil, fmt.Errorf("nft: read item data: %w", err)
+		}
+	}
+	if data.Content, err = result.Cell(4); err != nil {
+		return nil, fmt.Errorf("nft: read content: %w", err)
+	}
+	return data, nil
+}
+
+// CheckOwner returns ErrNotOwner if owner does not own item, and ErrCollection
+// if collection is not nil and the item is not from it. A transfer from a
+// wallet which is not the owner fails and the TON sent with it are spent on fees.
+func CheckOwner(ctx context.Context, api walletv3.TonAPI, item, owner, collection *address.Address) (*ItemData, error) {
+	data, err := GetItemData(ctx, api, item)
+	if err != nil {
+		return nil, err
+	}
+	if !data.Initialized {
+		return nil, ErrNotInited
+	}
+	if !sameAddress(data.Owner, owner) {
+		return nil, fmt.Errorf("%w: %s is owned by %s", ErrNotOwner, item.String(), data.Owner.String())
+	}
+	if collection != nil && !sameAddress(data.Collection, collection) {
+		return nil, fmt.Errorf("%w: %s is from %s", ErrCollection, item.String(), describe(data.Collection))
+	}
+	return data, nil
+}
+
+// FullContent returns the content of an item with the common part of its collection.
+// Items of a collection usually keep only their own part, like "12.json", and
+// the get_nft_content get method of the collection adds the rest.
+func FullContent(ctx context.Context, api walletv3.TonAPI, data *ItemData) (*cell.Cell, error) {
+	if data.Collection == nil || data.Collection.IsAddrNone() {
+		return data.Content, nil // an item without a collection keeps the whole content
+	}
+
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.RunGetMethod(ctx, block, data.Collection, "get_nft_content", data.Index, data.Content)
+	if err != nil {
+		return nil, fmt.Errorf("nft: run get_nft_content: %w", err)
+	}
+	content, err := result.Cell(0)
+	if err != nil {
+		return nil, fmt.Errorf("nft: read content: %w", err)
+	}
+	return content, nil
+}
+
+// sameAddress compares the workchain and the account ID, not the flags of the user-friendly form.
+func sameAddress(a, b *address.Address) bool {
+	if a == nil || b == nil || a.IsAddrNone() || b.IsAddrNone() {
+		return false
+	}
+	return a.Workchain() == b.Workchain() && string(a.Data()) == string(b.Data())
+}
+
+func describe(addr *address.Address) string {
+	if addr == nil || addr.IsAddrNone() {
+		return "no collection"
+	}
+	return addr.String()
+}
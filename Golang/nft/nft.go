@@ -0,0 +1,122 @@
+// Package nft builds transfers of NFT items (TEP-62) and reads their owner,
+// collection and metadata (TEP-64), the parts Chapter 4 does by hand. It also
+// deploys a standard collection and mints its items, see Collection.
+package nft
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+)
+
+// Opcodes of TEP-62.
+const (
+	OpTransfer          = 0x5fcc3d14 // owner -> item
+	OpOwnershipAssigned = 0x05138d91 // item -> new owner, if forward_amount is not zero
+	OpExcesses          = 0xd53276db // the TON left after a transfer -> response destination
+	OpGetStaticData     = 0x2fcb26a2
+	OpReportStaticData  = 0x8b771735
+)
+
+var (
+	ErrNoOwner    = errors.New("nft: transfer has no new owner")
+	ErrNoItems    = errors.New("nft: no items to transfer")
+	ErrNotOwner   = errors.New("nft: the wallet does not own the item")
+	ErrCollection = errors.New("nft: the item belongs to another collection")
+	ErrNotInited  = errors.New("nft: the item is not initialized by its collection yet")
+)
+
+// Transfer is the body the owner sends to an NFT item to give it to NewOwner.
+// The message needs enough TON for the fees plus ForwardAmount, 0.05 TON is usually enough.
+type Transfer struct {
+	QueryID  uint64
+	NewOwner *address.Address
+
+	// ResponseDestination gets the TON which is not spent on fees (op excesses), usually the sender wallet.
+	ResponseDestination *address.Address
+	CustomPayload       *cell.Cell // nil for none, some items (for example editable ones) read it
+
+	// ForwardAmount is sent to NewOwner with ownership_assigned, which holds
+	// ForwardPayload. With zero NewOwner gets no message.
+	ForwardAmount  tlb.Coins
+	ForwardPayload *cell.Cell // nil for none, comment.Text for a comment
+}
+
+// ToCell serializes the transfer:
+//
+//	transfer#5fcc3d14 query_id:uint64 new_owner:MsgAddress response_destination:MsgAddress
+//	custom_payload:(Maybe ^Cell) forward_amount:(VarUInteger 16) forward_payload:(Either Cell ^Cell)
+func (t *Transfer) ToCell() (*cell.Cell, error) {
+	if t.NewOwner == nil {
+		return nil, ErrNoOwner
+	}
+
+	response := t.ResponseDestination
+	if response == nil {
+		response = address.NewAddressNone()
+	}
+
+	b := cell.BeginCell().
+		MustStoreUInt(OpTransfer, 32).               // Opcode for NFT transfer
+		MustStoreUInt(t.QueryID, 64).                // query_id
+		MustStoreAddr(t.NewOwner).                   // new_owner
+		MustStoreAddr(response).                     // response_destination for excesses
+		MustStoreMaybeRef(t.CustomPayload).          // custom_payload
+		MustStoreBigCoins(t.ForwardAmount.NanoTON()) // forward_amount
+
+	if t.ForwardPayload == nil {
+		b.MustStoreBoolBit(false) // empty forward_payload in this cell
+	} else {
+		b.MustStoreBoolBit(true) // we store forward_payload as a reference
+		b.MustStoreRef(t.ForwardPayload)
+	}
+	return b.EndCell(), nil
+}
+
+// ItemTransfer is the transfer of one item, for a batch.
+type ItemTransfer struct {
+	Item     *address.Address
+	Value    tlb.Coins // TON sent to the item for the fees
+	Transfer Transfer
+}
+
+// Messages returns the internal messages of a batch of transfers, one per item,
+// all with mode 3. A wallet V3 sends up to walletv3.MaxMessages of them in one
+// transfer, a highload wallet up to highload.MaxMessages in one query.
+func Messages(transfers []ItemTransfer) ([]message.Out, error) {
+	if len(transfers) == 0 {
+		return nil, ErrNoItems
+	}
+
+	seen := map[string]bool{}
+	var messages []message.Out
+	for i, t := range transfers {
+		key := fmt.Sprintf("%d:%x", t.Item.Workchain(), t.Item.Data()) // the raw form, the same item may come with other flags
+		if seen[key] {
+			return nil, fmt.Errorf("nft: item %s is in the batch twice, the second transfer would fail", t.Item.String())
+		}
+		seen[key] = true
+
+		body, err := t.Transfer.ToCell()
+		if err != nil {
+			return nil, fmt.Errorf("nft: transfer %d: %w", i+1, err)
+		}
+		internalMessage, err := (&message.Internal{
+			Bounce:  true, // if the transfer fails, the TON comes back
+			Dest:    t.Item,
+			Value:   t.Value,
+			Body:    body,
+			BodyRef: true,
+		}).ToCell()
+		if err != nil {
+			return nil, fmt.Errorf("nft: transfer %d: %w", i+1, err)
+		}
+		messages = append(messages, message.Out{Mode: message.ModeDefault, Message: internalMessage})
+	}
+	return messages, nil
+}
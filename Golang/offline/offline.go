@@ -0,0 +1,244 @@
+// Package offline splits a wallet V3 transfer into three steps, so the mnemonic
+// never has to be on a machine connected to the network:
+//
+//  1. Prepare runs online: it reads seqno and the public key of the wallet and
+//     returns an unsigned TransferRequest, which is written to a file.
+//  2. TransferRequest.Sign runs on the air-gapped machine: the request is shown
+//     to a person with Describe, then signed.
+//  3. SignedTransfer.External runs online again: it checks the signature and
+//     wraps it into the ext_in_msg_info$10 message which is broadcast.
+//
+// The files contain only readable fields, the cells are built again from them
+// at every step, so what is shown is exactly what is signed.
+package offline
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/message"
+	"main/signer"
+	"main/walletv3"
+)
+
+var (
+	ErrWrongKey     = errors.New("offline: request was made for another public key")
+	ErrExpired      = errors.New("offline: transfer has already expired")
+	ErrBadSignature = errors.New("offline: signature does not match the transfer")
+)
+
+// TransferMessage is one internal message of a transfer.
+type TransferMessage struct {
+	Destination string           `json:"destination"`
+	Amount      string           `json:"amount"` // in TON, for example "0.01"
+	Comment     string           `json:"comment,omitempty"`
+	Bounce      bool             `json:"bounce"`
+	Mode        message.SendMode `json:"mode"`
+}
+
+// TransferRequest is an unsigned transfer from a wallet V3.
+type TransferRequest struct {
+	Wallet      string            `json:"wallet"`
+	PublicKey   string            `json:"public_key"` // hex, read from the wallet by Prepare
+	SubwalletID uint32            `json:"subwallet_id"`
+	Seqno       uint32            `json:"seqno"`
+	ValidUntil  time.Time         `json:"valid_until"`
+	Messages    []TransferMessage `json:"messages"`
+}
+
+// SignedTransfer is a request together with its signature.
+type SignedTransfer struct {
+	Request   TransferRequest `json:"request"`
+	Signature []byte          `json:"signature"`
+}
+
+// Prepare reads seqno and the public key of the wallet and makes an unsigned request.
+// validFor has to be long enough to carry the file to the air-gapped machine and back.
+func Prepare(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address, subwalletID uint32, validFor time.Duration, messages []TransferMessage) (*TransferRequest, error) {
+	seqno, err := walletv3.GetSeqno(ctx, api, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := walletv3.GetPublicKey(ctx, api, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &TransferRequest{
+		Wallet:      walletAddress.String(),
+		PublicKey:   hex.EncodeToString(publicKey),
+		SubwalletID: subwalletID,
+		Seqno:       seqno,
+		ValidUntil:  time.Now().Add(validFor).UTC().Truncate(time.Second),
+		Messages:    messages,
+	}
+
+	// build it once to report bad addresses or amounts before the file goes offline
+	if _, err = req.Transfer(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Transfer builds the wallet V3 transfer from the readable fields.
+func (r *TransferRequest) Transfer() (*walletv3.Transfer, error) {
+	t := &walletv3.Transfer{
+		SubwalletID: r.SubwalletID,
+		ValidUntil:  r.ValidUntil,
+		Seqno:       r.Seqno,
+	}
+	for i, m := range r.Messages {
+		msg, err := m.cell()
+		if err != nil {
+			return nil, fmt.Errorf("offline: message #%d: %w", i+1, err)
+		}
+		t.Messages = append(t.Messages, message.Out{Mode: m.Mode, Message: msg})
+	}
+
+	if _, err := t.Payload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Describe returns the transfer in a form to show to the person who signs it.
+func (r *TransferRequest) Describe() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Wallet:       %s\n", r.Wallet)
+	fmt.Fprintf(&sb, "Public key:   %s\n", r.PublicKey)
+	fmt.Fprintf(&sb, "Subwallet ID: %d\n", r.SubwalletID)
+	fmt.Fprintf(&sb, "Seqno:        %d\n", r.Seqno)
+	fmt.Fprintf(&sb, "Valid until:  %s\n", r.ValidUntil.Format(time.RFC3339))
+	for i, m := range r.Messages {
+		fmt.Fprintf(&sb, "Message #%d:\n", i+1)
+		fmt.Fprintf(&sb, "  to:      %s\n", m.Destination)
+		fmt.Fprintf(&sb, "  amount:  %s TON\n", m.Amount)
+		fmt.Fprintf(&sb, "  bounce:  %t\n", m.Bounce)
+		fmt.Fprintf(&sb, "  mode:    %s\n", m.Mode)
+		if m.Comment != "" {
+			fmt.Fprintf(&sb, "  comment: %q\n", m.Comment)
+		}
+	}
+
+	modes := make([]message.SendMode, len(r.Messages))
+	for i, m := range r.Messages {
+		modes[i] = m.Mode
+	}
+	warnings, err := message.CheckBatch(modes...)
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(&sb, "Warning:      %s\n", w)
+	}
+	return sb.String()
+}
+
+// Sign signs the request. It runs on the air-gapped machine.
+func (r *TransferRequest) Sign(ctx context.Context, s signer.Signer) (*SignedTransfer, error) {
+	if hex.EncodeToString(s.PublicKey()) != r.PublicKey {
+		return nil, ErrWrongKey
+	}
+	if time.Now().After(r.ValidUntil) {
+		return nil, ErrExpired
+	}
+
+	t, err := r.Transfer()
+	if err != nil {
+		return nil, err
+	}
+	body, err := t.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedTransfer{
+		Request:   *r,
+		Signature: body.BeginParse().MustLoadSlice(512),
+	}, nil
+}
+
+// External checks the signature and returns the external message to broadcast.
+func (st *SignedTransfer) External() (*cell.Cell, error) {
+	walletAddress, err := address.ParseAddr(st.Request.Wallet)
+	if err != nil {
+		return nil, fmt.Errorf("offline: wallet address: %w", err)
+	}
+	publicKey, err := hex.DecodeString(st.Request.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("offline: invalid public key %q", st.Request.PublicKey)
+	}
+
+	t, err := st.Request.Transfer()
+	if err != nil {
+		return nil, err
+	}
+	toSign, err := t.Payload()
+	if err != nil {
+		return nil, err
+	}
+	if len(st.Signature) != ed25519.SignatureSize || !ed25519.Verify(publicKey, toSign.EndCell().Hash(), st.Signature) {
+		return nil, ErrBadSignature
+	}
+
+	body := cell.BeginCell().
+		MustStoreSlice(st.Signature, 512). // store signature
+		MustStoreBuilder(toSign).          // store our message
+		EndCell()
+	return message.External(walletAddress, nil, body), nil
+}
+
+// cell builds the internal message the same way Chapter 4 does.
+func (m TransferMessage) cell() (*cell.Cell, error) {
+	destination, err := address.ParseAddr(m.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	amount, err := tlb.FromTON(m.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("amount: %w", err)
+	}
+
+	internalMessage := &message.Internal{
+		Dest:    destination,
+		Value:   amount,
+		Bounce:  m.Bounce,
+		BodyRef: true, // we store Message Body as a reference
+	}
+	if m.Comment != "" {
+		internalMessage.Body = cell.BeginCell().
+			MustStoreUInt(0, 32).
+			MustStoreStringSnake(m.Comment).
+			EndCell()
+	}
+	return internalMessage.ToCell()
+}
+
+// WriteFile writes a request or a signed transfer as JSON.
+func WriteFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// ReadFile reads a file written by WriteFile into v.
+func ReadFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
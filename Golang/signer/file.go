@@ -0,0 +1,156 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	requestSuffix   = ".request.json"
+	signatureSuffix = ".signature.json"
+)
+
+// FileRequest is written by FileSigner for the air-gapped machine.
+type FileRequest struct {
+	PublicKey []byte    `json:"public_key"`
+	Hash      []byte    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FileSignature is written back by SignRequests.
+type FileSignature struct {
+	Hash      []byte `json:"hash"`
+	Signature []byte `json:"signature"`
+}
+
+// FileSigner is used on the online machine when the key is on an air-gapped one.
+// Sign writes a request file to the directory and waits until a signature file
+// appears next to it. The directory is usually on a removable drive which is
+// carried between the machines, where SignRequests answers the requests.
+type FileSigner struct {
+	dir       string
+	publicKey ed25519.PublicKey
+
+	// PollInterval is how often the directory is checked for the signature.
+	PollInterval time.Duration
+}
+
+// NewFileSigner returns a file signer. The public key has to be known in advance,
+// because the air-gapped machine can not be asked for it.
+func NewFileSigner(dir string, publicKey ed25519.PublicKey) *FileSigner {
+	return &FileSigner{dir: dir, publicKey: publicKey, PollInterval: time.Second}
+}
+
+func (s *FileSigner) PublicKey() ed25519.PublicKey {
+	return s.publicKey
+}
+
+func (s *FileSigner) Sign(ctx context.Context, hash []byte) ([]byte, error) {
+	base := filepath.Join(s.dir, hex.EncodeToString(hash))
+
+	err := writeJSON(base+requestSuffix, FileRequest{
+		PublicKey: s.publicKey,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		var resp FileSignature
+		err = readJSON(base+signatureSuffix, &resp)
+		if err == nil {
+			if !bytes.Equal(resp.Hash, hash) {
+				return nil, fmt.Errorf("signer: signature file %s is for another hash", base+signatureSuffix)
+			}
+			if err = verify(s.publicKey, hash, resp.Signature); err != nil {
+				return nil, err
+			}
+
+			_ = os.Remove(base + requestSuffix)
+			_ = os.Remove(base + signatureSuffix)
+			return resp.Signature, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SignRequests runs on the air-gapped machine. It signs every request in dir
+// made for the public key of s and writes the signature files. Requests for
+// other keys are skipped. It returns how many requests were signed.
+func SignRequests(ctx context.Context, dir string, s Signer, approve Approver) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+requestSuffix))
+	if err != nil {
+		return 0, err
+	}
+
+	signed := 0
+	for _, path := range paths {
+		var req FileRequest
+		if err = readJSON(path, &req); err != nil {
+			return signed, err
+		}
+		if !s.PublicKey().Equal(ed25519.PublicKey(req.PublicKey)) {
+			continue
+		}
+		if len(req.Hash) != 32 {
+			return signed, fmt.Errorf("signer: request %s has invalid hash", path)
+		}
+		if err = approved(approve, req.Hash); err != nil {
+			return signed, fmt.Errorf("signer: request %s is not approved: %w", path, err)
+		}
+
+		signature, err := s.Sign(ctx, req.Hash)
+		if err != nil {
+			return signed, err
+		}
+
+		out := strings.TrimSuffix(path, requestSuffix) + signatureSuffix
+		if err = writeJSON(out, FileSignature{Hash: req.Hash, Signature: signature}); err != nil {
+			return signed, err
+		}
+		signed++
+	}
+	return signed, nil
+}
+
+// writeJSON writes to a temporary file first, so the other side never reads half of a file.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,169 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// maxFrameSize limits one request or response, real ones are less than 200 bytes.
+const maxFrameSize = 64 << 10
+
+// Request is sent to a remote signer. Method is "public_key" or "sign".
+type Request struct {
+	Method string `json:"method"`
+	Hash   []byte `json:"hash,omitempty"`
+}
+
+// Response is the answer of a remote signer. Error is set when the request was refused.
+type Response struct {
+	PublicKey []byte `json:"public_key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RemoteSigner asks another process to sign. The private key never leaves that process.
+type RemoteSigner struct {
+	publicKey ed25519.PublicKey
+	call      func(ctx context.Context, req Request) (*Response, error)
+}
+
+// NewUnixSigner connects to a signer listening on a Unix socket.
+// Every request is a 4 byte big endian length followed by JSON, the same for responses.
+func NewUnixSigner(ctx context.Context, socketPath string) (*RemoteSigner, error) {
+	call := func(ctx context.Context, req Request) (*Response, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "unix", socketPath)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+
+		if err = writeFrame(conn, req); err != nil {
+			return nil, err
+		}
+		var resp Response
+		if err = readFrame(conn, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	return newRemoteSigner(ctx, call)
+}
+
+// NewHTTPSigner connects to a signer served by Handler, url is the address of
+// the handler, for example "http://127.0.0.1:8081", and token is the one the handler was given.
+func NewHTTPSigner(ctx context.Context, url, token string, client *http.Client) (*RemoteSigner, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url = strings.TrimSuffix(url, "/")
+
+	call := func(ctx context.Context, req Request) (*Response, error) {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/"+req.Method, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+
+		httpResp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		defer httpResp.Body.Close()
+
+		var resp Response
+		if err = json.NewDecoder(io.LimitReader(httpResp.Body, maxFrameSize)).Decode(&resp); err != nil {
+			return nil, fmt.Errorf("signer: bad response, http status %d: %w", httpResp.StatusCode, err)
+		}
+		return &resp, nil
+	}
+	return newRemoteSigner(ctx, call)
+}
+
+func newRemoteSigner(ctx context.Context, call func(context.Context, Request) (*Response, error)) (*RemoteSigner, error) {
+	resp, err := call(ctx, Request{Method: "public_key"})
+	if err != nil {
+		return nil, fmt.Errorf("signer: get public key: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer: get public key: %s", resp.Error)
+	}
+	if len(resp.PublicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("signer: remote signer returned invalid public key")
+	}
+
+	return &RemoteSigner{publicKey: resp.PublicKey, call: call}, nil
+}
+
+func (s *RemoteSigner) PublicKey() ed25519.PublicKey {
+	return s.publicKey
+}
+
+func (s *RemoteSigner) Sign(ctx context.Context, hash []byte) ([]byte, error) {
+	resp, err := s.call(ctx, Request{Method: "sign", Hash: hash})
+	if err != nil {
+		return nil, fmt.Errorf("signer: sign: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer: sign: %s", resp.Error)
+	}
+
+	if err = verify(s.publicKey, hash, resp.Signature); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+func writeFrame(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxFrameSize {
+		return errors.New("signer: frame is too big")
+	}
+
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+
+	_, err = w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader, v any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return errors.New("signer: frame is too big")
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
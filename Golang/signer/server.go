@@ -0,0 +1,130 @@
+package signer
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Approver decides if a hash may be signed. It can log the request or ask an operator.
+// A nil Approver refuses every hash, signing everything has to be asked for with ApproveAll.
+type Approver func(hash []byte) error
+
+// ApproveAll approves every hash.
+func ApproveAll(hash []byte) error {
+	return nil
+}
+
+var errNoApprover = errors.New("no approver is set")
+
+// approved calls approve, which refuses when it is nil.
+func approved(approve Approver, hash []byte) error {
+	if approve == nil {
+		return errNoApprover
+	}
+	return approve(hash)
+}
+
+// TokenEnv is the environment variable with the token of the HTTP signer, for Handler and NewHTTPSigner.
+const TokenEnv = "TON_SIGNER_TOKEN"
+
+// Serve answers requests of NewUnixSigner on the listener until it is closed.
+// There is no token: whoever can open the socket can sign, so keep it readable by its owner only.
+func Serve(listener net.Listener, s Signer, approve Approver) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+
+			var req Request
+			if err := readFrame(conn, &req); err != nil {
+				log.Println("signer: read request:", err)
+				return
+			}
+			if err := writeFrame(conn, handle(context.Background(), s, approve, req)); err != nil {
+				log.Println("signer: write response:", err)
+			}
+		}()
+	}
+}
+
+// Handler answers requests of NewHTTPSigner on /public_key and /sign. Every
+// request has to carry the token as "Authorization: Bearer <token>"; with an
+// empty token every request is refused.
+func Handler(s Signer, approve Approver, token string) http.Handler {
+	mux := http.NewServeMux()
+	for _, method := range []string{"public_key", "sign"} {
+		method := method
+		mux.HandleFunc("/"+method, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !authorized(r, token) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(Response{Error: "bad or missing token"})
+				return
+			}
+
+			var req Request
+			if err := json.NewDecoder(io.LimitReader(r.Body, maxFrameSize)).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(Response{Error: "bad request: " + err.Error()})
+				return
+			}
+			req.Method = method
+
+			resp := handle(r.Context(), s, approve, req)
+			w.Header().Set("Content-Type", "application/json")
+			if resp.Error != "" {
+				w.WriteHeader(http.StatusForbidden)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		})
+	}
+	return mux
+}
+
+// authorized compares the bearer token of r with token in constant time.
+func authorized(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if token == "" || len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(token)) == 1
+}
+
+func handle(ctx context.Context, s Signer, approve Approver, req Request) Response {
+	switch req.Method {
+	case "public_key":
+		return Response{PublicKey: s.PublicKey()}
+	case "sign":
+		if len(req.Hash) != 32 {
+			return Response{Error: "hash must be 32 bytes"}
+		}
+		if err := approved(approve, req.Hash); err != nil {
+			return Response{Error: "not approved: " + err.Error()}
+		}
+
+		signature, err := s.Sign(ctx, req.Hash)
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{Signature: signature}
+	}
+	return Response{Error: "unknown method " + req.Method}
+}
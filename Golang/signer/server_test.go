@@ -0,0 +1,60 @@
+package signer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewKeySigner(key)
+	hash := make([]byte, 32)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		serverToken string
+		clientToken string
+		approve     Approver
+		connects    bool
+		signs       bool
+	}{
+		{"token and approval", "secret", "secret", ApproveAll, true, true},
+		{"no approver", "secret", "secret", nil, true, false},
+		{"rejected", "secret", "secret", func([]byte) error { return errors.New("no") }, true, false},
+		{"wrong token", "secret", "guess", ApproveAll, false, false},
+		{"no client token", "secret", "", ApproveAll, false, false},
+		{"no server token", "", "", ApproveAll, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(Handler(s, tt.approve, tt.serverToken))
+			defer server.Close()
+
+			remote, err := NewHTTPSigner(ctx, server.URL, tt.clientToken, nil)
+			if (err == nil) != tt.connects {
+				t.Fatalf("connect error %v", err)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), "token") {
+					t.Errorf("connect error %v, want a token error", err)
+				}
+				return
+			}
+			signature, err := remote.Sign(ctx, hash)
+			if (err == nil) != tt.signs {
+				t.Fatalf("sign error %v", err)
+			}
+			if err == nil && !ed25519.Verify(s.PublicKey(), hash, signature) {
+				t.Error("bad signature")
+			}
+		})
+	}
+}
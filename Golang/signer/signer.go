@@ -0,0 +1,58 @@
+// Package signer hides where the private key of a wallet lives.
+//
+// Wallet contracts check an ed25519 signature of the hash of the message cell,
+// so everything that builds a message only needs something that turns a hash
+// into a signature. The key can be in memory, in another process reached over
+// a Unix socket or HTTP, or on an air-gapped machine reached through files.
+package signer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+
+	"main/keys"
+)
+
+// Signer signs cell hashes for one wallet key.
+type Signer interface {
+	// PublicKey returns the public key the signatures can be checked with.
+	PublicKey() ed25519.PublicKey
+	// Sign returns the ed25519 signature of hash, which is usually cell.Hash().
+	Sign(ctx context.Context, hash []byte) ([]byte, error)
+}
+
+// ErrBadSignature is returned when a signer answers with a signature that does not match its public key.
+var ErrBadSignature = errors.New("signer: signature does not match the public key")
+
+// KeySigner keeps the private key in memory.
+type KeySigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewKeySigner returns a signer for a private key which is already in memory.
+func NewKeySigner(key ed25519.PrivateKey) *KeySigner {
+	return &KeySigner{key: key}
+}
+
+// FromKeyPair returns a signer for a key pair from the keys or keystore packages.
+func FromKeyPair(keyPair *keys.KeyPair) *KeySigner {
+	return NewKeySigner(keyPair.PrivateKey)
+}
+
+func (s *KeySigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *KeySigner) Sign(_ context.Context, hash []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, hash), nil
+}
+
+// verify makes sure that a signature which came from outside of the process
+// is correct, so a broken signer can not make us send a message that will be rejected.
+func verify(publicKey ed25519.PublicKey, hash, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(publicKey, hash, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Package subscription creates and reads the subscription plugin of wallet V4
+// (simple-subscription-plugin.fc from the wallet V4 sources): a contract which
+// every period asks the wallet for Amount and sends it to the beneficiary.
+//
+// The wallet deploys and installs the plugin with walletv4.DeployPlugin (see
+// Subscription.Deploy) and cancels it with walletv4.RemovePlugin. Between the
+// two, anyone may send the plugin an empty external message (ChargeMessage)
+// once a period is over: it asks the wallet with op "plug", the wallet answers
+// with the amount and the plugin forwards it to the beneficiary.
+//
+// The code of the plugin is not in this repository, the functions which need it
+// take it as a cell, like nft.Collection does with the collection code.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/walletv3"
+	"main/walletv4"
+)
+
+// Ops between the plugin, the wallet and the beneficiary.
+const (
+	OpPaymentRequest  = 0x706c7567               // "plug", the plugin asks the wallet for the amount
+	OpPaymentResponse = OpPaymentRequest | 1<<31 // the wallet pays, the body of the deploy pays the first period
+	OpDestruct        = 0x64737472               // "dstr", from the wallet (walletv4.RemovePlugin) or the beneficiary
+)
+
+var (
+	ErrNoBeneficiary = errors.New("subscription: no wallet or beneficiary")
+	ErrPeriod        = errors.New("subscription: period must be at least a second")
+)
+
+// Subscription is the data of a plugin.
+type Subscription struct {
+	Wallet      *address.Address // the wallet V4 which pays
+	Beneficiary *address.Address
+	Amount      tlb.Coins     // paid every Period
+	Period      time.Duration // whole seconds
+	StartTime   time.Time     // zero for now
+	Timeout     time.Duration // how long the plugin waits for the answer of the wallet before it asks again
+	ID          uint32        // tells apart subscriptions with the same terms, they would have the same address
+}
+
+// Data returns the initial data cell of the plugin:
+// wallet:MsgAddressInt beneficiary:MsgAddressInt amount:Coins period:uint32 start_time:uint32
+// timeout:uint32 last_payment_time:uint32 last_request_time:uint32 failed_attempts:uint8 subscription_id:uint32
+func (s *Subscription) Data() (*cell.Cell, error) {
+	if s.Wallet == nil || s.Beneficiary == nil {
+		return nil, ErrNoBeneficiary
+	}
+	if s.Period < time.Second {
+		return nil, ErrPeriod
+	}
+	return cell.BeginCell().
+		MustStoreAddr(s.Wallet).
+		MustStoreAddr(s.Beneficiary).
+		MustStoreBigCoins(s.Amount.NanoTON()).
+		MustStoreUInt(uint64(s.Period/time.Second), 32).
+		MustStoreUInt(uint64(unixOrZero(s.StartTime)), 32).
+		MustStoreUInt(uint64(s.Timeout/time.Second), 32).
+		MustStoreUInt(0, 32). // No payment yet
+		MustStoreUInt(0, 32). // No request yet
+		MustStoreUInt(0, 8).  // No failed attempts
+		MustStoreUInt(uint64(s.ID), 32).
+		EndCell(), nil
+}
+
+// StateInit returns the state init which deploys the plugin with code.
+func (s *Subscription) StateInit(code *cell.Cell) (*cell.Cell, error) {
+	data, err := s.Data()
+	if err != nil {
+		return nil, err
+	}
+	return contract.StateInit(code, data), nil
+}
+
+// Deploy returns the plugin action which deploys and installs the plugin
+// from the wallet. The wallet sends it Amount with OpPaymentResponse, so the
+// first period is paid right away, plus fee for the storage and the fees of
+// the plugin. The plugin is in the workchain of the wallet.
+func (s *Subscription) Deploy(code *cell.Cell, fee tlb.Coins) (*walletv4.DeployPlugin, error) {
+	stateInit, err := s.StateInit(code)
+	if err != nil {
+		return nil, err
+	}
+	balance := new(big.Int).Add(s.Amount.NanoTON(), fee.NanoTON())
+	return &walletv4.DeployPlugin{
+		Workchain: int8(s.Wallet.Workchain()),
+		Balance:   tlb.FromNanoTON(balance),
+		StateInit: stateInit,
+		Body:      cell.BeginCell().MustStoreUInt(OpPaymentResponse, 32).EndCell(),
+	}, nil
+}
+
+// ChargeMessage returns the external message which makes the plugin ask the
+// wallet for the next payment. The plugin accepts it only when a period is
+// over and Timeout has passed since its last request; anyone may send it.
+func ChargeMessage(plugin *address.Address) *cell.Cell {
+	return message.External(plugin, nil, cell.BeginCell().EndCell())
+}
+
+// CancelBody returns the body the beneficiary sends to the plugin to cancel
+// the subscription. The owner of the wallet cancels with walletv4.RemovePlugin.
+func CancelBody() *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(OpDestruct, 32).EndCell()
+}
+
+// State is what get_subscription_data returns.
+type State struct {
+	Plugin          *address.Address
+	Wallet          *address.Address
+	Beneficiary     *address.Address
+	Amount          tlb.Coins
+	Period          time.Duration
+	StartTime       time.Time
+	Timeout         time.Duration
+	LastPaymentTime time.Time // zero before the first payment
+	LastRequestTime time.Time
+	FailedAttempts  uint8 // requests the wallet did not pay, for example without enough TON
+	ID              uint32
+}
+
+// NextPayment returns when the plugin may ask for the next payment: a period
+// after the last payment, or the start time before the first one.
+func (d *State) NextPayment() time.Time {
+	if d.LastPaymentTime.IsZero() {
+		return d.StartTime
+	}
+	return d.LastPaymentTime.Add(d.Period)
+}
+
+// GetState runs the "get_subscription_data" get method of a plugin.
+func GetState(ctx context.Context, api walletv3.TonAPI, plugin *address.Address) (*State, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, plugin, "get_subscription_data")
+	if err != nil {
+		return nil, fmt.Errorf("subscription: run get_subscription_data: %w", err)
+	}
+
+	// The addresses are pairs (workchain, account id), then eight numbers
+	var addresses [2]*address.Address
+	for i := range addresses {
+		pair, err := result.Tuple(uint(i))
+		if err != nil || len(pair) != 2 {
+			return nil, fmt.Errorf("subscription: read address %d: %v", i, err)
+		}
+		workchain, ok := pair[0].(*big.Int)
+		hash, ok2 := pair[1].(*big.Int)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("subscription: read address %d: not two numbers", i)
+		}
+		addresses[i] = address.NewAddress(0, byte(workchain.Int64()), hash.FillBytes(make([]byte, 32)))
+	}
+	var numbers [8]*big.Int
+	for i := range numbers {
+		if numbers[i], err = result.Int(uint(i + 2)); err != nil {
+			return nil, fmt.Errorf("subscription: read get_subscription_data: %w", err)
+		}
+	}
+
+	return &State{
+		Plugin:          plugin,
+		Wallet:          addresses[0],
+		Beneficiary:     addresses[1],
+		Amount:          tlb.FromNanoTON(numbers[0]),
+		Period:          time.Duration(numbers[1].Int64()) * time.Second,
+		StartTime:       timeOrZero(numbers[2].Int64()),
+		Timeout:         time.Duration(numbers[3].Int64()) * time.Second,
+		LastPaymentTime: timeOrZero(numbers[4].Int64()),
+		LastRequestTime: timeOrZero(numbers[5].Int64()),
+		FailedAttempts:  uint8(numbers[6].Uint64()),
+		ID:              uint32(numbers[7].Uint64()),
+	}, nil
+}
+
+// List returns the subscriptions installed on a wallet V4, in the order of
+// get_plugin_list. Plugins which have no get_subscription_data are skipped.
+func List(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) ([]*State, error) {
+	plugins, err := walletv4.GetPluginList(ctx, api, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []*State
+	for _, plugin := range plugins {
+		data, err := GetState(ctx, api, plugin)
+		if err != nil {
+			continue // another kind of plugin
+		}
+		subscriptions = append(subscriptions, data)
+	}
+	return subscriptions, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,143 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+)
+
+var (
+	testWallet      = address.NewAddress(0, 0, append(make([]byte, 31), 1))
+	testBeneficiary = address.NewAddress(0, 0xff, append(make([]byte, 31), 2)) // in the masterchain
+)
+
+func TestData(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	s := Subscription{
+		Wallet:      testWallet,
+		Beneficiary: testBeneficiary,
+		Amount:      tlb.MustFromTON("1.5"),
+		Period:      30 * 24 * time.Hour,
+		StartTime:   start,
+		Timeout:     time.Hour,
+		ID:          9,
+	}
+	data, err := s.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := data.BeginParse()
+	if a := d.MustLoadAddr(); a.String() != testWallet.String() {
+		t.Errorf("wallet %s", a)
+	}
+	if a := d.MustLoadAddr(); a.String() != testBeneficiary.String() {
+		t.Errorf("beneficiary %s", a)
+	}
+	if amount := d.MustLoadBigCoins(); amount.Cmp(s.Amount.NanoTON()) != 0 {
+		t.Errorf("amount %s", amount)
+	}
+	fields := []struct {
+		name string
+		bits uint
+		want uint64
+	}{
+		{"period", 32, 30 * 24 * 3600},
+		{"start_time", 32, uint64(start.Unix())},
+		{"timeout", 32, 3600},
+		{"last_payment_time", 32, 0},
+		{"last_request_time", 32, 0},
+		{"failed_attempts", 8, 0},
+		{"subscription_id", 32, 9},
+	}
+	for _, f := range fields {
+		if got := d.MustLoadUInt(f.bits); got != f.want {
+			t.Errorf("%s = %d, want %d", f.name, got, f.want)
+		}
+	}
+	if d.BitsLeft() != 0 || d.RefsNum() != 0 {
+		t.Errorf("%d bits and %d refs after the fields", d.BitsLeft(), d.RefsNum())
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *Subscription)
+		err    error
+	}{
+		{"no beneficiary", func(s *Subscription) { s.Beneficiary = nil }, ErrNoBeneficiary},
+		{"no wallet", func(s *Subscription) { s.Wallet = nil }, ErrNoBeneficiary},
+		{"period below a second", func(s *Subscription) { s.Period = time.Second / 2 }, ErrPeriod},
+	}
+	for _, tt := range tests {
+		broken := s
+		tt.change(&broken)
+		if _, err := broken.Data(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// getMethodAPI answers every get method with result.
+type getMethodAPI struct {
+	result []any
+}
+
+func (a *getMethodAPI) CurrentMasterchainInfo(context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{}, nil
+}
+
+func (a *getMethodAPI) RunGetMethod(context.Context, *ton.BlockIDExt, *address.Address, string, ...any) (*ton.ExecutionResult, error) {
+	return ton.NewExecutionResult(a.result), nil
+}
+
+func TestGetState(t *testing.T) {
+	pair := func(a *address.Address) []any {
+		return []any{big.NewInt(int64(a.Workchain())), new(big.Int).SetBytes(a.Data())}
+	}
+	numbers := func(n ...int64) []any {
+		values := make([]any, len(n))
+		for i, v := range n {
+			values[i] = big.NewInt(v)
+		}
+		return values
+	}
+	// amount, period, start_time, timeout, last_payment_time, last_request_time, failed_attempts, subscription_id
+	stack := append([]any{pair(testWallet), pair(testBeneficiary)}, numbers(1_500_000_000, 3600, 1700000000, 60, 0, 1700000100, 2, 9)...)
+
+	plugin := address.NewAddress(0, 0, append(make([]byte, 31), 3))
+	state, err := GetState(context.Background(), &getMethodAPI{result: stack}, plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Wallet.String() != testWallet.String() || state.Beneficiary.String() != testBeneficiary.String() {
+		t.Errorf("wallet %s, beneficiary %s", state.Wallet, state.Beneficiary)
+	}
+	if state.Amount.NanoTON().Int64() != 1_500_000_000 || state.Period != time.Hour || state.Timeout != time.Minute ||
+		!state.StartTime.Equal(time.Unix(1700000000, 0)) || state.FailedAttempts != 2 || state.ID != 9 {
+		t.Errorf("state %+v", state)
+	}
+	if !state.LastPaymentTime.IsZero() || !state.NextPayment().Equal(state.StartTime) {
+		t.Errorf("before the first payment: last payment %s, next %s", state.LastPaymentTime, state.NextPayment())
+	}
+
+	tests := []struct {
+		name  string
+		stack []any
+	}{
+		{"address is not a pair", append([]any{[]any{big.NewInt(0)}, pair(testBeneficiary)}, stack[2:]...)},
+		{"address is not numbers", append([]any{[]any{big.NewInt(0), "x"}, pair(testBeneficiary)}, stack[2:]...)},
+		{"a number missing", stack[:len(stack)-1]},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		if _, err := GetState(context.Background(), &getMethodAPI{result: tt.stack}, plugin); err == nil {
+			t.Errorf("%s: parsed", tt.name)
+		}
+	}
+}
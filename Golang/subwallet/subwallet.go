@@ -0,0 +1,128 @@
+// Package subwallet lists the wallets one key owns under different subwallet IDs.
+//
+// The address of a wallet is the hash of its state init, and the subwallet ID is
+// a part of the data cell, so the same key and code give a new address for every
+// subwallet ID. Chapter 3 uses 698983191 and the deploy in Chapter 4 uses 3.
+package subwallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+)
+
+var (
+	ErrBadRange = errors.New("subwallet: first subwallet ID is greater than the last one")
+	ErrTooMany  = errors.New("subwallet: too many subwallet IDs in the range")
+)
+
+// MaxCount is the most subwallet IDs Enumerate lists at once.
+const MaxCount = 10000
+
+// DataFunc builds the initial data cell of a wallet, for example walletv3.Data or highload.Data.
+type DataFunc func(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell
+
+// Wallet is one subwallet of a key.
+type Wallet struct {
+	SubwalletID uint32
+	Address     *address.Address
+	StateInit   *cell.Cell
+}
+
+// Each calls fn with the wallets with subwallet IDs from first to last inclusive,
+// one at a time, and stops at the first error fn returns.
+func Each(publicKey ed25519.PublicKey, code *cell.Cell, data DataFunc, first, last uint32, fn func(Wallet) error) error {
+	if first > last {
+		return ErrBadRange
+	}
+
+	for id := uint64(first); id <= uint64(last); id++ { // uint64, so last = MaxUint32 does not loop forever
+		stateInit := contract.StateInit(code, data(publicKey, uint32(id)))
+		err := fn(Wallet{
+			SubwalletID: uint32(id),
+			Address:     address.NewAddress(0, 0, stateInit.Hash()),
+			StateInit:   stateInit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enumerate returns the wallets with subwallet IDs from first to last inclusive,
+// at most MaxCount of them. Use Each for a longer range.
+func Enumerate(publicKey ed25519.PublicKey, code *cell.Cell, data DataFunc, first, last uint32) ([]Wallet, error) {
+	if first > last {
+		return nil, ErrBadRange
+	}
+	if uint64(last-first)+1 > MaxCount {
+		return nil, fmt.Errorf("%w: %d, at most %d", ErrTooMany, uint64(last-first)+1, MaxCount)
+	}
+
+	wallets := make([]Wallet, 0, uint64(last-first)+1)
+	err := Each(publicKey, code, data, first, last, func(w Wallet) error {
+		wallets = append(wallets, w)
+		return nil
+	})
+	return wallets, err
+}
+
+// Status is the on-chain state of a wallet.
+type Status struct {
+	Wallet
+	Status  tlb.AccountStatus // ACTIVE, UNINIT, FROZEN or NON_EXIST
+	Balance tlb.Coins
+}
+
+// Active reports whether the wallet is deployed.
+func (s Status) Active() bool {
+	return s.Status == tlb.AccountStatusActive
+}
+
+// AccountAPI is the part of *ton.APIClient used to read the accounts.
+type AccountAPI interface {
+	CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+	GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error)
+}
+
+// Check reads the state and the balance of every wallet in one masterchain block.
+func Check(ctx context.Context, api AccountAPI, wallets []Wallet) ([]Status, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(wallets))
+	for _, w := range wallets {
+		s, err := CheckAt(ctx, api, block, w)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// CheckAt reads the state and the balance of one wallet in block.
+func CheckAt(ctx context.Context, api AccountAPI, block *ton.BlockIDExt, w Wallet) (Status, error) {
+	account, err := api.GetAccount(ctx, block, w.Address)
+	if err != nil {
+		return Status{}, fmt.Errorf("subwallet: get account %s: %w", w.Address, err)
+	}
+
+	s := Status{Wallet: w, Status: tlb.AccountStatusNonExist, Balance: tlb.MustFromTON("0")}
+	if account.IsActive && account.State != nil {
+		s.Status = account.State.Status
+		s.Balance = account.State.Balance
+	}
+	return s, nil
+}
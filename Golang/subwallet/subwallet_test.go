@@ -0,0 +1,68 @@
+package subwallet
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func testData(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return cell.BeginCell().MustStoreUInt(uint64(subwalletID), 32).MustStoreSlice(publicKey, 256).EndCell()
+}
+
+func TestEnumerate(t *testing.T) {
+	publicKey := make(ed25519.PublicKey, ed25519.PublicKeySize)
+	code := cell.BeginCell().MustStoreUInt(1, 8).EndCell()
+
+	wallets, err := Enumerate(publicKey, code, testData, 5, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i, w := range wallets {
+		if w.SubwalletID != uint32(5+i) || seen[w.Address.String()] {
+			t.Errorf("wallet %d: subwallet %d, address %s", i, w.SubwalletID, w.Address)
+		}
+		seen[w.Address.String()] = true
+	}
+	if len(wallets) != 3 {
+		t.Errorf("%d wallets, want 3", len(wallets))
+	}
+
+	tests := []struct {
+		name        string
+		first, last uint32
+		err         error
+	}{
+		{"reversed", 7, 5, ErrBadRange},
+		{"above MaxCount", 0, MaxCount, ErrTooMany},
+		{"the whole range", 0, math.MaxUint32, ErrTooMany},
+	}
+	for _, tt := range tests {
+		if _, err := Enumerate(publicKey, code, testData, tt.first, tt.last); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// Each stops at the last ID without wrapping around, and at the first error
+	var ids []uint32
+	stop := errors.New("stop")
+	err = Each(publicKey, code, testData, math.MaxUint32-1, math.MaxUint32, func(w Wallet) error {
+		ids = append(ids, w.SubwalletID)
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[1] != math.MaxUint32 {
+		t.Errorf("Each to MaxUint32: %v, %v", ids, err)
+	}
+	calls := 0
+	err = Each(publicKey, code, testData, 0, math.MaxUint32, func(Wallet) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Each after an error: %d calls, %v", calls, err)
+	}
+}
@@ -0,0 +1,238 @@
+// Package vanity searches for a wallet whose user-friendly address has a given
+// prefix or suffix, using the stateInit -> address computation from Chapter 3.
+//
+// The search either tries subwallet IDs of one key, which is fast, or generates
+// a fresh mnemonic for every try, which is slow (pbkdf2 with 100000 iterations)
+// but gives a wallet with the default subwallet ID.
+//
+// Every base64 character multiplies the work by 64, or by about 32 with
+// IgnoreCase, so more than 5 characters takes hours even on many cores.
+package vanity
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/keys"
+	"main/subwallet"
+)
+
+var (
+	ErrNoPattern     = errors.New("vanity: prefix or suffix is required")
+	ErrNoKey         = errors.New("vanity: subwallet search needs a public key")
+	ErrBadCharacter  = errors.New("vanity: address can only have characters A-Z, a-z, 0-9, - and _")
+	ErrNotFound      = errors.New("vanity: all subwallet IDs were tried")
+	ErrAddressPrefix = errors.New("vanity: address in workchain 0 starts with EQ, UQ, kQ or 0Q and then A, B, C or D")
+)
+
+// Options describe what to search for.
+type Options struct {
+	Prefix     string // the beginning of the address, including the tag, for example "EQDtest"
+	Suffix     string
+	IgnoreCase bool
+	Bounceable bool // match the EQ... form of the address instead of UQ...
+	Testnet    bool // match the testnet-only form, kQ... or 0Q...
+
+	// FreshKeys generates a new mnemonic for every try with SubwalletID.
+	// Otherwise subwallet IDs from SubwalletID upwards are tried with PublicKey.
+	FreshKeys   bool
+	PublicKey   ed25519.PublicKey
+	SubwalletID uint32
+
+	Code *cell.Cell
+	Data subwallet.DataFunc
+
+	Workers int // runtime.NumCPU() if 0
+
+	// Progress is called every ProgressInterval (a second if 0) with the number of tries so far.
+	Progress         func(tried uint64, elapsed time.Duration)
+	ProgressInterval time.Duration
+}
+
+// Result is a found wallet, ready to be deployed like in Chapter 3.
+type Result struct {
+	Mnemonic    []string // only with FreshKeys
+	PublicKey   ed25519.PublicKey
+	SubwalletID uint32
+	Address     *address.Address
+	StateInit   *cell.Cell
+	Tried       uint64
+}
+
+// Search runs until an address matches, ctx is cancelled or, for a subwallet
+// search, all subwallet IDs were tried.
+func Search(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		tried   atomic.Uint64
+		nextID  atomic.Uint64 // next subwallet ID to try
+		once    sync.Once
+		found   *Result
+		failure error
+		wg      sync.WaitGroup
+	)
+	nextID.Store(uint64(opts.SubwalletID))
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for searchCtx.Err() == nil {
+				r, err := opts.try(&nextID)
+				if err != nil {
+					once.Do(func() { // subwallet IDs are over or the random generator failed
+						failure = err
+						cancel()
+					})
+					return
+				}
+				tried.Add(1)
+
+				if opts.matches(r.Address.String()) {
+					once.Do(func() {
+						found = r
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	opts.reportProgress(done, &tried)
+
+	switch {
+	case found != nil:
+		found.Tried = tried.Load()
+		return found, nil
+	case failure != nil:
+		return nil, failure
+	default:
+		return nil, ctx.Err()
+	}
+}
+
+func (opts *Options) validate() error {
+	if opts.Prefix == "" && opts.Suffix == "" {
+		return ErrNoPattern
+	}
+	for _, c := range opts.Prefix + opts.Suffix {
+		if !strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_", c) {
+			return ErrBadCharacter
+		}
+	}
+
+	// the first two characters are the tag of the address and the third one has
+	// only the two highest bits of the hash, so a prefix which can not be there would never be found
+	tag := map[[2]bool]string{
+		{true, false}:  "EQ",
+		{false, false}: "UQ",
+		{true, true}:   "kQ",
+		{false, true}:  "0Q",
+	}[[2]bool{opts.Bounceable, opts.Testnet}]
+	prefix, head := opts.Prefix, tag+"ABCD"
+	if opts.IgnoreCase {
+		prefix, head = strings.ToUpper(prefix), strings.ToUpper(head)
+	}
+	for i := 0; i < len(prefix) && i < 3; i++ {
+		if i < 2 && prefix[i] != head[i] || i == 2 && !strings.ContainsRune(head[2:], rune(prefix[i])) {
+			return fmt.Errorf("%w, got %q", ErrAddressPrefix, opts.Prefix)
+		}
+	}
+
+	if !opts.FreshKeys && len(opts.PublicKey) != ed25519.PublicKeySize {
+		return ErrNoKey
+	}
+	return nil
+}
+
+// try builds the next wallet.
+func (opts *Options) try(nextID *atomic.Uint64) (*Result, error) {
+	r := &Result{PublicKey: opts.PublicKey, SubwalletID: opts.SubwalletID}
+
+	if opts.FreshKeys {
+		mnemonic, err := keys.NewMnemonic()
+		if err != nil {
+			return nil, err
+		}
+		keyPair, err := keys.FromMnemonic(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+		r.Mnemonic, r.PublicKey = mnemonic, keyPair.PublicKey
+	} else {
+		id := nextID.Add(1) - 1
+		if id > math.MaxUint32 {
+			return nil, ErrNotFound
+		}
+		r.SubwalletID = uint32(id)
+	}
+
+	r.StateInit = contract.StateInit(opts.Code, opts.Data(r.PublicKey, r.SubwalletID))
+	r.Address = address.NewAddress(0, 0, r.StateInit.Hash()) // get the hash of stateInit to get the address in workchain 0
+	r.Address.SetBounce(opts.Bounceable)
+	r.Address.SetTestnetOnly(opts.Testnet)
+	return r, nil
+}
+
+func (opts *Options) matches(addr string) bool {
+	if opts.IgnoreCase {
+		return len(addr) >= len(opts.Prefix) && strings.EqualFold(addr[:len(opts.Prefix)], opts.Prefix) &&
+			len(addr) >= len(opts.Suffix) && strings.EqualFold(addr[len(addr)-len(opts.Suffix):], opts.Suffix)
+	}
+	return strings.HasPrefix(addr, opts.Prefix) && strings.HasSuffix(addr, opts.Suffix)
+}
+
+// reportProgress calls opts.Progress until done is closed.
+func (opts *Options) reportProgress(done <-chan struct{}, tried *atomic.Uint64) {
+	if opts.Progress == nil {
+		<-done
+		return
+	}
+
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-done:
+			opts.Progress(tried.Load(), time.Since(start))
+			return
+		case <-ticker.C:
+			opts.Progress(tried.Load(), time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package walletv3 builds deploy and transfer messages for the wallet V3 contract
+// the same way Chapters 2-4 do it by hand.
+package walletv3
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/signer"
+)
+
+// DefaultSubwalletID is the subwallet ID used by the standard wallet apps for workchain 0.
+const DefaultSubwalletID = 698983191
+
+// MaxMessages is how many internal messages wallet V3 can send at once.
+const MaxMessages = 4
+
+// codeBOC is the compiled wallet V3 code from Chapter 3.
+const codeBOC = "te6ccgEBCAEAhgABFP8A9KQT9LzyyAsBAgEgAgMCAUgEBQCW8oMI1xgg0x/TH9MfAvgju/Jj7UTQ0x/TH9P/0VEyuvKhUUS68qIE+QFUEFX5EPKj+ACTINdKltMH1AL7AOgwAaTIyx/LH8v/ye1UAATQMAIBSAYHABe7Oc7UTQ0z8x1wv/gAEbjJftRNDXCx+A=="
+
+var ErrTooManyMessages = errors.New("walletv3: wallet can send at most 4 messages at once")
+
+var code = contract.MustLoadCode(codeBOC)
+
+// Code returns the wallet V3 code cell.
+func Code() *cell.Cell {
+	return code
+}
+
+// Data returns the initial data cell of a wallet.
+func Data(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(0, 32).                   // Seqno
+		MustStoreUInt(uint64(subwalletID), 32). // Subwallet ID
+		MustStoreSlice(publicKey, 256).         // Public Key
+		EndCell()
+}
+
+// StateInit returns the state init which deploys a wallet.
+func StateInit(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return contract.StateInit(Code(), Data(publicKey, subwalletID))
+}
+
+// Address returns the address of a wallet in workchain 0.
+func Address(publicKey ed25519.PublicKey, subwalletID uint32) *address.Address {
+	return address.NewAddress(0, 0, StateInit(publicKey, subwalletID).Hash())
+}
+
+// Transfer is the message a wallet owner signs to send up to 4 internal messages.
+type Transfer struct {
+	SubwalletID uint32
+	ValidUntil  time.Time
+	Seqno       uint32
+	Messages    []message.Out
+}
+
+// Payload returns the part of the message which is signed.
+func (t *Transfer) Payload() (*cell.Builder, error) {
+	if len(t.Messages) > MaxMessages {
+		return nil, ErrTooManyMessages
+	}
+
+	toSign := cell.BeginCell().
+		MustStoreUInt(uint64(t.SubwalletID), 32).       // subwallet_id
+		MustStoreUInt(uint64(t.ValidUntil.Unix()), 32). // message expiration time
+		MustStoreUInt(uint64(t.Seqno), 32)              // store seqno
+
+	for i, m := range t.Messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("walletv3: message %d: %w", i+1, err)
+		}
+		toSign.MustStoreUInt(uint64(m.Mode), 8) // store mode of our internal message
+		toSign.MustStoreRef(m.Message)          // store our internal message as a reference
+	}
+	return toSign, nil
+}
+
+// Sign signs the transfer with s and returns the body of the external message.
+func (t *Transfer) Sign(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	toSign, err := t.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.Sign(ctx, toSign.EndCell().Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreSlice(signature, 512). // store signature
+		MustStoreBuilder(toSign).       // store our message
+		EndCell(), nil
+}
+
+// External signs the transfer and wraps it into an external message to walletAddress.
+// stateInit is nil for a wallet which is already deployed.
+func (t *Transfer) External(ctx context.Context, s signer.Signer, walletAddress *address.Address, stateInit *cell.Cell) (*cell.Cell, error) {
+	body, err := t.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return message.External(walletAddress, stateInit, body), nil
+}
+
+// TonAPI is the part of *ton.APIClient used to read the wallet state.
+type TonAPI interface {
+	CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+	RunGetMethod(ctx context.Context, block *ton.BlockIDExt, addr *address.Address, method string, params ...any) (*ton.ExecutionResult, error)
+}
+
+// GetSeqno runs the "seqno" get method of a wallet.
+func GetSeqno(ctx context.Context, api TonAPI, walletAddress *address.Address) (uint32, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "seqno")
+	if err != nil {
+		return 0, fmt.Errorf("walletv3: run seqno: %w", err)
+	}
+	seqno, err := result.Int(0)
+	if err != nil {
+		return 0, fmt.Errorf("walletv3: read seqno: %w", err)
+	}
+	return uint32(seqno.Uint64()), nil
+}
+
+// GetPublicKey runs the "get_public_key" get method of a wallet.
+func GetPublicKey(ctx context.Context, api TonAPI, walletAddress *address.Address) (ed25519.PublicKey, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "get_public_key")
+	if err != nil {
+		return nil, fmt.Errorf("walletv3: run get_public_key: %w", err)
+	}
+	publicKey, err := result.Int(0)
+	if err != nil {
+		return nil, fmt.Errorf("walletv3: read public key: %w", err)
+	}
+	return publicKey.FillBytes(make([]byte, ed25519.PublicKeySize)), nil
+}
@@ -0,0 +1,294 @@
+// Package walletv4 builds deploy, transfer and plugin messages for the wallet
+// V4R2 contract. It differs from wallet V3 (package walletv3) by one byte, the
+// op after the seqno, and by the dictionary of plugins in its data: a plugin,
+// for example a subscription, is a contract which may ask the wallet for TON.
+package walletv4
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/signer"
+	"main/walletv3"
+)
+
+// DefaultSubwalletID is the same as for wallet V3.
+const DefaultSubwalletID = walletv3.DefaultSubwalletID
+
+// MaxMessages is how many internal messages wallet V4 can send at once.
+const MaxMessages = 4
+
+// Ops of the signed body, the byte after the seqno.
+const (
+	OpSend                   = 0 // send the internal messages
+	OpDeployAndInstallPlugin = 1
+	OpInstallPlugin          = 2
+	OpRemovePlugin           = 3
+)
+
+// Ops the wallet sends to a plugin when it is installed or removed.
+const (
+	OpPluginInstalled = 0x6e6f7465 // "note"
+	OpPluginRemoved   = 0x64737472 // "dstr"
+)
+
+// codeBOC is the compiled wallet V4R2 code.
+const codeBOC = "te6cckECFAEAAtQAART/APSkE/S88sgLAQIBIAIDAgFIBAUE+PKDCNcYINMf0x/THwL4I7vyZO1E0NMf0x/T//QE0VFDuvKhUVG68qIF+QFUEGT5EPKj+AAkpMjLH1JAyx9SMMv/UhD0AMntVPgPAdMHIcAAn2xRkyDXSpbTB9QC+wDoMOAhwAHjACHAAuMAAcADkTDjDQOkyMsfEssfy/8QERITAubQAdDTAyFxsJJfBOAi10nBIJJfBOAC0x8hghBwbHVnvSKCEGRzdHK9sJJfBeAD+kAwIPpEAcjKB8v/ydDtRNCBAUDXIfQEMFyBAQj0Cm+hMbOSXwfgBdM/yCWCEHBsdWe6kjgw4w0DghBkc3RyupJfBuMNBgcCASAICQB4AfoA9AQw+CdvIjBQCqEhvvLgUIIQcGx1Z4MesXCAGFAEywUmzxZY+gIZ9ADLaRfLH1Jgyz8gyYBA+wAGAIpQBIEBCPRZMO1E0IEBQNcgyAHPFvQAye1UAXKwjiOCEGRzdHKDHrFwgBhQBcsFUAPPFiP6AhPLassfyz/JgED7AJJfA+ICASAKCwBZvSQrb2omhAgKBrkPoCGEcNQICEekk30pkQzmkD6f+YN4EoAbeBAUiYcVnzGEAgFYDA0AEbjJftRNDXCx+AA9sp37UTQgQFA1yH0BDACyMoHy//J0AGBAQj0Cm+hMYAIBIA4PABmtznaiaEAga5Drhf/AABmvHfaiaEAQa5DrhY/AAG7SB/oA1NQi+QAFyMoHFcv/ydB3dIAYyMsFywIizxZQBfoCFMtrEszMyXP7AMhAFIEBCPRR8qcCAHCBAQjXGPoA0z/IVCBHgQEI9FHyp4IQbm90ZXB0gBjIywXLAlAGzxZQBPoCFMtqEssfyz/Jc/sAAgBsgQEI1xj6ANM/MFIkgQEI9Fnyp4IQZHN0cnB0gBjIywXLAlAFzxZQA/oCE8tqyx8Syz/Jc/sAAAr0AMntVGliJeU="
+
+var (
+	ErrTooManyMessages     = errors.New("walletv4: wallet can send at most 4 messages at once")
+	ErrPluginWithMessages  = errors.New("walletv4: a plugin operation sends no internal messages")
+	ErrNoPlugin            = errors.New("walletv4: no plugin address")
+	ErrPluginNotStdAddress = errors.New("walletv4: a plugin must have a standard address")
+)
+
+var code = contract.MustLoadCode(codeBOC)
+
+// Code returns the wallet V4R2 code cell.
+func Code() *cell.Cell {
+	return code
+}
+
+// Data returns the initial data cell of a wallet: the one of wallet V3 with an empty dictionary of plugins.
+func Data(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(0, 32).                   // Seqno
+		MustStoreUInt(uint64(subwalletID), 32). // Subwallet ID
+		MustStoreSlice(publicKey, 256).         // Public Key
+		MustStoreDict(nil).                     // No plugins
+		EndCell()
+}
+
+// StateInit returns the state init which deploys a wallet.
+func StateInit(publicKey ed25519.PublicKey, subwalletID uint32) *cell.Cell {
+	return contract.StateInit(Code(), Data(publicKey, subwalletID))
+}
+
+// Address returns the address of a wallet in workchain 0.
+func Address(publicKey ed25519.PublicKey, subwalletID uint32) *address.Address {
+	return address.NewAddress(0, 0, StateInit(publicKey, subwalletID).Hash())
+}
+
+// PluginAction is an operation on the plugins of the wallet: DeployPlugin, InstallPlugin or RemovePlugin.
+type PluginAction interface {
+	op() uint8
+	store(b *cell.Builder) error
+}
+
+// DeployPlugin deploys a plugin with StateInit and Body, sends it Balance and installs it (op 1).
+type DeployPlugin struct {
+	Workchain int8
+	Balance   tlb.Coins
+	StateInit *cell.Cell
+	Body      *cell.Cell // the body of the deploy message, an empty cell if nil
+}
+
+// Address returns the address of the plugin the action deploys.
+func (d *DeployPlugin) Address() *address.Address {
+	return address.NewAddress(0, byte(d.Workchain), d.StateInit.Hash())
+}
+
+func (d *DeployPlugin) op() uint8 { return OpDeployAndInstallPlugin }
+
+func (d *DeployPlugin) store(b *cell.Builder) error {
+	if d.StateInit == nil {
+		return errors.New("walletv4: plugin deploy has no state init")
+	}
+	body := d.Body
+	if body == nil {
+		body = cell.BeginCell().EndCell()
+	}
+	b.MustStoreInt(int64(d.Workchain), 8).
+		MustStoreBigCoins(d.Balance.NanoTON()).
+		MustStoreRef(d.StateInit).
+		MustStoreRef(body)
+	return nil
+}
+
+// InstallPlugin adds a deployed plugin to the wallet and sends it Amount with op "note" (op 2).
+type InstallPlugin struct {
+	Plugin  *address.Address
+	Amount  tlb.Coins
+	QueryID uint64
+}
+
+func (i *InstallPlugin) op() uint8 { return OpInstallPlugin }
+
+func (i *InstallPlugin) store(b *cell.Builder) error {
+	return storePlugin(b, i.Plugin, i.Amount, i.QueryID)
+}
+
+// RemovePlugin removes a plugin from the wallet and sends it Amount with op "dstr" (op 3).
+type RemovePlugin struct {
+	Plugin  *address.Address
+	Amount  tlb.Coins
+	QueryID uint64
+}
+
+func (r *RemovePlugin) op() uint8 { return OpRemovePlugin }
+
+func (r *RemovePlugin) store(b *cell.Builder) error {
+	return storePlugin(b, r.Plugin, r.Amount, r.QueryID)
+}
+
+// storePlugin stores wc:int8 addr_hash:uint256 amount:Coins query_id:uint64, the same for install and remove.
+func storePlugin(b *cell.Builder, plugin *address.Address, amount tlb.Coins, queryID uint64) error {
+	if plugin == nil {
+		return ErrNoPlugin
+	}
+	if plugin.Type() != address.StdAddress {
+		return ErrPluginNotStdAddress
+	}
+	b.MustStoreInt(int64(plugin.Workchain()), 8).
+		MustStoreSlice(plugin.Data(), 256).
+		MustStoreBigCoins(amount.NanoTON()).
+		MustStoreUInt(queryID, 64)
+	return nil
+}
+
+// Transfer is the message a wallet owner signs. With Plugin nil it sends up to
+// 4 internal messages like wallet V3 (op 0), else it runs the plugin action
+// and sends no messages.
+type Transfer struct {
+	SubwalletID uint32
+	ValidUntil  time.Time
+	Seqno       uint32
+	Messages    []message.Out
+	Plugin      PluginAction
+}
+
+// Payload returns the part of the message which is signed.
+func (t *Transfer) Payload() (*cell.Builder, error) {
+	if len(t.Messages) > MaxMessages {
+		return nil, ErrTooManyMessages
+	}
+
+	toSign := cell.BeginCell().
+		MustStoreUInt(uint64(t.SubwalletID), 32).       // subwallet_id
+		MustStoreUInt(uint64(t.ValidUntil.Unix()), 32). // message expiration time
+		MustStoreUInt(uint64(t.Seqno), 32)              // store seqno
+
+	if t.Plugin != nil {
+		if len(t.Messages) > 0 {
+			return nil, ErrPluginWithMessages
+		}
+		toSign.MustStoreUInt(uint64(t.Plugin.op()), 8)
+		if err := t.Plugin.store(toSign); err != nil {
+			return nil, err
+		}
+		return toSign, nil
+	}
+
+	toSign.MustStoreUInt(OpSend, 8) // the byte wallet V3 does not have
+	for i, m := range t.Messages {
+		if err := m.Mode.Validate(); err != nil {
+			return nil, fmt.Errorf("walletv4: message %d: %w", i+1, err)
+		}
+		toSign.MustStoreUInt(uint64(m.Mode), 8) // store mode of our internal message
+		toSign.MustStoreRef(m.Message)          // store our internal message as a reference
+	}
+	return toSign, nil
+}
+
+// Sign signs the transfer with s and returns the body of the external message.
+func (t *Transfer) Sign(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	toSign, err := t.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.Sign(ctx, toSign.EndCell().Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreSlice(signature, 512). // store signature
+		MustStoreBuilder(toSign).       // store our message
+		EndCell(), nil
+}
+
+// External signs the transfer and wraps it into an external message to walletAddress.
+// stateInit is nil for a wallet which is already deployed.
+func (t *Transfer) External(ctx context.Context, s signer.Signer, walletAddress *address.Address, stateInit *cell.Cell) (*cell.Cell, error) {
+	body, err := t.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return message.External(walletAddress, stateInit, body), nil
+}
+
+// GetSeqno runs the "seqno" get method, the same as the one of wallet V3.
+func GetSeqno(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (uint32, error) {
+	return walletv3.GetSeqno(ctx, api, walletAddress)
+}
+
+// IsPluginInstalled runs the "is_plugin_installed" get method of a wallet.
+func IsPluginInstalled(ctx context.Context, api walletv3.TonAPI, walletAddress, plugin *address.Address) (bool, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	hash := new(big.Int).SetBytes(plugin.Data())
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "is_plugin_installed", int64(plugin.Workchain()), hash)
+	if err != nil {
+		return false, fmt.Errorf("walletv4: run is_plugin_installed: %w", err)
+	}
+	installed, err := result.Int(0)
+	if err != nil {
+		return false, fmt.Errorf("walletv4: read is_plugin_installed: %w", err)
+	}
+	return installed.Sign() != 0, nil // -1 is true
+}
+
+// GetPluginList runs the "get_plugin_list" get method of a wallet.
+func GetPluginList(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) ([]*address.Address, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "get_plugin_list")
+	if err != nil {
+		return nil, fmt.Errorf("walletv4: run get_plugin_list: %w", err)
+	}
+	if empty, err := result.IsNil(0); err != nil || empty {
+		return nil, err // an empty list is null
+	}
+	list, err := result.Tuple(0)
+	if err != nil {
+		return nil, fmt.Errorf("walletv4: read get_plugin_list: %w", err)
+	}
+
+	// The list is a lisp-style one: [[wc, addr_hash], tail], where tail is the same or null
+	var plugins []*address.Address
+	for list != nil {
+		if len(list) != 2 {
+			return nil, errors.New("walletv4: get_plugin_list: unexpected list")
+		}
+		pair, ok := list[0].([]any)
+		if !ok || len(pair) != 2 {
+			return nil, errors.New("walletv4: get_plugin_list: unexpected pair")
+		}
+		wc, okWc := pair[0].(*big.Int)
+		hash, okHash := pair[1].(*big.Int)
+		if !okWc || !okHash {
+			return nil, errors.New("walletv4: get_plugin_list: unexpected pair")
+		}
+		plugins = append(plugins, address.NewAddress(0, byte(wc.Int64()), hash.FillBytes(make([]byte, 32))))
+
+		list, _ = list[1].([]any) // nil at the end
+	}
+	return plugins, nil
+}
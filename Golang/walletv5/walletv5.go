@@ -0,0 +1,373 @@
+// Package walletv5 builds deploy and transfer messages for the wallet V5R1 (W5)
+// contract, the newest standard wallet.
+//
+// Compared with wallet V3 (package walletv3) the signature is at the end of the
+// body, which starts with an op, and the messages are an out-action list of up
+// to 255 actions in the format of the c5 register. The wallet also keeps a
+// dictionary of extensions: contracts which may send requests on behalf of the
+// wallet, for example to pay a subscription, without a signature.
+package walletv5
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"main/contract"
+	"main/message"
+	"main/signer"
+	"main/walletv3"
+)
+
+// Ops of the requests to the wallet.
+const (
+	OpSignedExternal  = 0x7369676e // "sign", a signed external message
+	OpSignedInternal  = 0x73696e74 // "sint", a signed request in an internal message anyone may relay
+	OpExtensionAction = 0x6578746e // "extn", a request from an extension
+)
+
+// Prefixes of the actions.
+const (
+	actionSendMsg          = 0x0ec3c86d // the out action of the c5 register
+	ActionAddExtension     = 0x02
+	ActionDeleteExtension  = 0x03
+	ActionSetSignatureAuth = 0x04
+)
+
+// MaxMessages is how many internal messages wallet V5 can send at once, the limit of the c5 register.
+const MaxMessages = 255
+
+// Global IDs of the networks, a part of the wallet ID.
+const (
+	GlobalIDMainnet = -239
+	GlobalIDTestnet = -3
+)
+
+// codeBOC is the compiled wallet V5R1 code.
+const codeBOC = "te6ccgECFAEAAoEAART/APSkE/S88sgLAQIBIAQCAQLyAwEeINcLH4IQc2lnbrry4Ip/DwIBSA4FAgEgBwYAGb5fD2omhAgKDrkPoCwCASALCAIBSAoJABGyYvtRNDXCgCAAF7Ml+1E0HHXIdcLH4AIBbg0MABmvHfaiaEAQ65DrhY/AABmtznaiaEAg65Drhf/AAtzQINdJwSCRW49jINcLHyCCEGV4dG69IYIQc2ludL2wkl8D4IIQZXh0brqOtIAg1yEB0HTXIfpAMPpE+Cj6RDBYvZFb4O1E0IEBQdch9AWDB/QOb6ExkTDhgEDXIXB/2zzgMSDXSYECgLmRMOBw4hAPAeaO8O2i7fshgwjXIgKDCNcjIIAg1yHTH9Mf0x/tRNDSANMfINMf0//XCgAK+QFAzPkQmiiUXwrbMeHywIffArNQB7Dy0IRRJbry4IVQNrry4Ib4I7vy0IgikvgA3gGkf8jKAMsfAc8Wye1UIJL4D95w2zzYEAP27aLt+wL0BCFukmwhjkwCIdc5MHCUIccAs44tAdcoIHYeQ2wg10nACPLgkyDXSsAC8uCTINcdBscSwgBSMLDy0InXTNc5MAGk6GwShAe78uCT10rAAPLgk+1V4tIAAcAAkVvg69csCBQgkXCWAdcsCBwS4lIQseMPINdKExIRABCTW9sx4ddM0AByMNcsCCSOLSHy4JLSAO1E0NIAURO68tCPVFAwkTGcAYEBQNch1woA8uCO4sjKAFjPFsntVJPywI3iAJYB+kAB+kT4KPpEMFi68uCR7UTQgQFB1xj0BQSdf8jKAEAEgwf0U/Lgi44UA4MH9Fvy4Iwi1woAIW4Bs7Dy0JDiyFADzxYS9ADJ7VQ="
+
+var (
+	ErrTooManyMessages    = errors.New("walletv5: wallet can send at most 255 messages at once")
+	ErrSubwalletNumber    = errors.New("walletv5: subwallet number is 15 bits, at most 32767")
+	ErrExternalMode       = errors.New("walletv5: a message of a signed external request needs mode +2, ignore errors")
+	ErrSignatureAuthByKey = errors.New("walletv5: only an extension may turn signature auth on or off")
+)
+
+var code = contract.MustLoadCode(codeBOC)
+
+// Code returns the wallet V5R1 code cell.
+func Code() *cell.Cell {
+	return code
+}
+
+// WalletID is what wallet V5 has instead of the subwallet ID of V3. It includes
+// the network, so the same key has different wallets on mainnet and testnet.
+type WalletID struct {
+	NetworkGlobalID int32 // GlobalIDMainnet or GlobalIDTestnet
+	Workchain       int8
+	SubwalletNumber uint16 // 0 in the wallet apps, 15 bits
+}
+
+// DefaultWalletID returns the wallet ID the wallet apps use: subwallet 0 in workchain 0.
+func DefaultWalletID(testnet bool) WalletID {
+	if testnet {
+		return WalletID{NetworkGlobalID: GlobalIDTestnet}
+	}
+	return WalletID{NetworkGlobalID: GlobalIDMainnet}
+}
+
+// Encode returns the 32-bit wallet ID stored in the data and signed in every request:
+// network_global_id XOR (1 bit, workchain:int8, version:uint8 = 0, subwallet_number:uint15).
+func (id WalletID) Encode() (uint32, error) {
+	if id.SubwalletNumber >= 1<<15 {
+		return 0, ErrSubwalletNumber
+	}
+	context := uint32(1)<<31 | // a client wallet, not a custom context
+		uint32(uint8(id.Workchain))<<23 |
+		uint32(0)<<15 | // version 0 is V5R1
+		uint32(id.SubwalletNumber)
+	return uint32(id.NetworkGlobalID) ^ context, nil
+}
+
+// Data returns the initial data cell of a wallet:
+// is_signature_allowed:Bool seqno:uint32 wallet_id:uint32 public_key:bits256 extensions:(HashmapE 256 int1)
+func Data(publicKey ed25519.PublicKey, id WalletID) (*cell.Cell, error) {
+	walletID, err := id.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return cell.BeginCell().
+		MustStoreBoolBit(true).              // Signature auth is allowed
+		MustStoreUInt(0, 32).                // Seqno
+		MustStoreUInt(uint64(walletID), 32). // Wallet ID
+		MustStoreSlice(publicKey, 256).      // Public Key
+		MustStoreDict(nil).                  // No extensions
+		EndCell(), nil
+}
+
+// StateInit returns the state init which deploys a wallet.
+func StateInit(publicKey ed25519.PublicKey, id WalletID) (*cell.Cell, error) {
+	data, err := Data(publicKey, id)
+	if err != nil {
+		return nil, err
+	}
+	return contract.StateInit(Code(), data), nil
+}
+
+// Address returns the address of a wallet in the workchain of id.
+func Address(publicKey ed25519.PublicKey, id WalletID) (*address.Address, error) {
+	stateInit, err := StateInit(publicKey, id)
+	if err != nil {
+		return nil, err
+	}
+	return address.NewAddress(0, byte(id.Workchain), stateInit.Hash()), nil
+}
+
+// ExtendedAction is an action which is not a message: AddExtension,
+// RemoveExtension or SetSignatureAuth.
+type ExtendedAction interface {
+	store(b *cell.Builder)
+}
+
+// AddExtension allows Extension to send requests on behalf of the wallet. The
+// wallet accepts only extensions in its own workchain.
+type AddExtension struct {
+	Extension *address.Address
+}
+
+func (a AddExtension) store(b *cell.Builder) {
+	b.MustStoreUInt(ActionAddExtension, 8).MustStoreAddr(a.Extension)
+}
+
+// RemoveExtension removes an extension. The wallet refuses to remove the last
+// one while signature auth is off, it would lock the wallet.
+type RemoveExtension struct {
+	Extension *address.Address
+}
+
+func (r RemoveExtension) store(b *cell.Builder) {
+	b.MustStoreUInt(ActionDeleteExtension, 8).MustStoreAddr(r.Extension)
+}
+
+// SetSignatureAuth turns requests signed by the key on or off. Only an
+// extension sends it, see ExtensionBody.
+type SetSignatureAuth struct {
+	Allowed bool
+}
+
+func (s SetSignatureAuth) store(b *cell.Builder) {
+	b.MustStoreUInt(ActionSetSignatureAuth, 8).MustStoreBoolBit(s.Allowed)
+}
+
+// Actions is what one request does: it sends Messages and then runs Extended.
+type Actions struct {
+	Messages []message.Out
+	Extended []ExtendedAction
+}
+
+// store stores the inner request:
+//
+//	out_actions:(Maybe ^OutList) has_other_actions:(## 1) other_actions:ActionList
+func (a *Actions) store(b *cell.Builder, external bool) error {
+	if len(a.Messages) > MaxMessages {
+		return ErrTooManyMessages
+	}
+
+	if len(a.Messages) == 0 {
+		b.MustStoreBoolBit(false) // No out actions
+	} else {
+		// out_list$_ prev:^OutList action:OutAction, so the first message is the deepest one
+		outList := cell.BeginCell().EndCell()
+		for i, m := range a.Messages {
+			if err := m.Mode.Validate(); err != nil {
+				return fmt.Errorf("walletv5: message %d: %w", i+1, err)
+			}
+			if external && m.Mode&message.FlagIgnoreErrors == 0 {
+				return fmt.Errorf("%w: message %d has mode %d", ErrExternalMode, i+1, m.Mode)
+			}
+			outList = cell.BeginCell().
+				MustStoreRef(outList).
+				MustStoreUInt(actionSendMsg, 32).
+				MustStoreUInt(uint64(m.Mode), 8). // store mode of our internal message
+				MustStoreRef(m.Message).          // store our internal message as a reference
+				EndCell()
+		}
+		b.MustStoreBoolBit(true).MustStoreRef(outList)
+	}
+
+	if len(a.Extended) == 0 {
+		b.MustStoreBoolBit(false) // No other actions
+		return nil
+	}
+	b.MustStoreBoolBit(true)
+
+	// The first extended action is in this cell, every next one is in a reference of the previous
+	var next *cell.Cell
+	for i := len(a.Extended) - 1; i > 0; i-- {
+		c := cell.BeginCell()
+		a.Extended[i].store(c)
+		if next != nil {
+			c.MustStoreRef(next)
+		}
+		next = c.EndCell()
+	}
+	a.Extended[0].store(b)
+	if next != nil {
+		b.MustStoreRef(next)
+	}
+	return nil
+}
+
+// Request is a request signed by the key of the wallet, sent in an external
+// message (Sign, External) or in an internal one (SignInternal).
+type Request struct {
+	WalletID   uint32 // WalletID.Encode
+	ValidUntil time.Time
+	Seqno      uint32
+	Actions
+}
+
+// Payload returns the part of the body which is signed, op included.
+func (r *Request) Payload(op uint32) (*cell.Builder, error) {
+	for _, action := range r.Extended {
+		if _, ok := action.(SetSignatureAuth); ok {
+			return nil, ErrSignatureAuthByKey
+		}
+	}
+
+	toSign := cell.BeginCell().
+		MustStoreUInt(uint64(op), 32).
+		MustStoreUInt(uint64(r.WalletID), 32).          // wallet_id
+		MustStoreUInt(uint64(r.ValidUntil.Unix()), 32). // message expiration time
+		MustStoreUInt(uint64(r.Seqno), 32)              // store seqno
+
+	if err := r.store(toSign, op == OpSignedExternal); err != nil {
+		return nil, err
+	}
+	return toSign, nil
+}
+
+func (r *Request) sign(ctx context.Context, s signer.Signer, op uint32) (*cell.Cell, error) {
+	toSign, err := r.Payload(op)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.Sign(ctx, toSign.EndCell().Hash())
+	if err != nil {
+		return nil, err
+	}
+	return toSign.MustStoreSlice(signature, 512).EndCell(), nil // unlike V3, the signature is the last
+}
+
+// Sign signs the request with s and returns the body of the external message.
+func (r *Request) Sign(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	return r.sign(ctx, s, OpSignedExternal)
+}
+
+// SignInternal signs the request with s and returns the body of an internal
+// message to the wallet. Anyone may send it, for example a service which
+// pays the fees in TON while the wallet sends jettons.
+func (r *Request) SignInternal(ctx context.Context, s signer.Signer) (*cell.Cell, error) {
+	return r.sign(ctx, s, OpSignedInternal)
+}
+
+// External signs the request and wraps it into an external message to walletAddress.
+// stateInit is nil for a wallet which is already deployed.
+func (r *Request) External(ctx context.Context, s signer.Signer, walletAddress *address.Address, stateInit *cell.Cell) (*cell.Cell, error) {
+	body, err := r.Sign(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return message.External(walletAddress, stateInit, body), nil
+}
+
+// ExtensionBody returns the body an installed extension sends to the wallet to
+// run a, no signature needed. This is the only way to use SetSignatureAuth.
+func ExtensionBody(queryID uint64, a Actions) (*cell.Cell, error) {
+	b := cell.BeginCell().
+		MustStoreUInt(OpExtensionAction, 32).
+		MustStoreUInt(queryID, 64)
+	if err := a.store(b, false); err != nil {
+		return nil, err
+	}
+	return b.EndCell(), nil
+}
+
+// GetSeqno runs the "seqno" get method, the same as the one of wallet V3.
+func GetSeqno(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (uint32, error) {
+	return walletv3.GetSeqno(ctx, api, walletAddress)
+}
+
+// GetWalletID runs the "get_subwallet_id" get method of a wallet, which returns the encoded wallet ID.
+func GetWalletID(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (uint32, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "get_subwallet_id")
+	if err != nil {
+		return 0, fmt.Errorf("walletv5: run get_subwallet_id: %w", err)
+	}
+	walletID, err := result.Int(0)
+	if err != nil {
+		return 0, fmt.Errorf("walletv5: read wallet ID: %w", err)
+	}
+	return uint32(walletID.Int64()), nil // Int64, in case the get method returns it as int32
+}
+
+// IsSignatureAllowed runs the "is_signature_allowed" get method of a wallet.
+func IsSignatureAllowed(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) (bool, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "is_signature_allowed")
+	if err != nil {
+		return false, fmt.Errorf("walletv5: run is_signature_allowed: %w", err)
+	}
+	allowed, err := result.Int(0)
+	if err != nil {
+		return false, fmt.Errorf("walletv5: read is_signature_allowed: %w", err)
+	}
+	return allowed.Sign() != 0, nil // -1 is true
+}
+
+// GetExtensions runs the "get_extensions" get method of a wallet.
+func GetExtensions(ctx context.Context, api walletv3.TonAPI, walletAddress *address.Address) ([]*address.Address, error) {
+	block, err := api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.RunGetMethod(ctx, block, walletAddress, "get_extensions")
+	if err != nil {
+		return nil, fmt.Errorf("walletv5: run get_extensions: %w", err)
+	}
+	if empty, err := result.IsNil(0); err != nil || empty {
+		return nil, err // an empty dictionary is null
+	}
+	dictCell, err := result.Cell(0)
+	if err != nil {
+		return nil, fmt.Errorf("walletv5: read get_extensions: %w", err)
+	}
+	dictionary, err := dictCell.BeginParse().ToDict(256)
+	if err != nil {
+		return nil, fmt.Errorf("walletv5: read get_extensions: %w", err)
+	}
+
+	// The keys are the account IDs, the extensions are in the workchain of the wallet
+	var extensions []*address.Address
+	for _, kv := range dictionary.All() {
+		hash := kv.Key.BeginParse().MustLoadBigUInt(256)
+		extensions = append(extensions, address.NewAddress(0, byte(walletAddress.Workchain()), hash.FillBytes(make([]byte, 32))))
+	}
+	return extensions, nil
+}